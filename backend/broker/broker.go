@@ -0,0 +1,64 @@
+package broker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// Handler receives the payload of a message published on a subject.
+type Handler func(data []byte)
+
+// Broker fans messages out between backend instances. Every instance subscribed to a
+// subject receives each message published on it at most once, in publication order
+// for a given publisher, including the instance that published it.
+type Broker interface {
+	// Publish returns ErrUnconfirmed when the message was sent but its delivery could not
+	// be confirmed: it may or may not have reached the subscribers, so sending it again may
+	// deliver it twice. Any other error means nothing was published.
+	Publish(ctx context.Context, subject string, data []byte) error
+	Subscribe(subject string, handler Handler) error
+	// OnResubscribe registers fn, called each time the subscriptions are re-established
+	// after a loss of connection: messages published in between were not received.
+	OnResubscribe(fn func())
+	Close() error
+}
+
+// ErrUnconfirmed is returned by Publish when the message may have been published.
+var ErrUnconfirmed = errors.New("broker: publish not confirmed")
+
+// Config describes which broker to use and how to reach it.
+type Config struct {
+	Driver   string // "memory" (single instance) or "redis"
+	RedisURL string // redis://[user:password@]host:port
+	Prefix   string // prefix of the channel names, to share a server between deployments
+}
+
+// ConfigFromEnv reads the broker configuration from environment variables.
+func ConfigFromEnv() Config {
+	return Config{
+		Driver:   getEnv("BROKER_DRIVER", "memory"),
+		RedisURL: getEnv("REDIS_URL", "redis://localhost:6379"),
+		Prefix:   getEnv("BROKER_PREFIX", "social"),
+	}
+}
+
+// New builds the broker described by cfg.
+func New(cfg Config) (Broker, error) {
+	switch cfg.Driver {
+	case "", "memory":
+		return NewMemoryBroker(), nil
+	case "redis":
+		return NewRedisBroker(cfg.RedisURL, cfg.Prefix)
+	default:
+		return nil, fmt.Errorf("unknown broker driver %q", cfg.Driver)
+	}
+}
+
+func getEnv(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
@@ -0,0 +1,42 @@
+package broker
+
+import (
+	"context"
+	"sync"
+)
+
+// MemoryBroker delivers messages to the subscribers of the current process only.
+// Handlers run synchronously in the publishing goroutine.
+type MemoryBroker struct {
+	mu       sync.RWMutex
+	handlers map[string][]Handler
+}
+
+func NewMemoryBroker() *MemoryBroker {
+	return &MemoryBroker{handlers: make(map[string][]Handler)}
+}
+
+func (b *MemoryBroker) Publish(ctx context.Context, subject string, data []byte) error {
+	b.mu.RLock()
+	handlers := b.handlers[subject]
+	b.mu.RUnlock()
+
+	for _, h := range handlers {
+		h(data)
+	}
+	return nil
+}
+
+func (b *MemoryBroker) Subscribe(subject string, handler Handler) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.handlers[subject] = append(b.handlers[subject], handler)
+	return nil
+}
+
+// OnResubscribe does nothing: in-process subscriptions are never lost.
+func (b *MemoryBroker) OnResubscribe(fn func()) {}
+
+func (b *MemoryBroker) Close() error {
+	return nil
+}
@@ -0,0 +1,389 @@
+package broker
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	redisDialTimeout = 5 * time.Second
+	redisMaxBackoff  = 10 * time.Second
+)
+
+// RedisBroker uses Redis PUBLISH/SUBSCRIBE over a minimal RESP client: one connection
+// for publishing (reopened on error) and one dedicated to subscriptions, re-established
+// in the background with the same channels when it drops. Messages published while
+// the subscription is down are lost for this instance; the OnResubscribe callbacks run
+// once it is back so that they can be recovered from elsewhere (the event log).
+type RedisBroker struct {
+	addr     string
+	username string
+	password string
+	prefix   string
+
+	pubMu sync.Mutex
+	pub   *redisConn
+
+	subMu         sync.Mutex
+	sub           *redisConn
+	handlers      map[string][]Handler
+	started       bool
+	onResubscribe []func()
+
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+type redisConn struct {
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+// NewRedisBroker parses rawURL (redis://[user:password@]host:port) and checks the server is reachable.
+func NewRedisBroker(rawURL, prefix string) (*RedisBroker, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Scheme != "redis" || u.Host == "" {
+		return nil, fmt.Errorf("invalid redis url %q", rawURL)
+	}
+	b := &RedisBroker{
+		addr:     u.Host,
+		prefix:   prefix,
+		handlers: make(map[string][]Handler),
+		closed:   make(chan struct{}),
+	}
+	if u.Port() == "" {
+		b.addr = net.JoinHostPort(u.Hostname(), "6379")
+	}
+	if u.User != nil {
+		b.password, _ = u.User.Password()
+		b.username = u.User.Username()
+		if b.password == "" {
+			// redis://secret@host : le mot de passe seul
+			b.password, b.username = b.username, ""
+		}
+	}
+
+	b.pubMu.Lock()
+	defer b.pubMu.Unlock()
+	if b.pub, err = b.dial(); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func (b *RedisBroker) channel(subject string) string {
+	if b.prefix == "" {
+		return subject
+	}
+	return b.prefix + ":" + subject
+}
+
+func (b *RedisBroker) dial() (*redisConn, error) {
+	conn, err := net.DialTimeout("tcp", b.addr, redisDialTimeout)
+	if err != nil {
+		return nil, err
+	}
+	c := &redisConn{conn: conn, r: bufio.NewReader(conn)}
+	if b.password != "" {
+		args := []string{"AUTH", b.password}
+		if b.username != "" {
+			args = []string{"AUTH", b.username, b.password}
+		}
+		if _, err := c.do(args...); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("redis auth: %w", err)
+		}
+	}
+	return c, nil
+}
+
+// Publish sends the message, reconnecting once if the publishing connection was broken
+// before the command could be written. Once written, it is never re-sent: a lost reply
+// does not mean the message was not published (ErrUnconfirmed).
+func (b *RedisBroker) Publish(ctx context.Context, subject string, data []byte) error {
+	b.pubMu.Lock()
+	defer b.pubMu.Unlock()
+
+	var err error
+	for attempt := 0; attempt < 2; attempt++ {
+		if b.pub == nil {
+			if b.pub, err = b.dial(); err != nil {
+				continue
+			}
+		}
+		if deadline, ok := ctx.Deadline(); ok {
+			b.pub.conn.SetDeadline(deadline)
+		} else {
+			b.pub.conn.SetDeadline(time.Now().Add(redisDialTimeout))
+		}
+		// Une commande écrite en partie n'est jamais exécutée : le serveur la jette avec la connexion
+		if err = b.pub.write("PUBLISH", b.channel(subject), string(data)); err != nil {
+			b.pub.conn.Close()
+			b.pub = nil
+			continue
+		}
+		reply, err := b.pub.read()
+		if err != nil {
+			b.pub.conn.Close()
+			b.pub = nil
+			return fmt.Errorf("%w: %v", ErrUnconfirmed, err)
+		}
+		if e, ok := reply.(redisError); ok {
+			return e
+		}
+		return nil
+	}
+	return err
+}
+
+// Subscribe registers handler for subject. Handlers of every subject run one at a time,
+// in the order the messages arrive.
+func (b *RedisBroker) Subscribe(subject string, handler Handler) error {
+	b.subMu.Lock()
+	defer b.subMu.Unlock()
+
+	channel := b.channel(subject)
+	_, known := b.handlers[channel]
+	b.handlers[channel] = append(b.handlers[channel], handler)
+
+	if !b.started {
+		b.started = true
+		go b.listen()
+		return nil
+	}
+	if !known && b.sub != nil {
+		// Une erreur ici fera tomber la connexion : listen se réabonnera à tous les canaux
+		return b.sub.write("SUBSCRIBE", channel)
+	}
+	return nil
+}
+
+// OnResubscribe registers fn, called in its own goroutine once every channel has been
+// subscribed again after the subscription connection dropped.
+func (b *RedisBroker) OnResubscribe(fn func()) {
+	b.subMu.Lock()
+	defer b.subMu.Unlock()
+	b.onResubscribe = append(b.onResubscribe, fn)
+}
+
+// listen keeps the subscription connection open and dispatches incoming messages.
+func (b *RedisBroker) listen() {
+	backoff := 100 * time.Millisecond
+	resubscribing := false
+	for {
+		select {
+		case <-b.closed:
+			return
+		default:
+		}
+
+		conn, channels, err := b.subscribe()
+		if err != nil {
+			log.Printf("❌ Broker redis: abonnement impossible: %v", err)
+			select {
+			case <-b.closed:
+				return
+			case <-time.After(backoff):
+			}
+			backoff = min(backoff*2, redisMaxBackoff)
+			continue
+		}
+		backoff = 100 * time.Millisecond
+
+		var ready func()
+		if resubscribing {
+			ready = b.resubscribed
+		}
+		err = b.read(conn, channels, ready)
+		resubscribing = true
+		b.subMu.Lock()
+		b.sub = nil
+		b.subMu.Unlock()
+		conn.conn.Close()
+
+		select {
+		case <-b.closed:
+			return
+		default:
+			log.Printf("⚠️ Broker redis: connexion d'abonnement perdue: %v", err)
+		}
+	}
+}
+
+// subscribe opens the subscription connection and returns the number of channels subscribed.
+func (b *RedisBroker) subscribe() (*redisConn, int, error) {
+	conn, err := b.dial()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	b.subMu.Lock()
+	defer b.subMu.Unlock()
+	args := []string{"SUBSCRIBE"}
+	for channel := range b.handlers {
+		args = append(args, channel)
+	}
+	if err := conn.write(args...); err != nil {
+		conn.conn.Close()
+		return nil, 0, err
+	}
+	b.sub = conn
+	return conn, len(args) - 1, nil
+}
+
+// resubscribed runs the OnResubscribe callbacks.
+func (b *RedisBroker) resubscribed() {
+	b.subMu.Lock()
+	callbacks := b.onResubscribe
+	b.subMu.Unlock()
+	for _, fn := range callbacks {
+		go fn()
+	}
+}
+
+// read dispatches the messages received on conn; ready runs once the server has confirmed
+// the subscription to all channels, so that nothing published afterwards can be missed.
+func (b *RedisBroker) read(conn *redisConn, channels int, ready func()) error {
+	for {
+		reply, err := conn.read()
+		if err != nil {
+			return err
+		}
+		parts, ok := reply.([]interface{})
+		if !ok || len(parts) != 3 {
+			continue
+		}
+		kind, _ := parts[0].(string)
+		if kind == "subscribe" {
+			if channels--; channels == 0 && ready != nil {
+				ready()
+			}
+			continue
+		}
+		if kind != "message" {
+			continue
+		}
+		channel, _ := parts[1].(string)
+		data, _ := parts[2].(string)
+
+		b.subMu.Lock()
+		handlers := b.handlers[channel]
+		b.subMu.Unlock()
+		for _, h := range handlers {
+			h([]byte(data))
+		}
+	}
+}
+
+func (b *RedisBroker) Close() error {
+	b.closeOnce.Do(func() {
+		close(b.closed)
+		b.pubMu.Lock()
+		if b.pub != nil {
+			b.pub.conn.Close()
+			b.pub = nil
+		}
+		b.pubMu.Unlock()
+		b.subMu.Lock()
+		if b.sub != nil {
+			b.sub.conn.Close()
+		}
+		b.subMu.Unlock()
+	})
+	return nil
+}
+
+// redisError is an error reply (-ERR ...) sent by the server.
+type redisError string
+
+func (e redisError) Error() string { return string(e) }
+
+func (c *redisConn) do(args ...string) (interface{}, error) {
+	if err := c.write(args...); err != nil {
+		return nil, err
+	}
+	reply, err := c.read()
+	if err != nil {
+		return nil, err
+	}
+	if e, ok := reply.(redisError); ok {
+		return nil, e
+	}
+	return reply, nil
+}
+
+// write encodes a command as a RESP array of bulk strings.
+func (c *redisConn) write(args ...string) error {
+	buf := make([]byte, 0, 64)
+	buf = append(buf, '*')
+	buf = strconv.AppendInt(buf, int64(len(args)), 10)
+	buf = append(buf, '\r', '\n')
+	for _, a := range args {
+		buf = append(buf, '$')
+		buf = strconv.AppendInt(buf, int64(len(a)), 10)
+		buf = append(buf, '\r', '\n')
+		buf = append(buf, a...)
+		buf = append(buf, '\r', '\n')
+	}
+	_, err := c.conn.Write(buf)
+	return err
+}
+
+// read decodes one RESP reply: strings, integers, errors, bulk strings and arrays.
+func (c *redisConn) read() (interface{}, error) {
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return nil, fmt.Errorf("redis: malformed reply %q", line)
+	}
+	kind, body := line[0], line[1:len(line)-2]
+
+	switch kind {
+	case '+':
+		return body, nil
+	case '-':
+		return redisError(body), nil
+	case ':':
+		return strconv.ParseInt(body, 10, 64)
+	case '$':
+		n, err := strconv.Atoi(body)
+		if err != nil {
+			return nil, err
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(c.r, buf); err != nil {
+			return nil, err
+		}
+		return string(buf[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(body)
+		if err != nil {
+			return nil, err
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		items := make([]interface{}, n)
+		for i := range items {
+			if items[i], err = c.read(); err != nil {
+				return nil, err
+			}
+		}
+		return items, nil
+	default:
+		return nil, fmt.Errorf("redis: unexpected reply type %q", kind)
+	}
+}
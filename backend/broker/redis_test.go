@@ -0,0 +1,138 @@
+package broker
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"social/broker/redistest"
+)
+
+// collector records the messages received by a subscription.
+type collector chan string
+
+func (c collector) handle(data []byte) { c <- string(data) }
+
+// expect waits for want, in order, then checks nothing else arrives.
+func (c collector) expect(t *testing.T, want ...string) {
+	t.Helper()
+	for _, w := range want {
+		select {
+		case got := <-c:
+			if got != w {
+				t.Fatalf("received %q, want %q", got, w)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("%q not received", w)
+		}
+	}
+	select {
+	case got := <-c:
+		t.Fatalf("unexpected message %q", got)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func newTestBroker(t *testing.T, srv *redistest.Server) *RedisBroker {
+	t.Helper()
+	b, err := NewRedisBroker(srv.URL, "test")
+	if err != nil {
+		t.Fatalf("NewRedisBroker: %v", err)
+	}
+	t.Cleanup(func() { b.Close() })
+	return b
+}
+
+func waitSubscribers(t *testing.T, srv *redistest.Server, channel string, n int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for srv.Subscribers(channel) != n {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d subscribers on %s, want %d", srv.Subscribers(channel), channel, n)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestRedisPublishReachesEveryInstanceOnce(t *testing.T) {
+	srv := redistest.NewServer()
+	defer srv.Close()
+	a, b := newTestBroker(t, srv), newTestBroker(t, srv)
+
+	gotA, gotB := make(collector, 10), make(collector, 10)
+	a.Subscribe("events", gotA.handle)
+	b.Subscribe("events", gotB.handle)
+	waitSubscribers(t, srv, "test:events", 2)
+
+	ctx := context.Background()
+	if err := a.Publish(ctx, "events", []byte("one")); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+	if err := b.Publish(ctx, "events", []byte("two")); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+	gotA.expect(t, "one", "two")
+	gotB.expect(t, "one", "two")
+}
+
+func TestRedisPublishNotResentAfterLostReply(t *testing.T) {
+	srv := redistest.NewServer()
+	defer srv.Close()
+	b := newTestBroker(t, srv)
+
+	got := make(collector, 10)
+	b.Subscribe("events", got.handle)
+	waitSubscribers(t, srv, "test:events", 1)
+
+	// Le message est diffusé mais la réponse se perd : pas de nouvel envoi
+	srv.LoseReplies(1)
+	err := b.Publish(context.Background(), "events", []byte("once"))
+	if !errors.Is(err, ErrUnconfirmed) {
+		t.Fatalf("Publish error = %v, want ErrUnconfirmed", err)
+	}
+	got.expect(t, "once")
+
+	// La connexion de publication est rouverte au message suivant
+	if err := b.Publish(context.Background(), "events", []byte("next")); err != nil {
+		t.Fatalf("Publish after lost reply: %v", err)
+	}
+	got.expect(t, "next")
+}
+
+func TestRedisResubscribe(t *testing.T) {
+	srv := redistest.NewServer()
+	defer srv.Close()
+	pub, sub := newTestBroker(t, srv), newTestBroker(t, srv)
+
+	got := make(collector, 10)
+	resubscribed := make(chan struct{}, 1)
+	sub.OnResubscribe(func() { resubscribed <- struct{}{} })
+	sub.Subscribe("events", got.handle)
+	waitSubscribers(t, srv, "test:events", 1)
+
+	srv.RejectSubscriptions(true)
+	srv.DropSubscriptions()
+	waitSubscribers(t, srv, "test:events", 0)
+
+	// Publier réussit pendant la coupure, mais l'instance abonnée ne reçoit rien
+	if err := pub.Publish(context.Background(), "events", []byte("missed")); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+	select {
+	case <-resubscribed:
+		t.Fatal("OnResubscribe called while the subscription is down")
+	case <-time.After(150 * time.Millisecond):
+	}
+
+	srv.RejectSubscriptions(false)
+	select {
+	case <-resubscribed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("OnResubscribe not called after the subscription came back")
+	}
+	if err := pub.Publish(context.Background(), "events", []byte("live")); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+	got.expect(t, "live")
+}
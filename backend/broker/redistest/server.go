@@ -0,0 +1,261 @@
+// Package redistest provides an in-process Redis stand-in for tests, speaking enough
+// of the RESP protocol for the broker: PING, AUTH, PUBLISH and SUBSCRIBE. Faults can
+// be injected to exercise lost replies and dropped subscriptions.
+package redistest
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Server is a Redis pub/sub server listening on a local port.
+type Server struct {
+	// URL is the redis:// address to give to the broker
+	URL string
+
+	ln net.Listener
+
+	mu              sync.Mutex
+	conns           map[*conn]struct{}
+	rejectSubscribe bool
+	loseReplies     int
+	dropPublishes   int
+}
+
+type conn struct {
+	net.Conn
+	writeMu  sync.Mutex
+	channels map[string]bool
+}
+
+// NewServer starts a server on a random local port. Close it when done.
+func NewServer() *Server {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		panic(fmt.Sprintf("redistest: listen: %v", err))
+	}
+	s := &Server{
+		URL:   "redis://" + ln.Addr().String(),
+		ln:    ln,
+		conns: make(map[*conn]struct{}),
+	}
+	go s.serve()
+	return s
+}
+
+// Close stops the server and closes every connection.
+func (s *Server) Close() {
+	s.ln.Close()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for c := range s.conns {
+		c.Close()
+	}
+}
+
+// Subscribers returns the number of connections subscribed to channel.
+func (s *Server) Subscribers(channel string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for c := range s.conns {
+		if c.channels[channel] {
+			n++
+		}
+	}
+	return n
+}
+
+// DropSubscriptions closes every subscribed connection.
+func (s *Server) DropSubscriptions() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for c := range s.conns {
+		if len(c.channels) > 0 {
+			c.Close()
+		}
+	}
+}
+
+// RejectSubscriptions makes the server close connections sending SUBSCRIBE while reject is true.
+func (s *Server) RejectSubscriptions(reject bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rejectSubscribe = reject
+}
+
+// LoseReplies makes the next n PUBLISH commands reach the subscribers, then close the
+// publishing connection instead of replying, like a reply lost on the network.
+func (s *Server) LoseReplies(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.loseReplies = n
+}
+
+// DropPublishes makes the next n PUBLISH commands close the publishing connection
+// without reaching any subscriber, like a server failing before processing them.
+func (s *Server) DropPublishes(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.dropPublishes = n
+}
+
+func (s *Server) serve() {
+	for {
+		nc, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		c := &conn{Conn: nc, channels: make(map[string]bool)}
+		s.mu.Lock()
+		s.conns[c] = struct{}{}
+		s.mu.Unlock()
+		go s.handle(c)
+	}
+}
+
+func (s *Server) handle(c *conn) {
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, c)
+		s.mu.Unlock()
+		c.Close()
+	}()
+
+	r := bufio.NewReader(c)
+	for {
+		args, err := readCommand(r)
+		if err != nil {
+			return
+		}
+		switch strings.ToUpper(args[0]) {
+		case "PING":
+			c.reply("+PONG\r\n")
+		case "AUTH":
+			c.reply("+OK\r\n")
+		case "SUBSCRIBE":
+			s.mu.Lock()
+			reject := s.rejectSubscribe
+			s.mu.Unlock()
+			if reject {
+				return
+			}
+			for _, channel := range args[1:] {
+				s.mu.Lock()
+				c.channels[channel] = true
+				n := len(c.channels)
+				s.mu.Unlock()
+				c.reply(array("subscribe", channel) + ":" + strconv.Itoa(n) + "\r\n")
+			}
+		case "PUBLISH":
+			if len(args) != 3 {
+				c.reply("-ERR wrong number of arguments for 'publish' command\r\n")
+				continue
+			}
+			s.mu.Lock()
+			drop := s.dropPublishes > 0
+			if drop {
+				s.dropPublishes--
+			}
+			s.mu.Unlock()
+			if drop {
+				return
+			}
+			n := s.publish(args[1], args[2])
+			s.mu.Lock()
+			lose := s.loseReplies > 0
+			if lose {
+				s.loseReplies--
+			}
+			s.mu.Unlock()
+			if lose {
+				return
+			}
+			c.reply(":" + strconv.Itoa(n) + "\r\n")
+		default:
+			c.reply("-ERR unknown command '" + args[0] + "'\r\n")
+		}
+	}
+}
+
+// publish sends the message to the subscribers of channel and returns their number.
+func (s *Server) publish(channel, message string) int {
+	s.mu.Lock()
+	var subscribers []*conn
+	for c := range s.conns {
+		if c.channels[channel] {
+			subscribers = append(subscribers, c)
+		}
+	}
+	s.mu.Unlock()
+
+	frame := "*3\r\n" + bulk("message") + bulk(channel) + bulk(message)
+	for _, c := range subscribers {
+		c.reply(frame)
+	}
+	return len(subscribers)
+}
+
+func (c *conn) reply(data string) {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	io.WriteString(c, data)
+}
+
+// array starts the 3-element push reply of a subscription confirmation.
+func array(kind, channel string) string {
+	return "*3\r\n" + bulk(kind) + bulk(channel)
+}
+
+func bulk(s string) string {
+	return "$" + strconv.Itoa(len(s)) + "\r\n" + s + "\r\n"
+}
+
+// readCommand decodes a RESP array of bulk strings.
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := readLine(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 2 || line[0] != '*' {
+		return nil, errors.New("redistest: expected an array")
+	}
+	n, err := strconv.Atoi(line[1:])
+	if err != nil || n < 1 {
+		return nil, errors.New("redistest: bad array length")
+	}
+	args := make([]string, n)
+	for i := range args {
+		line, err := readLine(r)
+		if err != nil {
+			return nil, err
+		}
+		if len(line) < 2 || line[0] != '$' {
+			return nil, errors.New("redistest: expected a bulk string")
+		}
+		size, err := strconv.Atoi(line[1:])
+		if err != nil || size < 0 {
+			return nil, errors.New("redistest: bad bulk length")
+		}
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		args[i] = string(buf[:size])
+	}
+	return args, nil
+}
+
+func readLine(r *bufio.Reader) (string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(line, "\r\n"), nil
+}
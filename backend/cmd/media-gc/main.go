@@ -0,0 +1,45 @@
+// Command media-gc runs one pass of the orphaned uploads sweeper and prints what it found.
+//
+// It is a dry run unless -dry-run=false is given:
+//
+//	go run ./cmd/media-gc [-grace 24h] [-dry-run=false]
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"social/db/sqlite"
+	"social/repositories"
+	"social/services"
+	"social/storage"
+)
+
+func main() {
+	grace := flag.Duration("grace", 24*time.Hour, "ignore files uploaded more recently than this")
+	dryRun := flag.Bool("dry-run", true, "only list orphaned files, do not delete anything")
+	flag.Parse()
+
+	sqlite.InitDB()
+	if err := storage.Init(); err != nil {
+		fmt.Printf("❌ Failed to initialize media storage: %v\n", err)
+		os.Exit(1)
+	}
+
+	mediaService := services.NewMediaService(repositories.NewMediaRepository(sqlite.GetDB()))
+	report, err := mediaService.SweepOrphans(context.Background(), *grace, *dryRun)
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		os.Exit(1)
+	}
+
+	action := "deleted"
+	if report.DryRun {
+		action = "would be deleted"
+	}
+	fmt.Printf("\nDone: %d of %d files %s (%d bytes), %d stale media records\n",
+		report.Orphans, report.Scanned, action, report.FreedBytes, report.StaleMedia)
+}
@@ -0,0 +1,131 @@
+// Command media-migrate copies uploaded media from one storage backend to another.
+//
+// Both backends are configured from the usual environment variables (UPLOADS_DIR,
+// S3_ENDPOINT, S3_BUCKET, ...); only the driver differs:
+//
+//	go run ./cmd/media-migrate -from local -to s3 [-dry-run] [-delete-source]
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"social/storage"
+)
+
+func main() {
+	from := flag.String("from", "local", "source driver (local or s3)")
+	to := flag.String("to", "s3", "destination driver (local or s3)")
+	prefix := flag.String("prefix", "", "only migrate keys starting with this prefix")
+	dryRun := flag.Bool("dry-run", false, "list what would be copied without writing anything")
+	deleteSource := flag.Bool("delete-source", false, "delete each file from the source once copied")
+	overwrite := flag.Bool("overwrite", false, "copy files that already exist in the destination")
+	flag.Parse()
+
+	if *from == *to {
+		fmt.Println("❌ -from and -to must be different drivers")
+		os.Exit(2)
+	}
+
+	src, err := newStorage(*from)
+	if err != nil {
+		fmt.Printf("❌ Source storage: %v\n", err)
+		os.Exit(1)
+	}
+	dst, err := newStorage(*to)
+	if err != nil {
+		fmt.Printf("❌ Destination storage: %v\n", err)
+		os.Exit(1)
+	}
+
+	opts := options{prefix: *prefix, dryRun: *dryRun, deleteSource: *deleteSource, overwrite: *overwrite}
+	res, err := migrate(context.Background(), src, dst, opts)
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("\nDone: %d copied, %d already present, %d failed\n", res.copied, res.skipped, res.failed)
+	if res.failed > 0 {
+		os.Exit(1)
+	}
+}
+
+type options struct {
+	prefix       string
+	dryRun       bool
+	deleteSource bool
+	overwrite    bool
+}
+
+type result struct {
+	copied, skipped, failed int
+}
+
+// migrate copies every object of src under opts.prefix to dst. Failures on single
+// files are reported and counted; only listing errors abort the migration.
+func migrate(ctx context.Context, src, dst storage.Storage, opts options) (result, error) {
+	var res result
+
+	objects, err := src.List(ctx, opts.prefix)
+	if err != nil {
+		return res, fmt.Errorf("failed to list source files: %w", err)
+	}
+
+	existing := make(map[string]bool)
+	if !opts.overwrite {
+		dstObjects, err := dst.List(ctx, opts.prefix)
+		if err != nil {
+			return res, fmt.Errorf("failed to list destination files: %w", err)
+		}
+		for _, o := range dstObjects {
+			existing[o.Key] = true
+		}
+	}
+
+	for _, o := range objects {
+		if existing[o.Key] {
+			res.skipped++
+			continue
+		}
+
+		if opts.dryRun {
+			fmt.Printf("🔎 would copy %s (%d bytes)\n", o.Key, o.Size)
+			res.copied++
+			continue
+		}
+
+		if err := copyObject(ctx, src, dst, o.Key); err != nil {
+			fmt.Printf("❌ %s: %v\n", o.Key, err)
+			res.failed++
+			continue
+		}
+		res.copied++
+		fmt.Printf("✅ %s\n", o.Key)
+
+		if opts.deleteSource {
+			if err := src.Delete(ctx, o.Key); err != nil {
+				fmt.Printf("⚠️ copied but failed to delete source %s: %v\n", o.Key, err)
+			}
+		}
+	}
+	return res, nil
+}
+
+func newStorage(driver string) (storage.Storage, error) {
+	cfg := storage.ConfigFromEnv()
+	cfg.Driver = driver
+	return storage.New(cfg)
+}
+
+func copyObject(ctx context.Context, src, dst storage.Storage, key string) error {
+	obj, err := src.Get(ctx, key)
+	if err != nil {
+		return err
+	}
+	defer obj.Body.Close()
+
+	return dst.Put(ctx, key, obj.Body, obj.Size, obj.ContentType)
+}
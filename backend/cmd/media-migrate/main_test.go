@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"social/storage"
+	"social/storage/s3test"
+)
+
+func TestMigrateLocalToS3(t *testing.T) {
+	ctx := context.Background()
+
+	local, err := storage.NewLocalStorage(t.TempDir(), "http://localhost:8080/uploads")
+	if err != nil {
+		t.Fatalf("NewLocalStorage: %v", err)
+	}
+	files := map[string]string{
+		"avatars/a.jpg":    "avatar",
+		"posts/b.png":      "post",
+		"posts/c d.png":    "spaced",
+		"chat/voice.webm":  "voice",
+		"groups/cover.jpg": "cover",
+	}
+	for key, content := range files {
+		if err := local.Put(ctx, key, strings.NewReader(content), int64(len(content)), ""); err != nil {
+			t.Fatalf("local Put(%q): %v", key, err)
+		}
+	}
+
+	srv := s3test.NewServer("AKIDTEST", "secret", "us-east-1", "media")
+	defer srv.Close()
+	s3, err := storage.NewS3Storage(storage.S3Config{
+		Endpoint: srv.URL, Region: srv.Region, Bucket: srv.Bucket,
+		AccessKey: srv.AccessKey, SecretKey: srv.SecretKey, UsePathStyle: true,
+	})
+	if err != nil {
+		t.Fatalf("NewS3Storage: %v", err)
+	}
+
+	// Un fichier déjà présent dans la destination n'est pas recopié
+	if err := s3.Put(ctx, "posts/b.png", strings.NewReader("already there"), 13, ""); err != nil {
+		t.Fatalf("s3 Put: %v", err)
+	}
+
+	res, err := migrate(ctx, local, s3, options{})
+	if err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	if res != (result{copied: 4, skipped: 1}) {
+		t.Fatalf("migrate = %+v, want 4 copied and 1 skipped", res)
+	}
+	for key, content := range files {
+		body, _, ok := srv.Object(key)
+		if key == "posts/b.png" {
+			content = "already there"
+		}
+		if !ok || string(body) != content {
+			t.Errorf("%s: got %q (present %v), want %q", key, body, ok, content)
+		}
+	}
+
+	// Une seconde passe avec -overwrite et -delete-source recopie posts/ puis vide la source
+	res, err = migrate(ctx, local, s3, options{deleteSource: true, overwrite: true, prefix: "posts/"})
+	if err != nil {
+		t.Fatalf("second migrate: %v", err)
+	}
+	if res != (result{copied: 2}) {
+		t.Fatalf("second migrate = %+v, want 2 copied", res)
+	}
+	if body, _, _ := srv.Object("posts/b.png"); string(body) != "post" {
+		t.Errorf("overwrite did not replace posts/b.png: %q", body)
+	}
+	left, err := local.List(ctx, "posts/")
+	if err != nil {
+		t.Fatalf("local List: %v", err)
+	}
+	if len(left) != 0 {
+		t.Errorf("delete-source left %d files in posts/", len(left))
+	}
+}
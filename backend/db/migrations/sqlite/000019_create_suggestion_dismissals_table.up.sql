@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS suggestion_dismissals (
+    user_id INTEGER NOT NULL,
+    dismissed_id INTEGER NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+
+    PRIMARY KEY (user_id, dismissed_id),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (dismissed_id) REFERENCES users(id) ON DELETE CASCADE
+);
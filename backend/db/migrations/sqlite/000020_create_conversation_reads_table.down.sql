@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_messages_to_from;
+DROP TABLE IF EXISTS conversation_reads;
@@ -0,0 +1,14 @@
+-- Position de lecture de chaque utilisateur dans chaque conversation privée :
+-- tous les messages de other_id vers user_id jusqu'à last_read_message_id sont lus
+CREATE TABLE IF NOT EXISTS conversation_reads (
+    user_id INTEGER NOT NULL,
+    other_id INTEGER NOT NULL,
+    last_read_message_id INTEGER NOT NULL DEFAULT 0,
+    read_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+
+    PRIMARY KEY (user_id, other_id),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (other_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_messages_to_from ON messages(to_id, from_id, id);
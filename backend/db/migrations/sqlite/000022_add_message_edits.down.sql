@@ -0,0 +1,8 @@
+DROP TABLE IF EXISTS message_edits;
+
+ALTER TABLE group_messages DROP COLUMN deleted_by;
+ALTER TABLE group_messages DROP COLUMN deleted_at;
+ALTER TABLE group_messages DROP COLUMN edited_at;
+
+ALTER TABLE messages DROP COLUMN deleted_at;
+ALTER TABLE messages DROP COLUMN edited_at;
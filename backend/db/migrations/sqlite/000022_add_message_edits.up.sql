@@ -0,0 +1,20 @@
+ALTER TABLE messages ADD COLUMN edited_at DATETIME;
+ALTER TABLE messages ADD COLUMN deleted_at DATETIME;
+
+ALTER TABLE group_messages ADD COLUMN edited_at DATETIME;
+ALTER TABLE group_messages ADD COLUMN deleted_at DATETIME;
+ALTER TABLE group_messages ADD COLUMN deleted_by INTEGER REFERENCES users(id);
+
+-- Historique des modifications : le contenu précédent de chaque message édité
+CREATE TABLE IF NOT EXISTS message_edits (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    conversation TEXT NOT NULL CHECK (conversation IN ('private', 'group')),
+    message_id INTEGER NOT NULL,
+    previous_content TEXT NOT NULL,
+    edited_by INTEGER NOT NULL,
+    edited_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+
+    FOREIGN KEY (edited_by) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_message_edits_message ON message_edits(conversation, message_id);
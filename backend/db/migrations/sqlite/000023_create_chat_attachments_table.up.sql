@@ -0,0 +1,17 @@
+-- Pièces jointes du chat : uploadées d'abord (message_id NULL), puis rattachées à un message
+CREATE TABLE IF NOT EXISTS chat_attachments (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    media_id INTEGER NOT NULL,
+    uploader_id INTEGER NOT NULL,
+    kind TEXT NOT NULL CHECK (kind IN ('image', 'audio', 'file')),
+    file_name TEXT NOT NULL DEFAULT '',
+    conversation TEXT CHECK (conversation IN ('private', 'group')),
+    message_id INTEGER,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+
+    FOREIGN KEY (media_id) REFERENCES media(id) ON DELETE CASCADE,
+    FOREIGN KEY (uploader_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_chat_attachments_message ON chat_attachments(conversation, message_id);
+CREATE INDEX IF NOT EXISTS idx_chat_attachments_media ON chat_attachments(media_id);
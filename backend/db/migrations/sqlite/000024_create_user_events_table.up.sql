@@ -0,0 +1,21 @@
+-- Dernier numéro de séquence attribué à chaque utilisateur (ne recule jamais, même après purge du journal)
+CREATE TABLE IF NOT EXISTS user_event_seqs (
+    user_id INTEGER PRIMARY KEY,
+    last_seq INTEGER NOT NULL DEFAULT 0,
+
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- Journal des événements temps réel envoyés à chaque utilisateur, rejoués à la reconnexion (/ws?since=)
+CREATE TABLE IF NOT EXISTS user_events (
+    user_id INTEGER NOT NULL,
+    seq INTEGER NOT NULL,
+    type TEXT NOT NULL,
+    payload TEXT NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+
+    PRIMARY KEY (user_id, seq),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_events_created_at ON user_events(created_at);
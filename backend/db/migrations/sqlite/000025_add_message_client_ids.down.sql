@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_group_messages_client_id;
+DROP INDEX IF EXISTS idx_messages_client_id;
+
+ALTER TABLE group_messages DROP COLUMN client_id;
+ALTER TABLE messages DROP COLUMN client_id;
@@ -0,0 +1,7 @@
+-- Identifiant choisi par le client pour un envoi : un renvoi avec le même client_id
+-- ne crée pas de second message
+ALTER TABLE messages ADD COLUMN client_id TEXT;
+ALTER TABLE group_messages ADD COLUMN client_id TEXT;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_messages_client_id ON messages(from_id, client_id) WHERE client_id IS NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_group_messages_client_id ON group_messages(sender_id, client_id) WHERE client_id IS NOT NULL;
@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS message_reactions;
+
+DROP INDEX IF EXISTS idx_group_messages_reply_to;
+
+ALTER TABLE group_messages DROP COLUMN reply_to;
+ALTER TABLE messages DROP COLUMN reply_to;
@@ -0,0 +1,20 @@
+-- Réponses : le message auquel répond un message privé ou de groupe
+ALTER TABLE messages ADD COLUMN reply_to INTEGER;
+ALTER TABLE group_messages ADD COLUMN reply_to INTEGER;
+
+CREATE INDEX IF NOT EXISTS idx_group_messages_reply_to ON group_messages(reply_to) WHERE reply_to IS NOT NULL;
+
+-- Réactions : un emoji par ligne, chaque utilisateur ne met qu'une fois le même emoji sur un message
+CREATE TABLE IF NOT EXISTS message_reactions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    conversation TEXT NOT NULL CHECK (conversation IN ('private', 'group')),
+    message_id INTEGER NOT NULL,
+    user_id INTEGER NOT NULL,
+    emoji TEXT NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    UNIQUE (conversation, message_id, user_id, emoji)
+);
+
+CREATE INDEX IF NOT EXISTS idx_message_reactions_message ON message_reactions(conversation, message_id);
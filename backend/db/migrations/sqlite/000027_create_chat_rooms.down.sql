@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS chat_room_messages;
+DROP TABLE IF EXISTS chat_room_participants;
+DROP TABLE IF EXISTS chat_rooms;
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_message_requests_recipient;
+DROP TABLE IF EXISTS message_requests;
+ALTER TABLE users DROP COLUMN dm_policy;
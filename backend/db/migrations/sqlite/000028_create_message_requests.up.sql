@@ -0,0 +1,19 @@
+-- Qui peut écrire en privé à l'utilisateur : everyone, followers, mutuals ou nobody.
+-- NULL = réglage par défaut (followers pour un compte privé, everyone sinon)
+ALTER TABLE users ADD COLUMN dm_policy TEXT CHECK (dm_policy IN ('everyone', 'followers', 'mutuals', 'nobody'));
+
+-- Demandes de message : le premier message à quelqu'un avec qui on n'est pas en relation attend
+-- que le destinataire l'accepte (ou l'ignore ; bloquer supprime la demande)
+CREATE TABLE IF NOT EXISTS message_requests (
+    sender_id INTEGER NOT NULL,
+    recipient_id INTEGER NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'accepted', 'ignored')),
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+
+    PRIMARY KEY (sender_id, recipient_id),
+    FOREIGN KEY (sender_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (recipient_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_message_requests_recipient ON message_requests(recipient_id, status);
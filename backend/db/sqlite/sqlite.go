@@ -17,7 +17,8 @@ var DB *sql.DB
 func InitDB() {
 	var err error
 	os.MkdirAll("./data", 0755)
-	DB, err = sql.Open("sqlite3", "./data/social.db?charset=utf8")
+	// busy_timeout : plusieurs instances du backend peuvent écrire dans la même base
+	DB, err = sql.Open("sqlite3", "./data/social.db?charset=utf8&_busy_timeout=5000")
 	if err != nil {
 		log.Fatal("Failed to open DB:", err)
 	}
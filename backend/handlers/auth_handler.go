@@ -12,12 +12,13 @@ import (
 
 type Handler struct {
 	authService    *services.AuthService
+	mediaService   *services.MediaService
 	sessionService *services.SessionService
 	Hub            *hub.Hub
 }
 
-func NewHandler(service *services.AuthService, sessionService *services.SessionService, hub *hub.Hub) *Handler {
-	return &Handler{authService: service, sessionService: sessionService, Hub: hub}
+func NewHandler(service *services.AuthService, mediaService *services.MediaService, sessionService *services.SessionService, hub *hub.Hub) *Handler {
+	return &Handler{authService: service, mediaService: mediaService, sessionService: sessionService, Hub: hub}
 }
 
 func (h *Handler) RegisterHandler(w http.ResponseWriter, r *http.Request) {
@@ -42,20 +43,29 @@ func (h *Handler) RegisterHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Upload avatar optionnel
-	avatarPath, err := utils.HandleOptionalFileUpload(
+	avatar, err := utils.HandleOptionalMediaUpload(
 		r,
 		"avatar",
 		utils.DefaultImageUploadConfig("uploads/avatars"),
 	)
 	if err != nil {
-		utils.WriteError(w, http.StatusBadRequest, "Failed to upload avatar: "+err.Error())
+		utils.WriteError(w, utils.UploadErrorStatus(err), "Failed to upload avatar: "+err.Error())
 		return
 	}
-	form.Avatar = avatarPath
+	if avatar != nil {
+		if err := h.mediaService.RecordUpload(0, avatar); err != nil {
+			fmt.Println("❌ Failed to record avatar metadata:", err)
+			h.mediaService.DiscardUpload(r.Context(), avatar)
+			utils.WriteError(w, http.StatusInternalServerError, "Failed to save avatar")
+			return
+		}
+		form.Avatar = avatar.Path
+	}
 
 	// Déléguer au service
 	err = h.authService.Register(form)
 	if err != nil {
+		h.mediaService.DiscardUpload(r.Context(), avatar)
 		utils.WriteError(w, http.StatusConflict, "Could not register: "+err.Error())
 		return
 	}
@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"social/models"
+	"social/services"
+	"social/utils"
+)
+
+type BlockHandler struct {
+	Service *services.BlockService
+}
+
+func NewBlockHandler(s *services.BlockService) *BlockHandler {
+	return &BlockHandler{Service: s}
+}
+
+// BlocksHandler gère /api/blocks : GET liste les utilisateurs bloqués, POST en bloque un
+func (h *BlockHandler) BlocksHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		h.GetBlockedUsers(w, r)
+	case http.MethodPost:
+		h.BlockUser(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *BlockHandler) GetBlockedUsers(w http.ResponseWriter, r *http.Request) {
+	userID, ok := utils.GetUserIDFromContext(r.Context())
+	if !ok {
+		utils.WriteError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	users, err := h.Service.GetBlockedUsers(userID)
+	if err != nil {
+		fmt.Println("❌ Failed to fetch blocked users:", err)
+		utils.WriteError(w, http.StatusInternalServerError, "Failed to fetch blocked users")
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, users)
+}
+
+func (h *BlockHandler) BlockUser(w http.ResponseWriter, r *http.Request) {
+	userID, ok := utils.GetUserIDFromContext(r.Context())
+	if !ok {
+		utils.WriteError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var req models.BlockRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.UserID <= 0 {
+		utils.WriteError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	err := h.Service.BlockUser(userID, req.UserID)
+	switch {
+	case errors.Is(err, services.ErrCannotBlock):
+		utils.WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	case errors.Is(err, services.ErrUserNotFound):
+		utils.WriteError(w, http.StatusNotFound, "User not found")
+		return
+	case err != nil:
+		fmt.Println("❌ Failed to block user:", err)
+		utils.WriteError(w, http.StatusInternalServerError, "Failed to block user")
+		return
+	}
+
+	utils.WriteSuccess(w, "User blocked")
+}
+
+// UnblockUser gère DELETE /api/blocks/{id}
+func (h *BlockHandler) UnblockUser(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := utils.GetUserIDFromContext(r.Context())
+	if !ok {
+		utils.WriteError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	blockedID, err := utils.ExtractIDFromPath(r.URL.Path, "/api/blocks/", "")
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	if err := h.Service.UnblockUser(userID, blockedID); err != nil {
+		fmt.Println("❌ Failed to unblock user:", err)
+		utils.WriteError(w, http.StatusInternalServerError, "Failed to unblock user")
+		return
+	}
+
+	utils.WriteSuccess(w, "User unblocked")
+}
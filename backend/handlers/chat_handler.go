@@ -1,21 +1,33 @@
 package handlers
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"strings"
 
+	"social/hub"
+	"social/models"
 	"social/services"
 	"social/utils"
 )
 
 type ChatHandler struct {
 	Service *services.ChatService
+	Media   *services.MediaService
 	Session *services.SessionService
+	Limiter *services.RateLimitService
+	Hub     *hub.Hub
 }
 
-func NewChatHandler(chatService *services.ChatService, sessionService *services.SessionService) *ChatHandler {
+func NewChatHandler(chatService *services.ChatService, mediaService *services.MediaService, sessionService *services.SessionService, limiter *services.RateLimitService, hub *hub.Hub) *ChatHandler {
 	return &ChatHandler{
 		Service: chatService,
+		Media:   mediaService,
 		Session: sessionService,
+		Limiter: limiter,
+		Hub:     hub,
 	}
 }
 
@@ -53,7 +65,11 @@ func (h *ChatHandler) GetChatHistory(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	messages, err := h.Service.GetChatHistory(userID, otherID)
+	// Pagination par ID : before = ID du plus ancien message déjà affiché
+	before := utils.ExtractQueryIntWithDefault(r, "before", 0)
+	limit := utils.ExtractQueryIntWithDefault(r, "limit", 0)
+
+	messages, err := h.Service.GetChatHistory(userID, otherID, before, limit)
 	if err != nil {
 		if err.Error() == "chat not allowed: users must follow each other" {
 			utils.WriteError(w, http.StatusForbidden, err.Error())
@@ -63,5 +79,195 @@ func (h *ChatHandler) GetChatHistory(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if messages == nil {
+		messages = []models.Message{}
+	}
+
 	utils.WriteJSON(w, http.StatusOK, messages)
-}
\ No newline at end of file
+}
+
+// GetConversations gère GET /api/conversations?before=&limit= : les discussions privées de l'utilisateur,
+// de la plus récemment active à la plus ancienne
+func (h *ChatHandler) GetConversations(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		utils.WriteError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	userID, ok := utils.GetUserIDFromContext(r.Context())
+	if !ok {
+		utils.WriteError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	before := utils.ExtractQueryIntWithDefault(r, "before", 0)
+	limit := utils.ExtractQueryIntWithDefault(r, "limit", 0)
+
+	page, err := h.Service.GetConversations(userID, before, limit)
+	if err != nil {
+		fmt.Println("❌ Error fetching conversations:", err)
+		utils.WriteError(w, http.StatusInternalServerError, "Failed to fetch conversations")
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, page)
+}
+
+// MessageHandler gère /api/chat/messages/{id} : PUT modifie le message privé, DELETE le supprime.
+// La modification est diffusée en direct aux deux participants.
+func (h *ChatHandler) MessageHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := utils.GetUserIDFromContext(r.Context())
+	if !ok {
+		utils.WriteError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	if strings.HasSuffix(r.URL.Path, "/reactions") {
+		h.reactionsHandler(w, r, userID)
+		return
+	}
+
+	messageID, err := utils.ExtractIDFromPath(r.URL.Path, "/api/chat/messages/", "")
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, "Invalid message ID")
+		return
+	}
+
+	var update models.MessageUpdate
+	switch r.Method {
+	case http.MethodPut:
+		var req struct {
+			Content string `json:"content"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			utils.WriteError(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+		if h.Limiter != nil && !utils.CheckRateLimitContent(w, h.Limiter, userID, services.ActionMessage, req.Content) {
+			return
+		}
+		update, err = h.Hub.EditMessage(userID, messageID, 0, req.Content)
+	case http.MethodDelete:
+		update, err = h.Hub.DeleteMessage(userID, messageID, 0)
+	default:
+		utils.WriteError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	if err != nil {
+		writeMessageUpdateError(w, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, update)
+}
+
+// reactionsHandler gère /api/chat/messages/{id}/reactions : POST ajoute l'emoji {"emoji": "👍"}
+// de l'utilisateur au message privé, DELETE le retire. Les deux participants sont prévenus en direct.
+func (h *ChatHandler) reactionsHandler(w http.ResponseWriter, r *http.Request, userID int) {
+	messageID, err := utils.ExtractIDFromPath(r.URL.Path, "/api/chat/messages/", "/reactions")
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, "Invalid message ID")
+		return
+	}
+	if r.Method != http.MethodPost && r.Method != http.MethodDelete {
+		utils.WriteError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req struct {
+		Emoji string `json:"emoji"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	// Le même emoji sur plusieurs messages n'est pas un doublon : seul le budget compte
+	if h.Limiter != nil && !utils.CheckRateLimit(w, h.Limiter, userID, services.ActionMessage) {
+		return
+	}
+
+	update, err := h.Hub.React(userID, messageID, 0, req.Emoji, r.Method == http.MethodPost)
+	if err != nil {
+		writeMessageUpdateError(w, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, update)
+}
+
+// writeMessageUpdateError traduit les erreurs de modification / suppression de message en statut HTTP
+func writeMessageUpdateError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, services.ErrMessageNotFound):
+		utils.WriteError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, services.ErrNotMessageSender), errors.Is(err, services.ErrUserBlocked):
+		utils.WriteError(w, http.StatusForbidden, err.Error())
+	case errors.Is(err, services.ErrEditWindowExpired):
+		utils.WriteError(w, http.StatusConflict, err.Error())
+	case errors.Is(err, services.ErrEmptyMessage),
+		errors.Is(err, services.ErrInvalidEmoji),
+		errors.Is(err, services.ErrTooManyReactions):
+		utils.WriteError(w, http.StatusBadRequest, err.Error())
+	default:
+		utils.WriteError(w, http.StatusInternalServerError, "Failed to update message")
+	}
+}
+
+// UploadAttachment gère POST /api/chat/attachments (champ multipart "file").
+// La pièce jointe retournée est ensuite référencée par son ID dans attachment_ids d'un
+// message WebSocket ; seuls les participants de la conversation pourront la télécharger.
+func (h *ChatHandler) UploadAttachment(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		utils.WriteError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	userID, ok := utils.GetUserIDFromContext(r.Context())
+	if !ok {
+		utils.WriteError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	if err := utils.ParseMultipartFormSafe(r, 10<<20); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, "Invalid form")
+		return
+	}
+
+	uploadConfig, err := utils.ChatAttachmentUploadConfig(r, "file", "uploads/chat", h.Service.AttachmentMaxSize)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, "Failed to upload attachment: "+err.Error())
+		return
+	}
+	uploadConfig.Quota = h.Media.QuotaCheck(userID)
+
+	media, err := utils.SaveUpload(r, "file", uploadConfig)
+	if err != nil {
+		utils.WriteError(w, utils.UploadErrorStatus(err), "Failed to upload attachment: "+err.Error())
+		return
+	}
+
+	// Le média doit être enregistré : la pièce jointe le référence par son ID
+	if err := h.Media.RecordUpload(userID, media); err != nil {
+		fmt.Println("❌ Error recording attachment metadata:", err)
+		h.Media.DiscardUpload(r.Context(), media)
+		utils.WriteError(w, http.StatusInternalServerError, "Failed to save attachment")
+		return
+	}
+
+	_, header, _ := r.FormFile("file")
+	fileName := ""
+	if header != nil {
+		fileName = header.Filename
+	}
+
+	attachment, err := h.Service.CreateAttachment(userID, media, fileName)
+	if err != nil {
+		fmt.Println("❌ Error creating attachment:", err)
+		h.Media.DiscardUpload(r.Context(), media)
+		utils.WriteError(w, http.StatusInternalServerError, "Failed to save attachment")
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusCreated, attachment)
+}
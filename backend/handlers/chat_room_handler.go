@@ -0,0 +1,344 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"social/hub"
+	"social/models"
+	"social/services"
+	"social/utils"
+)
+
+type ChatRoomHandler struct {
+	Service *services.ChatRoomService
+	Limiter *services.RateLimitService
+	Hub     *hub.Hub
+}
+
+func NewChatRoomHandler(service *services.ChatRoomService, limiter *services.RateLimitService, hub *hub.Hub) *ChatRoomHandler {
+	return &ChatRoomHandler{Service: service, Limiter: limiter, Hub: hub}
+}
+
+// RoomsHandler gère /api/chat/rooms : GET liste les discussions à plusieurs de l'utilisateur, POST en crée une
+func (h *ChatRoomHandler) RoomsHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		h.GetRooms(w, r)
+	case http.MethodPost:
+		h.CreateRoom(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// RoomRouterHandler gère /api/chat/rooms/{id}[/messages | /leave | /participants[/{userID}]]
+func (h *ChatRoomHandler) RoomRouterHandler(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/chat/rooms/"), "/"), "/")
+	roomID, err := strconv.Atoi(parts[0])
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, "Invalid room ID")
+		return
+	}
+	suffix := strings.Join(parts[1:], "/")
+
+	switch {
+	case suffix == "" && r.Method == http.MethodGet:
+		h.GetRoom(w, r, roomID)
+	case suffix == "" && r.Method == http.MethodPut:
+		h.RenameRoom(w, r, roomID)
+	case suffix == "messages" && r.Method == http.MethodGet:
+		h.GetHistory(w, r, roomID)
+	case suffix == "messages" && r.Method == http.MethodPost:
+		h.SendMessage(w, r, roomID)
+	case suffix == "leave" && r.Method == http.MethodPost:
+		h.Leave(w, r, roomID)
+	case suffix == "participants" && r.Method == http.MethodPost:
+		h.AddParticipants(w, r, roomID)
+	case len(parts) == 3 && parts[1] == "participants":
+		userID, err := strconv.Atoi(parts[2])
+		if err != nil {
+			utils.WriteError(w, http.StatusBadRequest, "Invalid user ID")
+			return
+		}
+		switch r.Method {
+		case http.MethodDelete:
+			h.RemoveParticipant(w, r, roomID, userID)
+		case http.MethodPut:
+			h.SetRole(w, r, roomID, userID)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (h *ChatRoomHandler) GetRooms(w http.ResponseWriter, r *http.Request) {
+	userID, ok := utils.GetUserIDFromContext(r.Context())
+	if !ok {
+		utils.WriteError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	rooms, err := h.Service.GetRooms(userID)
+	if err != nil {
+		fmt.Println("❌ Failed to fetch chat rooms:", err)
+		utils.WriteError(w, http.StatusInternalServerError, "Failed to fetch chat rooms")
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, rooms)
+}
+
+func (h *ChatRoomHandler) CreateRoom(w http.ResponseWriter, r *http.Request) {
+	userID, ok := utils.GetUserIDFromContext(r.Context())
+	if !ok {
+		utils.WriteError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var req models.CreateRoomRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	event, err := h.Service.CreateRoom(userID, req)
+	if err != nil {
+		writeRoomError(w, err)
+		return
+	}
+
+	h.Hub.SendRoomEvent(event)
+	utils.WriteCreated(w, event.Room)
+}
+
+func (h *ChatRoomHandler) GetRoom(w http.ResponseWriter, r *http.Request, roomID int) {
+	userID, ok := utils.GetUserIDFromContext(r.Context())
+	if !ok {
+		utils.WriteError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	room, err := h.Service.GetRoom(roomID, userID)
+	if err != nil {
+		writeRoomError(w, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, room)
+}
+
+// RenameRoom gère PUT /api/chat/rooms/{id} {"title": "..."} (admins)
+func (h *ChatRoomHandler) RenameRoom(w http.ResponseWriter, r *http.Request, roomID int) {
+	userID, ok := utils.GetUserIDFromContext(r.Context())
+	if !ok {
+		utils.WriteError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var req struct {
+		Title string `json:"title"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	event, err := h.Service.Rename(roomID, userID, req.Title)
+	if err != nil {
+		writeRoomError(w, err)
+		return
+	}
+
+	h.Hub.SendRoomEvent(event)
+	utils.WriteJSON(w, http.StatusOK, event.Room)
+}
+
+// GetHistory gère GET /api/chat/rooms/{id}/messages?before=&limit=, paginé comme /api/chat/history
+func (h *ChatRoomHandler) GetHistory(w http.ResponseWriter, r *http.Request, roomID int) {
+	userID, ok := utils.GetUserIDFromContext(r.Context())
+	if !ok {
+		utils.WriteError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	before := utils.ExtractQueryIntWithDefault(r, "before", 0)
+	limit := utils.ExtractQueryIntWithDefault(r, "limit", 0)
+
+	messages, err := h.Service.GetHistory(roomID, userID, before, limit)
+	if err != nil {
+		writeRoomError(w, err)
+		return
+	}
+	if messages == nil {
+		messages = []models.Message{}
+	}
+
+	utils.WriteJSON(w, http.StatusOK, messages)
+}
+
+// SendMessage gère POST /api/chat/rooms/{id}/messages {"content", "client_id", "reply_to"} : même traitement
+// qu'un room_message reçu par WebSocket, livré en direct aux participants
+func (h *ChatRoomHandler) SendMessage(w http.ResponseWriter, r *http.Request, roomID int) {
+	userID, ok := utils.GetUserIDFromContext(r.Context())
+	if !ok {
+		utils.WriteError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	var req struct {
+		Content  string `json:"content"`
+		ClientID string `json:"client_id"`
+		ReplyTo  int    `json:"reply_to"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if h.Limiter != nil && !utils.CheckRateLimitContent(w, h.Limiter, userID, services.ActionMessage, req.Content) {
+		return
+	}
+
+	msg := models.Message{
+		Type:      "room_message",
+		From:      userID,
+		RoomID:    roomID,
+		Content:   req.Content,
+		ClientID:  req.ClientID,
+		ReplyTo:   req.ReplyTo,
+		Timestamp: time.Now().Format(time.RFC3339),
+	}
+	ack, err := h.Hub.SendRoomMessage(&msg)
+	if err != nil {
+		writeRoomError(w, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, ack)
+}
+
+// AddParticipants gère POST /api/chat/rooms/{id}/participants {"user_ids": [...]} (admins)
+func (h *ChatRoomHandler) AddParticipants(w http.ResponseWriter, r *http.Request, roomID int) {
+	userID, ok := utils.GetUserIDFromContext(r.Context())
+	if !ok {
+		utils.WriteError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var req struct {
+		UserIDs []int `json:"user_ids"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.UserIDs) == 0 {
+		utils.WriteError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	event, err := h.Service.AddParticipants(roomID, userID, req.UserIDs)
+	if err != nil {
+		writeRoomError(w, err)
+		return
+	}
+	if event.Room == nil {
+		utils.WriteSuccess(w, "Already participants")
+		return
+	}
+
+	h.Hub.SendRoomEvent(event)
+	utils.WriteJSON(w, http.StatusOK, event.Room)
+}
+
+// RemoveParticipant gère DELETE /api/chat/rooms/{id}/participants/{userID} (admins, ou soi-même pour partir)
+func (h *ChatRoomHandler) RemoveParticipant(w http.ResponseWriter, r *http.Request, roomID, participantID int) {
+	userID, ok := utils.GetUserIDFromContext(r.Context())
+	if !ok {
+		utils.WriteError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	event, err := h.Service.RemoveParticipant(roomID, userID, participantID)
+	if err != nil {
+		writeRoomError(w, err)
+		return
+	}
+
+	h.Hub.SendRoomEvent(event)
+	utils.WriteSuccess(w, "Participant removed")
+}
+
+// SetRole gère PUT /api/chat/rooms/{id}/participants/{userID} {"role": "admin" | "member"} (créateur)
+func (h *ChatRoomHandler) SetRole(w http.ResponseWriter, r *http.Request, roomID, participantID int) {
+	userID, ok := utils.GetUserIDFromContext(r.Context())
+	if !ok {
+		utils.WriteError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var req struct {
+		Role string `json:"role"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	event, err := h.Service.SetRole(roomID, userID, participantID, req.Role)
+	if err != nil {
+		writeRoomError(w, err)
+		return
+	}
+	if event.Room != nil {
+		h.Hub.SendRoomEvent(event)
+	}
+
+	utils.WriteSuccess(w, "Role updated")
+}
+
+// Leave gère POST /api/chat/rooms/{id}/leave
+func (h *ChatRoomHandler) Leave(w http.ResponseWriter, r *http.Request, roomID int) {
+	userID, ok := utils.GetUserIDFromContext(r.Context())
+	if !ok {
+		utils.WriteError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	event, err := h.Service.Leave(roomID, userID)
+	if err != nil {
+		writeRoomError(w, err)
+		return
+	}
+
+	h.Hub.SendRoomEvent(event)
+	utils.WriteSuccess(w, "Left the chat room")
+}
+
+// writeRoomError traduit les erreurs des discussions à plusieurs en statut HTTP
+func writeRoomError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, services.ErrRoomNotFound), errors.Is(err, services.ErrReplyNotFound):
+		utils.WriteError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, services.ErrUserNotFound):
+		utils.WriteError(w, http.StatusNotFound, "User is not a participant")
+	case errors.Is(err, services.ErrNotRoomAdmin),
+		errors.Is(err, services.ErrNotRoomCreator),
+		errors.Is(err, services.ErrCannotRemoveAdmin),
+		errors.Is(err, services.ErrChatNotAllowed),
+		errors.Is(err, services.ErrUserBlocked):
+		utils.WriteError(w, http.StatusForbidden, err.Error())
+	case errors.Is(err, services.ErrRoomTooSmall),
+		errors.Is(err, services.ErrRoomFull),
+		errors.Is(err, services.ErrInvalidRoomTitle),
+		errors.Is(err, services.ErrInvalidRoomRole),
+		errors.Is(err, services.ErrEmptyMessage),
+		errors.Is(err, services.ErrInvalidClientID):
+		utils.WriteError(w, http.StatusBadRequest, err.Error())
+	default:
+		fmt.Println("❌ Chat room error:", err)
+		utils.WriteError(w, http.StatusInternalServerError, "Internal server error")
+	}
+}
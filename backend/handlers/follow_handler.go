@@ -2,10 +2,12 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 
 	"social/hub"
+	"social/models"
 	"social/services"
 	"social/utils"
 )
@@ -42,6 +44,10 @@ func (h *FollowHandler) SendFollowRequest(w http.ResponseWriter, r *http.Request
 	}
 
 	notification, status, err := h.Service.SendFollowRequest(userID, req.FollowedID)
+	if errors.Is(err, services.ErrUserBlocked) {
+		utils.WriteError(w, http.StatusForbidden, "You cannot follow this user")
+		return
+	}
 	if err != nil {
 		utils.WriteError(w, http.StatusInternalServerError, "Error sending follow request")
 		return
@@ -101,11 +107,18 @@ func (h *FollowHandler) AcceptFollow(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := h.Service.AcceptFollowRequest(req.SenderID, userID); err != nil {
+	err := h.Service.AcceptFollowRequest(req.SenderID, userID)
+	if errors.Is(err, services.ErrFollowRequestNotFound) {
+		utils.WriteError(w, http.StatusNotFound, "Follow request not found")
+		return
+	}
+	if err != nil {
 		utils.WriteError(w, http.StatusInternalServerError, "Database error")
 		return
 	}
 
+	h.notifyFollowResponse(req.SenderID, userID, "accepted")
+
 	utils.WriteSuccess(w, "Follow accepted")
 }
 
@@ -129,12 +142,19 @@ func (h *FollowHandler) RejectFollow(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := h.Service.RejectFollowRequest(req.SenderID, userID); err != nil {
+	err := h.Service.RejectFollowRequest(req.SenderID, userID)
+	if errors.Is(err, services.ErrFollowRequestNotFound) {
+		utils.WriteError(w, http.StatusNotFound, "Follow request not found")
+		return
+	}
+	if err != nil {
 		fmt.Println("error 1 : ", err)
 		utils.WriteError(w, http.StatusInternalServerError, "Database error")
 		return
 	}
 
+	h.notifyFollowResponse(req.SenderID, userID, "rejected")
+
 	utils.WriteSuccess(w, "Follow rejected")
 }
 
@@ -155,12 +175,17 @@ func (h *FollowHandler) UnfollowUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := h.Service.UnfollowUser(sessionUserID, payload.FollowedID); err != nil {
+	cancelled, err := h.Service.UnfollowUser(sessionUserID, payload.FollowedID)
+	if err != nil {
 		fmt.Println("called : ", err)
 		utils.WriteError(w, http.StatusInternalServerError, "Error unfollowing user")
 		return
 	}
 
+	if cancelled {
+		h.notifyRequestCancelled(sessionUserID, payload.FollowedID)
+	}
+
 	utils.WriteSuccess(w, "Unfollowed successfully")
 }
 
@@ -171,7 +196,21 @@ func (h *FollowHandler) GetFollowersHandler(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	followers, err := h.Service.GetFollowers(userID)
+	viewerID, ok := utils.GetUserIDFromContext(r.Context())
+	if !ok {
+		utils.WriteError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	followers, err := h.Service.GetFollowers(viewerID, userID)
+	if errors.Is(err, services.ErrUserBlocked) || errors.Is(err, services.ErrUserNotFound) {
+		utils.WriteError(w, http.StatusNotFound, "User not found")
+		return
+	}
+	if errors.Is(err, services.ErrPrivateAccount) {
+		utils.WriteError(w, http.StatusForbidden, err.Error())
+		return
+	}
 	if err != nil {
 		utils.WriteError(w, http.StatusInternalServerError, "DB error")
 		return
@@ -187,7 +226,21 @@ func (h *FollowHandler) GetFollowingHandler(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	following, err := h.Service.GetFollowing(userID)
+	viewerID, ok := utils.GetUserIDFromContext(r.Context())
+	if !ok {
+		utils.WriteError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	following, err := h.Service.GetFollowing(viewerID, userID)
+	if errors.Is(err, services.ErrUserBlocked) || errors.Is(err, services.ErrUserNotFound) {
+		utils.WriteError(w, http.StatusNotFound, "User not found")
+		return
+	}
+	if errors.Is(err, services.ErrPrivateAccount) {
+		utils.WriteError(w, http.StatusForbidden, err.Error())
+		return
+	}
 	if err != nil {
 		utils.WriteError(w, http.StatusInternalServerError, "DB error")
 		return
@@ -210,4 +263,193 @@ func (h *FollowHandler) GetRecipientsHandler(w http.ResponseWriter, r *http.Requ
 	}
 
 	utils.WriteJSON(w, http.StatusOK, recipients)
-}
\ No newline at end of file
+}
+
+// FollowRequestsHandler gère /api/follow/requests :
+// GET ?direction=incoming|outgoing&limit=&offset= liste les demandes en attente
+func (h *FollowHandler) FollowRequestsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := utils.GetUserIDFromContext(r.Context())
+	if !ok {
+		utils.WriteError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	direction := utils.ExtractQueryStringWithDefault(r, "direction", "incoming")
+	limit := utils.ExtractQueryIntWithDefault(r, "limit", 0)
+	offset := utils.ExtractQueryIntWithDefault(r, "offset", 0)
+
+	page, err := h.Service.GetFollowRequests(userID, direction, limit, offset)
+	if errors.Is(err, services.ErrInvalidDirection) {
+		utils.WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		fmt.Println("❌ Failed to fetch follow requests:", err)
+		utils.WriteError(w, http.StatusInternalServerError, "Failed to fetch follow requests")
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, page)
+}
+
+// FollowRequestsRouterHandler gère /api/follow/requests/... :
+// POST accept et reject (en masse), DELETE {id} annule une demande envoyée
+func (h *FollowHandler) FollowRequestsRouterHandler(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.URL.Path == "/api/follow/requests/accept":
+		h.respondToFollowRequests(w, r, true)
+	case r.URL.Path == "/api/follow/requests/reject":
+		h.respondToFollowRequests(w, r, false)
+	default:
+		h.CancelFollowRequest(w, r)
+	}
+}
+
+func (h *FollowHandler) respondToFollowRequests(w http.ResponseWriter, r *http.Request, accept bool) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := utils.GetUserIDFromContext(r.Context())
+	if !ok {
+		utils.WriteError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var req models.BulkFollowActionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	processed, err := h.Service.RespondToFollowRequests(userID, req.SenderIDs, accept)
+	if errors.Is(err, services.ErrInvalidBulkFollowAction) {
+		utils.WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		fmt.Println("❌ Failed to process follow requests:", err)
+		utils.WriteError(w, http.StatusInternalServerError, "Failed to process follow requests")
+		return
+	}
+
+	action := "rejected"
+	if accept {
+		action = "accepted"
+	}
+	for _, senderID := range processed {
+		h.notifyFollowResponse(senderID, userID, action)
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"action":    action,
+		"processed": processed,
+	})
+}
+
+// CancelFollowRequest gère DELETE /api/follow/requests/{id} : annule la demande envoyée à {id}
+func (h *FollowHandler) CancelFollowRequest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := utils.GetUserIDFromContext(r.Context())
+	if !ok {
+		utils.WriteError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	followedID, err := utils.ExtractIDFromPath(r.URL.Path, "/api/follow/requests/", "")
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	err = h.Service.CancelFollowRequest(userID, followedID)
+	if errors.Is(err, services.ErrFollowRequestNotFound) {
+		utils.WriteError(w, http.StatusNotFound, "Follow request not found")
+		return
+	}
+	if err != nil {
+		fmt.Println("❌ Failed to cancel follow request:", err)
+		utils.WriteError(w, http.StatusInternalServerError, "Failed to cancel follow request")
+		return
+	}
+
+	h.notifyRequestCancelled(userID, followedID)
+	utils.WriteSuccess(w, "Follow request cancelled")
+}
+
+// RemoveFollower gère DELETE /api/followers/{id} : {id} ne suit plus l'utilisateur connecté
+func (h *FollowHandler) RemoveFollower(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := utils.GetUserIDFromContext(r.Context())
+	if !ok {
+		utils.WriteError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	followerID, err := utils.ExtractIDFromPath(r.URL.Path, "/api/followers/", "")
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	err = h.Service.RemoveFollower(userID, followerID)
+	if errors.Is(err, services.ErrNotAFollower) {
+		utils.WriteError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		fmt.Println("❌ Failed to remove follower:", err)
+		utils.WriteError(w, http.StatusInternalServerError, "Failed to remove follower")
+		return
+	}
+
+	if h.hub != nil {
+		h.hub.SendEvent(followerID, models.FollowEvent{
+			Type:        "follow_status_update",
+			SenderID:    followerID,
+			RecipientID: userID,
+			Action:      "removed",
+		})
+	}
+
+	utils.WriteSuccess(w, "Follower removed")
+}
+
+// notifyFollowResponse prévient senderID que receiverID a répondu à sa demande
+func (h *FollowHandler) notifyFollowResponse(senderID, receiverID int, action string) {
+	if h.hub == nil {
+		return
+	}
+	h.hub.SendEvent(senderID, models.FollowEvent{
+		Type:        "follow_request_response",
+		SenderID:    senderID,
+		RecipientID: receiverID,
+		Action:      action,
+	})
+}
+
+// notifyRequestCancelled retire la demande de followerID de l'interface de followedID
+func (h *FollowHandler) notifyRequestCancelled(followerID, followedID int) {
+	if h.hub == nil {
+		return
+	}
+	h.hub.SendEvent(followedID, models.FollowEvent{
+		Type:        "follow_request_cancelled",
+		SenderID:    followerID,
+		RecipientID: followedID,
+	})
+}
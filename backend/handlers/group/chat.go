@@ -16,25 +16,43 @@ import (
 type ChatHandler struct {
 	Service *services.GroupService
 	Session *services.SessionService
+	Limiter *services.RateLimitService
 	Hub     *hub.Hub
 }
 
-func NewChatHandler(service *services.GroupService, session *services.SessionService, hub *hub.Hub) *ChatHandler {
+func NewChatHandler(service *services.GroupService, session *services.SessionService, limiter *services.RateLimitService, hub *hub.Hub) *ChatHandler {
 	return &ChatHandler{
 		Service: service,
 		Session: session,
+		Limiter: limiter,
 		Hub:     hub,
 	}
 }
 
-// GetHistory récupère l'historique des messages d'un groupe
+// GetHistory récupère l'historique des messages d'un groupe (membres uniquement)
 func (h *ChatHandler) GetHistory(w http.ResponseWriter, r *http.Request) {
+	userID, ok := utils.GetUserIDFromContext(r.Context())
+	if !ok {
+		utils.WriteError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
 	groupID, err := utils.ExtractIDFromPath(r.URL.Path, "/api/groups/", "/chat")
 	if err != nil {
 		utils.WriteError(w, http.StatusBadRequest, "Invalid group ID")
 		return
 	}
 
+	isMember, err := h.Service.IsGroupMember(groupID, userID)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, "Failed to check membership")
+		return
+	}
+	if !isMember {
+		utils.WriteError(w, http.StatusForbidden, "Not a member of the group")
+		return
+	}
+
 	limit := utils.ExtractQueryIntWithDefault(r, "limit", 50)
 
 	messages, err := h.Service.GetGroupChatHistory(groupID, limit)
@@ -88,6 +106,9 @@ func (h *ChatHandler) SendMessage(w http.ResponseWriter, r *http.Request) {
 		utils.WriteError(w, http.StatusBadRequest, "Message content cannot be empty")
 		return
 	}
+	if !allowContent(w, r, h.Limiter, services.ActionMessage, req.Content) {
+		return
+	}
 
 	msg, err := h.Service.SendGroupMessage(userID, groupID, req.Content)
 	if err != nil {
@@ -105,4 +126,160 @@ func (h *ChatHandler) SendMessage(w http.ResponseWriter, r *http.Request) {
 	}
 
 	utils.WriteSuccess(w, "Group message sent successfully")
-}
\ No newline at end of file
+}
+
+// UpdateMessage gère /api/groups/{id}/messages/{messageID} : PUT modifie le message (expéditeur seulement),
+// DELETE le supprime (expéditeur, ou créateur du groupe). Les membres connectés sont prévenus en direct.
+func (h *ChatHandler) UpdateMessage(w http.ResponseWriter, r *http.Request, method string) {
+	userID, ok := utils.GetUserIDFromContext(r.Context())
+	if !ok {
+		utils.WriteError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	// Path: /api/groups/{id}/messages/{messageID}
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	groupID, err := strconv.Atoi(parts[2])
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, "Invalid group ID")
+		return
+	}
+	messageID, err := strconv.Atoi(parts[4])
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, "Invalid message ID")
+		return
+	}
+
+	var update models.MessageUpdate
+	switch method {
+	case http.MethodPut:
+		var req struct {
+			Content string `json:"content"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			utils.WriteError(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+		if !allowContent(w, r, h.Limiter, services.ActionMessage, req.Content) {
+			return
+		}
+		update, err = h.Hub.EditMessage(userID, messageID, groupID, req.Content)
+	case http.MethodDelete:
+		update, err = h.Hub.DeleteMessage(userID, messageID, groupID)
+	default:
+		utils.WriteError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	if err != nil {
+		writeMessageError(w, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, update)
+}
+
+// Reactions gère /api/groups/{id}/messages/{messageID}/reactions : POST ajoute l'emoji {"emoji": "👍"}
+// du membre au message, DELETE le retire. Les membres connectés sont prévenus en direct.
+func (h *ChatHandler) Reactions(w http.ResponseWriter, r *http.Request, method string) {
+	userID, ok := utils.GetUserIDFromContext(r.Context())
+	if !ok {
+		utils.WriteError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	if method != http.MethodPost && method != http.MethodDelete {
+		utils.WriteError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	// Path: /api/groups/{id}/messages/{messageID}/reactions
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	groupID, err := strconv.Atoi(parts[2])
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, "Invalid group ID")
+		return
+	}
+	messageID, err := strconv.Atoi(parts[4])
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, "Invalid message ID")
+		return
+	}
+
+	var req struct {
+		Emoji string `json:"emoji"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	update, err := h.Hub.React(userID, messageID, groupID, req.Emoji, method == http.MethodPost)
+	if err != nil {
+		writeMessageError(w, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, update)
+}
+
+// GetThread gère GET /api/groups/{id}/messages/{messageID}/thread?before=&limit= : le message
+// et ses réponses (membres uniquement)
+func (h *ChatHandler) GetThread(w http.ResponseWriter, r *http.Request) {
+	userID, ok := utils.GetUserIDFromContext(r.Context())
+	if !ok {
+		utils.WriteError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	// Path: /api/groups/{id}/messages/{messageID}/thread
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	groupID, err := strconv.Atoi(parts[2])
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, "Invalid group ID")
+		return
+	}
+	messageID, err := strconv.Atoi(parts[4])
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, "Invalid message ID")
+		return
+	}
+
+	isMember, err := h.Service.IsGroupMember(groupID, userID)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, "Failed to check membership")
+		return
+	}
+	if !isMember {
+		utils.WriteError(w, http.StatusForbidden, "Not a member of the group")
+		return
+	}
+
+	before := utils.ExtractQueryIntWithDefault(r, "before", 0)
+	limit := utils.ExtractQueryIntWithDefault(r, "limit", 50)
+
+	thread, err := h.Service.GetGroupThread(groupID, messageID, before, limit)
+	if err != nil {
+		writeMessageError(w, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, thread)
+}
+
+// writeMessageError traduit les erreurs sur un message du groupe en statut HTTP
+func writeMessageError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, services.ErrMessageNotFound):
+		utils.WriteError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, services.ErrNotMessageSender), errors.Is(err, services.ErrNotGroupMember):
+		utils.WriteError(w, http.StatusForbidden, err.Error())
+	case errors.Is(err, services.ErrEditWindowExpired):
+		utils.WriteError(w, http.StatusConflict, err.Error())
+	case errors.Is(err, services.ErrEmptyMessage),
+		errors.Is(err, services.ErrInvalidEmoji),
+		errors.Is(err, services.ErrTooManyReactions):
+		utils.WriteError(w, http.StatusBadRequest, err.Error())
+	default:
+		utils.WriteError(w, http.StatusInternalServerError, "Failed to update message")
+	}
+}
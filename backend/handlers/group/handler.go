@@ -20,6 +20,7 @@ var (
 type Handler struct {
 	Service *services.GroupService
 	Session *services.SessionService
+	Limiter *services.RateLimitService
 	Hub     *hub.Hub
 
 	// Sub-handlers
@@ -29,18 +30,19 @@ type Handler struct {
 	Chat       *ChatHandler
 }
 
-func NewHandler(service *services.GroupService, session *services.SessionService, hub *hub.Hub) *Handler {
+func NewHandler(service *services.GroupService, media *services.MediaService, session *services.SessionService, limiter *services.RateLimitService, hub *hub.Hub) *Handler {
 	h := &Handler{
 		Service: service,
 		Session: session,
+		Limiter: limiter,
 		Hub:     hub,
 	}
 
 	// Initialize sub-handlers
 	h.Membership = NewMembershipHandler(service, session, hub)
-	h.Posts = NewPostsHandler(service, session)
+	h.Posts = NewPostsHandler(service, media, session, limiter)
 	h.Events = NewEventsHandler(service, session, hub)
-	h.Chat = NewChatHandler(service, session, hub)
+	h.Chat = NewChatHandler(service, session, limiter, hub)
 
 	return h
 }
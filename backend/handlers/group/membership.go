@@ -1,9 +1,12 @@
 package group
 
 import (
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
 	"strings"
 
 	"social/hub"
@@ -189,6 +192,10 @@ func (h *MembershipHandler) InviteUser(w http.ResponseWriter, r *http.Request) {
 	}
 
 	notification, err := h.Service.InviteUserToGroup(groupID, senderID, req)
+	if errors.Is(err, services.ErrUserBlocked) {
+		utils.WriteError(w, http.StatusForbidden, "You cannot invite this user")
+		return
+	}
 	if err != nil {
 		fmt.Println("Error inviting user:", err)
 		utils.WriteError(w, http.StatusInternalServerError, err.Error())
@@ -223,6 +230,10 @@ func (h *MembershipHandler) ApproveRequest(w http.ResponseWriter, r *http.Reques
 	}
 
 	if err := h.Service.ApproveMembership(groupID, creatorID, req); err != nil {
+		if errors.Is(err, services.ErrNoPendingRequest) {
+			utils.WriteError(w, http.StatusNotFound, err.Error())
+			return
+		}
 		utils.WriteError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
@@ -251,6 +262,10 @@ func (h *MembershipHandler) DeclineRequest(w http.ResponseWriter, r *http.Reques
 	}
 
 	if err := h.Service.DeclineMembership(groupID, creatorID, req); err != nil {
+		if errors.Is(err, services.ErrNoPendingRequest) {
+			utils.WriteError(w, http.StatusNotFound, err.Error())
+			return
+		}
 		utils.WriteError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
@@ -258,6 +273,75 @@ func (h *MembershipHandler) DeclineRequest(w http.ResponseWriter, r *http.Reques
 	utils.WriteSuccess(w, "Request declined successfully")
 }
 
+// LeaveGroup retire l'utilisateur connecté du groupe (POST /api/groups/{id}/membership/leave)
+func (h *MembershipHandler) LeaveGroup(w http.ResponseWriter, r *http.Request) {
+	groupID, err := utils.ExtractIDFromPath(r.URL.Path, "/api/groups/", "/membership/leave")
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, "Invalid group ID")
+		return
+	}
+
+	userID, ok := utils.GetUserIDFromContext(r.Context())
+	if !ok {
+		utils.WriteError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	if err := h.Service.LeaveGroup(groupID, userID); err != nil {
+		writeMembershipError(w, err, "Failed to leave group")
+		return
+	}
+
+	utils.WriteSuccess(w, "Left the group successfully")
+}
+
+// RemoveMember exclut un membre du groupe, réservé au créateur (DELETE /api/groups/{id}/members/{userID})
+func (h *MembershipHandler) RemoveMember(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(parts) != 5 {
+		utils.WriteError(w, http.StatusBadRequest, "Invalid path")
+		return
+	}
+	groupID, err := strconv.Atoi(parts[2])
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, "Invalid group ID")
+		return
+	}
+	memberID, err := strconv.Atoi(parts[4])
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	creatorID, ok := utils.GetUserIDFromContext(r.Context())
+	if !ok {
+		utils.WriteError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	if err := h.Service.RemoveMember(groupID, creatorID, memberID); err != nil {
+		writeMembershipError(w, err, "Failed to remove member")
+		return
+	}
+
+	utils.WriteSuccess(w, "Member removed successfully")
+}
+
+func writeMembershipError(w http.ResponseWriter, err error, fallback string) {
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		utils.WriteError(w, http.StatusNotFound, "Group not found")
+	case errors.Is(err, services.ErrNotGroupMember):
+		utils.WriteError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, services.ErrUnauthorized):
+		utils.WriteError(w, http.StatusForbidden, "Only the group creator can remove members")
+	case errors.Is(err, services.ErrCreatorCannotLeave):
+		utils.WriteError(w, http.StatusConflict, err.Error())
+	default:
+		utils.WriteError(w, http.StatusInternalServerError, fallback)
+	}
+}
+
 // GetInvitableMembers récupère les utilisateurs qui peuvent être invités
 func (h *MembershipHandler) GetInvitableMembers(w http.ResponseWriter, r *http.Request) {
 	groupID, err := utils.ExtractIDFromPath(r.URL.Path, "/api/groups/", "/invitable_members")
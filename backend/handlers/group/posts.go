@@ -12,13 +12,17 @@ import (
 
 type PostsHandler struct {
 	Service *services.GroupService
+	Media   *services.MediaService
 	Session *services.SessionService
+	Limiter *services.RateLimitService
 }
 
-func NewPostsHandler(service *services.GroupService, session *services.SessionService) *PostsHandler {
+func NewPostsHandler(service *services.GroupService, media *services.MediaService, session *services.SessionService, limiter *services.RateLimitService) *PostsHandler {
 	return &PostsHandler{
 		Service: service,
+		Media:   media,
 		Session: session,
+		Limiter: limiter,
 	}
 }
 
@@ -69,18 +73,30 @@ func (h *PostsHandler) CreatePost(w http.ResponseWriter, r *http.Request) {
 		utils.WriteError(w, http.StatusBadRequest, "Content is required")
 		return
 	}
+	if !allowContent(w, r, h.Limiter, services.ActionPost, content) {
+		return
+	}
 
 	// Upload image optionnel
-	imageURL, err := utils.HandleOptionalFileUpload(
-		r,
-		"image",
-		utils.DefaultImageUploadConfig("uploads/group_posts"),
-	)
+	uploadConfig := utils.DefaultImageUploadConfig("uploads/group_posts")
+	uploadConfig.Quota = h.Media.QuotaCheck(userID)
+	image, err := utils.HandleOptionalMediaUpload(r, "image", uploadConfig)
 	if err != nil {
-		utils.WriteError(w, http.StatusBadRequest, "Failed to upload image: "+err.Error())
+		utils.WriteError(w, utils.UploadErrorStatus(err), "Failed to upload image: "+err.Error())
 		return
 	}
 
+	imageURL := ""
+	if image != nil {
+		if err := h.Media.RecordUpload(userID, image); err != nil {
+			fmt.Println("Error recording image metadata:", err)
+			h.Media.DiscardUpload(r.Context(), image)
+			utils.WriteError(w, http.StatusInternalServerError, "Failed to save image")
+			return
+		}
+		imageURL = image.Path
+	}
+
 	post := models.GroupPost{
 		GroupID:  groupID,
 		AuthorID: userID,
@@ -91,6 +107,7 @@ func (h *PostsHandler) CreatePost(w http.ResponseWriter, r *http.Request) {
 	createdPost, err := h.Service.CreateGroupPost(post)
 	if err != nil {
 		fmt.Println("Error creating post:", err)
+		h.Media.DiscardUpload(r.Context(), image)
 		utils.WriteError(w, http.StatusInternalServerError, "Failed to create post")
 		return
 	}
@@ -157,6 +174,9 @@ func (h *PostsHandler) CreateComment(w http.ResponseWriter, r *http.Request) {
 		utils.WriteError(w, http.StatusBadRequest, "Content is required")
 		return
 	}
+	if !allowContent(w, r, h.Limiter, services.ActionComment, content) {
+		return
+	}
 
 	comment, err := h.Service.CreateGroupPostComment(userID, postID, content)
 	if err != nil {
@@ -4,6 +4,9 @@ import (
 	"net/http"
 	"strconv"
 	"strings"
+
+	"social/services"
+	"social/utils"
 )
 
 // GroupRouterHandler est le routeur principal qui délègue aux sub-handlers
@@ -41,6 +44,40 @@ func (h *Handler) GroupRouterHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	// Handle members/{userID} (remove a member)
+	if len(pathParts) == 5 && pathParts[3] == "members" && method == http.MethodDelete {
+		h.Membership.RemoveMember(w, r)
+		return
+	}
+
+	// Handle messages/{messageID}/reactions and messages/{messageID}/thread
+	if len(pathParts) == 6 && pathParts[3] == "messages" {
+		switch {
+		case pathParts[5] == "reactions":
+			if method == http.MethodPost && !h.allow(w, r, services.ActionMessage) {
+				return
+			}
+			h.Chat.Reactions(w, r, method)
+		case pathParts[5] == "thread" && method == http.MethodGet:
+			h.Chat.GetThread(w, r)
+		default:
+			http.NotFound(w, r)
+		}
+		return
+	}
+
+	// Handle messages/{messageID} (edit / delete a chat message)
+	if len(pathParts) == 5 && pathParts[3] == "messages" {
+		h.Chat.UpdateMessage(w, r, method)
+		return
+	}
+
+	// Routes limitées par utilisateur (voir services.RateLimitService) ; les posts, commentaires
+	// et messages sont limités par leur handler, qui connaît le contenu envoyé
+	if method == http.MethodPost && suffix == "invite" && !h.allow(w, r, services.ActionInvite) {
+		return
+	}
+
 	// Route to appropriate sub-handler based on suffix
 	switch {
 	// Membership routes
@@ -62,6 +99,8 @@ func (h *Handler) GroupRouterHandler(w http.ResponseWriter, r *http.Request) {
 		h.Membership.ApproveRequest(w, r)
 	case suffix == "membership/decline" && method == http.MethodPost:
 		h.Membership.DeclineRequest(w, r)
+	case suffix == "membership/leave" && method == http.MethodPost:
+		h.Membership.LeaveGroup(w, r)
 	case suffix == "invitable_members":
 		h.Membership.GetInvitableMembers(w, r)
 
@@ -92,4 +131,18 @@ func (h *Handler) GroupRouterHandler(w http.ResponseWriter, r *http.Request) {
 	default:
 		http.NotFound(w, r)
 	}
-}
\ No newline at end of file
+}
+
+// allow consomme le budget action de l'utilisateur, ou répond 429
+func (h *Handler) allow(w http.ResponseWriter, r *http.Request, action string) bool {
+	return allowContent(w, r, h.Limiter, action, "")
+}
+
+// allowContent consomme le budget action de l'utilisateur en refusant aussi un contenu répété, ou répond 429
+func allowContent(w http.ResponseWriter, r *http.Request, limiter *services.RateLimitService, action, content string) bool {
+	userID, ok := utils.GetUserIDFromContext(r.Context())
+	if !ok || limiter == nil {
+		return true
+	}
+	return utils.CheckRateLimitContent(w, limiter, userID, action, content)
+}
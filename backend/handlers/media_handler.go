@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"social/services"
+	"social/storage"
+	"social/utils"
+)
+
+// inlineMediaTypes sont les seuls types affichés dans la page : les images produites par l'upload.
+// Tout autre fichier (audio, pdf, texte…) est servi en téléchargement.
+var inlineMediaTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/gif":  true,
+	"image/webp": true,
+}
+
+type MediaHandler struct {
+	media   *services.MediaService
+	session *services.SessionService
+}
+
+func NewMediaHandler(media *services.MediaService, session *services.SessionService) *MediaHandler {
+	return &MediaHandler{media: media, session: session}
+}
+
+// ServeMedia sert GET /uploads/{key}.
+// L'accès est accordé soit par une URL signée valide (exp + sig), soit par la session
+// du visiteur s'il a le droit de voir le post, commentaire, post de groupe, message du chat ou avatar
+// qui référence le fichier. Un refus répond 404 pour ne pas révéler l'existence du fichier.
+func (h *MediaHandler) ServeMedia(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		utils.WriteError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	key := strings.TrimPrefix(r.URL.Path, "/uploads/")
+	if key == "" || strings.HasSuffix(key, "/") {
+		utils.WriteError(w, http.StatusNotFound, "Not found")
+		return
+	}
+
+	maxAge := 5 * time.Minute
+	if exp, ok := storage.VerifySignedURL(key, r.URL.Query()); ok {
+		maxAge = time.Until(exp)
+	} else {
+		viewerID, err := h.session.GetUserIDFromSession(r)
+		if err != nil {
+			utils.WriteError(w, http.StatusNotFound, "Not found")
+			return
+		}
+		allowed, err := h.media.CanView(viewerID, key)
+		if err != nil {
+			fmt.Println("❌ media access check failed:", err)
+			utils.WriteError(w, http.StatusInternalServerError, "Could not load media")
+			return
+		}
+		if !allowed {
+			utils.WriteError(w, http.StatusNotFound, "Not found")
+			return
+		}
+	}
+
+	obj, err := storage.GetStorage().Get(r.Context(), key)
+	if errors.Is(err, storage.ErrNotFound) {
+		utils.WriteError(w, http.StatusNotFound, "Not found")
+		return
+	}
+	if err != nil {
+		fmt.Println("❌ media read failed:", err)
+		utils.WriteError(w, http.StatusInternalServerError, "Could not load media")
+		return
+	}
+
+	// ServeContent gère Range, If-None-Match et If-Modified-Since, mais il lui faut un ReadSeeker :
+	// un fichier S3 est lu en flux et rouvert à la position demandée plutôt que chargé en mémoire
+	content := storage.Seekable(r.Context(), storage.GetStorage(), obj)
+	defer content.Close()
+
+	etag := obj.ETag
+	if etag == "" {
+		etag = fmt.Sprintf(`W/"%x-%x"`, obj.Size, obj.ModTime.UnixNano())
+	}
+
+	// Le type servi est celui détecté à l'upload, jamais celui déduit du nom de fichier
+	contentType, err := h.media.ContentType(key)
+	if err != nil {
+		fmt.Println("❌ media type lookup failed:", err)
+		utils.WriteError(w, http.StatusInternalServerError, "Could not load media")
+		return
+	}
+	if contentType == "" {
+		// Fichier antérieur à la table media : seules les images reconnues gardent leur type
+		contentType = "application/octet-stream"
+		head := make([]byte, 512)
+		n, _ := io.ReadFull(content, head)
+		if imageType, ok := utils.SniffImageType(head[:n]); ok {
+			contentType = imageType
+		}
+		if _, err := content.Seek(0, io.SeekStart); err != nil {
+			fmt.Println("❌ media read failed:", err)
+			utils.WriteError(w, http.StatusInternalServerError, "Could not load media")
+			return
+		}
+	}
+
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", fmt.Sprintf("private, max-age=%d", int(maxAge.Seconds())))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Content-Security-Policy", "sandbox")
+	w.Header().Set("Content-Type", contentType)
+	if !inlineMediaTypes[contentType] {
+		w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": path.Base(key)}))
+	}
+
+	http.ServeContent(w, r, key, obj.ModTime, content)
+}
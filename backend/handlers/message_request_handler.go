@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"social/hub"
+	"social/models"
+	"social/services"
+	"social/utils"
+)
+
+type MessageRequestHandler struct {
+	Service *services.MessageRequestService
+	Hub     *hub.Hub
+}
+
+func NewMessageRequestHandler(service *services.MessageRequestService, hub *hub.Hub) *MessageRequestHandler {
+	return &MessageRequestHandler{Service: service, Hub: hub}
+}
+
+// GetRequests gère GET /api/chat/requests?status= : les demandes de message reçues (pending par défaut, ou ignored)
+func (h *MessageRequestHandler) GetRequests(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := utils.GetUserIDFromContext(r.Context())
+	if !ok {
+		utils.WriteError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	requests, err := h.Service.GetRequests(userID, r.URL.Query().Get("status"))
+	if err != nil {
+		writeMessageRequestError(w, err)
+		return
+	}
+	if requests == nil {
+		requests = []models.MessageRequest{}
+	}
+
+	utils.WriteJSON(w, http.StatusOK, requests)
+}
+
+// RequestRouterHandler gère POST /api/chat/requests/{userID}/accept, /ignore et /block
+func (h *MessageRequestHandler) RequestRouterHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := utils.GetUserIDFromContext(r.Context())
+	if !ok {
+		utils.WriteError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/chat/requests/"), "/"), "/")
+	if len(parts) != 2 {
+		http.NotFound(w, r)
+		return
+	}
+	senderID, err := strconv.Atoi(parts[0])
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	switch parts[1] {
+	case "accept":
+		event, err := h.Service.Accept(userID, senderID)
+		if err != nil {
+			writeMessageRequestError(w, err)
+			return
+		}
+		h.Hub.SendEvent(senderID, event)
+		utils.WriteSuccess(w, "Message request accepted")
+	case "ignore":
+		if err := h.Service.Ignore(userID, senderID); err != nil {
+			writeMessageRequestError(w, err)
+			return
+		}
+		utils.WriteSuccess(w, "Message request ignored")
+	case "block":
+		if err := h.Service.Block(userID, senderID); err != nil {
+			writeMessageRequestError(w, err)
+			return
+		}
+		utils.WriteSuccess(w, "User blocked")
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// SettingsHandler gère /api/chat/settings : GET retourne le réglage dm_policy, PUT le change
+func (h *MessageRequestHandler) SettingsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := utils.GetUserIDFromContext(r.Context())
+	if !ok {
+		utils.WriteError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		settings, err := h.Service.GetDMPolicy(userID)
+		if err != nil {
+			writeMessageRequestError(w, err)
+			return
+		}
+		utils.WriteJSON(w, http.StatusOK, settings)
+	case http.MethodPut:
+		var req models.DMSettings
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			utils.WriteError(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+		if err := h.Service.SetDMPolicy(userID, req.DMPolicy); err != nil {
+			writeMessageRequestError(w, err)
+			return
+		}
+		utils.WriteJSON(w, http.StatusOK, req)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// writeMessageRequestError traduit les erreurs des demandes de message en statut HTTP
+func writeMessageRequestError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, services.ErrMessageRequestNotFound), errors.Is(err, services.ErrUserNotFound):
+		utils.WriteError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, services.ErrInvalidDMPolicy), errors.Is(err, services.ErrInvalidRequestStatus):
+		utils.WriteError(w, http.StatusBadRequest, err.Error())
+	default:
+		fmt.Println("❌ Message request error:", err)
+		utils.WriteError(w, http.StatusInternalServerError, "Internal server error")
+	}
+}
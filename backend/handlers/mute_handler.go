@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"social/models"
+	"social/services"
+	"social/utils"
+)
+
+type MuteHandler struct {
+	Service *services.MuteService
+}
+
+func NewMuteHandler(s *services.MuteService) *MuteHandler {
+	return &MuteHandler{Service: s}
+}
+
+// MutesHandler gère /api/mutes : GET liste les masquages, POST masque un utilisateur
+func (h *MuteHandler) MutesHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		h.GetMutes(w, r)
+	case http.MethodPost:
+		h.MuteUser(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// MuteRouterHandler gère POST /api/mutes/keywords, DELETE /api/mutes/keywords/{id} et DELETE /api/mutes/{userID}
+func (h *MuteHandler) MuteRouterHandler(w http.ResponseWriter, r *http.Request) {
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/mutes/"), "/")
+
+	switch {
+	case path == "keywords" && r.Method == http.MethodPost:
+		h.MuteKeyword(w, r)
+	case strings.HasPrefix(path, "keywords/") && r.Method == http.MethodDelete:
+		h.UnmuteKeyword(w, r)
+	case !strings.Contains(path, "/") && r.Method == http.MethodDelete:
+		h.UnmuteUser(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *MuteHandler) GetMutes(w http.ResponseWriter, r *http.Request) {
+	userID, ok := utils.GetUserIDFromContext(r.Context())
+	if !ok {
+		utils.WriteError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	mutes, err := h.Service.GetMutes(userID)
+	if err != nil {
+		fmt.Println("❌ Failed to fetch mutes:", err)
+		utils.WriteError(w, http.StatusInternalServerError, "Failed to fetch mutes")
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, mutes)
+}
+
+func (h *MuteHandler) MuteUser(w http.ResponseWriter, r *http.Request) {
+	userID, ok := utils.GetUserIDFromContext(r.Context())
+	if !ok {
+		utils.WriteError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var req models.MuteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.UserID <= 0 {
+		utils.WriteError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	err := h.Service.MuteUser(userID, req)
+	switch {
+	case errors.Is(err, services.ErrCannotMute), errors.Is(err, services.ErrExpiryInPast):
+		utils.WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	case errors.Is(err, services.ErrUserNotFound):
+		utils.WriteError(w, http.StatusNotFound, "User not found")
+		return
+	case err != nil:
+		fmt.Println("❌ Failed to mute user:", err)
+		utils.WriteError(w, http.StatusInternalServerError, "Failed to mute user")
+		return
+	}
+
+	utils.WriteSuccess(w, "User muted")
+}
+
+func (h *MuteHandler) UnmuteUser(w http.ResponseWriter, r *http.Request) {
+	userID, ok := utils.GetUserIDFromContext(r.Context())
+	if !ok {
+		utils.WriteError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	mutedID, err := utils.ExtractIDFromPath(r.URL.Path, "/api/mutes/", "")
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	if err := h.Service.UnmuteUser(userID, mutedID); err != nil {
+		fmt.Println("❌ Failed to unmute user:", err)
+		utils.WriteError(w, http.StatusInternalServerError, "Failed to unmute user")
+		return
+	}
+
+	utils.WriteSuccess(w, "User unmuted")
+}
+
+// MuteKeyword gère POST /api/mutes/keywords {"keyword", "expires_at"} : le mot-clé masque les contenus
+// qui le contiennent comme mot entier (ou suite de mots), sans tenir compte de la casse ni de la ponctuation
+// autour ; "cat" masque "My cat!" mais ni "education" ni "cats". Il doit contenir au moins une lettre ou un chiffre.
+func (h *MuteHandler) MuteKeyword(w http.ResponseWriter, r *http.Request) {
+	userID, ok := utils.GetUserIDFromContext(r.Context())
+	if !ok {
+		utils.WriteError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var req models.MuteKeywordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	keyword, err := h.Service.MuteKeyword(userID, req)
+	if errors.Is(err, services.ErrInvalidKeyword) || errors.Is(err, services.ErrExpiryInPast) {
+		utils.WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		fmt.Println("❌ Failed to mute keyword:", err)
+		utils.WriteError(w, http.StatusInternalServerError, "Failed to mute keyword")
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusCreated, keyword)
+}
+
+func (h *MuteHandler) UnmuteKeyword(w http.ResponseWriter, r *http.Request) {
+	userID, ok := utils.GetUserIDFromContext(r.Context())
+	if !ok {
+		utils.WriteError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	keywordID, err := utils.ExtractIDFromPath(r.URL.Path, "/api/mutes/keywords/", "")
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, "Invalid keyword ID")
+		return
+	}
+
+	if err := h.Service.UnmuteKeyword(userID, keywordID); err != nil {
+		fmt.Println("❌ Failed to unmute keyword:", err)
+		utils.WriteError(w, http.StatusInternalServerError, "Failed to unmute keyword")
+		return
+	}
+
+	utils.WriteSuccess(w, "Keyword unmuted")
+}
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
@@ -11,11 +12,13 @@ import (
 
 type PostHandler struct {
 	service *services.PostService
+	media   *services.MediaService
 	session *services.SessionService
+	limiter *services.RateLimitService
 }
 
-func NewPostHandler(service *services.PostService, session *services.SessionService) *PostHandler {
-	return &PostHandler{service: service, session: session}
+func NewPostHandler(service *services.PostService, media *services.MediaService, session *services.SessionService, limiter *services.RateLimitService) *PostHandler {
+	return &PostHandler{service: service, media: media, session: session, limiter: limiter}
 }
 
 func (h *PostHandler) GetUserPostsHandler(w http.ResponseWriter, r *http.Request) {
@@ -75,18 +78,30 @@ func (h *PostHandler) CreatePostHandler(w http.ResponseWriter, r *http.Request)
 		utils.WriteError(w, http.StatusBadRequest, "Missing content or privacy")
 		return
 	}
+	if h.limiter != nil && !utils.CheckRateLimitContent(w, h.limiter, userID, services.ActionPost, content) {
+		return
+	}
 
 	// Upload image optionnel
-	imageURL, err := utils.HandleOptionalFileUpload(
-		r,
-		"image",
-		utils.DefaultImageUploadConfig("uploads"),
-	)
+	uploadConfig := utils.DefaultImageUploadConfig("uploads")
+	uploadConfig.Quota = h.media.QuotaCheck(userID)
+	image, err := utils.HandleOptionalMediaUpload(r, "image", uploadConfig)
 	if err != nil {
-		utils.WriteError(w, http.StatusBadRequest, "Failed to upload image: "+err.Error())
+		utils.WriteError(w, utils.UploadErrorStatus(err), "Failed to upload image: "+err.Error())
 		return
 	}
 
+	imageURL := ""
+	if image != nil {
+		if err := h.media.RecordUpload(userID, image); err != nil {
+			fmt.Println("❌ Failed to record image metadata:", err)
+			h.media.DiscardUpload(r.Context(), image)
+			utils.WriteError(w, http.StatusInternalServerError, "Failed to save image")
+			return
+		}
+		imageURL = image.Path
+	}
+
 	var recipientIDs []int
 	if privacy == "custom" {
 		for _, idStr := range recipientIDsStr {
@@ -100,6 +115,7 @@ func (h *PostHandler) CreatePostHandler(w http.ResponseWriter, r *http.Request)
 	err = h.service.CreatePost(userID, content, imageURL, privacy, recipientIDs)
 	if err != nil {
 		fmt.Println(err)
+		h.media.DiscardUpload(r.Context(), image)
 		utils.WriteError(w, http.StatusInternalServerError, "Failed to create post")
 		return
 	}
@@ -148,18 +164,30 @@ func (h *PostHandler) CreateCommentHandler(w http.ResponseWriter, r *http.Reques
 	}
 
 	content := r.FormValue("content")
+	if h.limiter != nil && !utils.CheckRateLimitContent(w, h.limiter, userID, services.ActionComment, content) {
+		return
+	}
 
 	// Upload image optionnel
-	imageURL, err := utils.HandleOptionalFileUpload(
-		r,
-		"image",
-		utils.DefaultImageUploadConfig("uploads"),
-	)
+	uploadConfig := utils.DefaultImageUploadConfig("uploads")
+	uploadConfig.Quota = h.media.QuotaCheck(userID)
+	image, err := utils.HandleOptionalMediaUpload(r, "image", uploadConfig)
 	if err != nil {
-		utils.WriteError(w, http.StatusBadRequest, "Failed to upload image: "+err.Error())
+		utils.WriteError(w, utils.UploadErrorStatus(err), "Failed to upload image: "+err.Error())
 		return
 	}
 
+	imageURL := ""
+	if image != nil {
+		if err := h.media.RecordUpload(userID, image); err != nil {
+			fmt.Println("❌ Failed to record image metadata:", err)
+			h.media.DiscardUpload(r.Context(), image)
+			utils.WriteError(w, http.StatusInternalServerError, "Failed to save image")
+			return
+		}
+		imageURL = image.Path
+	}
+
 	if content == "" && imageURL == "" {
 		utils.WriteError(w, http.StatusBadRequest, "Comment cannot be empty")
 		return
@@ -167,6 +195,11 @@ func (h *PostHandler) CreateCommentHandler(w http.ResponseWriter, r *http.Reques
 
 	err = h.service.CreateComment(postID, userID, content, imageURL)
 	if err != nil {
+		h.media.DiscardUpload(r.Context(), image)
+		if errors.Is(err, services.ErrUserBlocked) {
+			utils.WriteError(w, http.StatusForbidden, "You cannot comment on this post")
+			return
+		}
 		utils.WriteError(w, http.StatusInternalServerError, "Database error")
 		return
 	}
@@ -180,13 +213,19 @@ func (h *PostHandler) GetCommentsByPostHandler(w http.ResponseWriter, r *http.Re
 		return
 	}
 
+	userID, ok := utils.GetUserIDFromContext(r.Context())
+	if !ok {
+		utils.WriteError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
 	postID := r.URL.Query().Get("id")
 	if postID == "" {
 		utils.WriteError(w, http.StatusBadRequest, "Post ID missing")
 		return
 	}
 
-	comments, err := h.service.GetCommentsByPost(postID)
+	comments, err := h.service.GetCommentsByPost(postID, userID)
 	if err != nil {
 		utils.WriteError(w, http.StatusInternalServerError, "Database error")
 		return
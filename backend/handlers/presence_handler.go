@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"social/services"
+	"social/utils"
+)
+
+type PresenceHandler struct {
+	Service *services.PresenceService
+}
+
+func NewPresenceHandler(s *services.PresenceService) *PresenceHandler {
+	return &PresenceHandler{Service: s}
+}
+
+// GetPresence gère GET /api/presence?ids=1,2,3 : statut (online, away, offline) et dernière connexion
+func (h *PresenceHandler) GetPresence(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := utils.GetUserIDFromContext(r.Context())
+	if !ok {
+		utils.WriteError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	idsParam, err := utils.ExtractQueryString(r, "ids")
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, "Missing ids parameter")
+		return
+	}
+
+	var ids []int
+	for _, part := range strings.Split(idsParam, ",") {
+		id, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil || id <= 0 {
+			utils.WriteError(w, http.StatusBadRequest, "Invalid user ID")
+			return
+		}
+		ids = append(ids, id)
+	}
+
+	presence, err := h.Service.GetPresence(userID, ids)
+	if err != nil {
+		fmt.Println("❌ Failed to fetch presence:", err)
+		utils.WriteError(w, http.StatusInternalServerError, "Failed to fetch presence")
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, presence)
+}
@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"strings"
 
 	"social/hub"
 	"social/models"
@@ -50,9 +51,22 @@ func (h *ProfileHandler) ProfileHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	user.Avatar = utils.PrepareAvatarURL(user.Avatar)
 	utils.WriteJSON(w, http.StatusOK, user)
 }
 
+// UsersRouterHandler gère /api/users/{id}, /api/users/{id}/mutuals et /api/users/{id}/relationship
+func (h *ProfileHandler) UsersRouterHandler(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case strings.HasSuffix(r.URL.Path, "/mutuals"):
+		h.GetMutuals(w, r)
+	case strings.HasSuffix(r.URL.Path, "/relationship"):
+		h.GetRelationship(w, r)
+	default:
+		h.GetUserByIDHandler(w, r)
+	}
+}
+
 func (h *ProfileHandler) GetUserByIDHandler(w http.ResponseWriter, r *http.Request) {
 	targetID, err := utils.ExtractIDFromPath(r.URL.Path, "/api/users/", "")
 	if err != nil {
@@ -75,14 +89,89 @@ func (h *ProfileHandler) GetUserByIDHandler(w http.ResponseWriter, r *http.Reque
 	utils.WriteJSON(w, http.StatusOK, user)
 }
 
+// GetMutuals gère GET /api/users/{id}/mutuals
+func (h *ProfileHandler) GetMutuals(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	targetID, err := utils.ExtractIDFromPath(r.URL.Path, "/api/users/", "/mutuals")
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	requesterID, ok := utils.GetUserIDFromContext(r.Context())
+	if !ok {
+		utils.WriteError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	mutuals, err := h.profileService.GetMutuals(requesterID, targetID)
+	switch {
+	case errors.Is(err, services.ErrUserBlocked), errors.Is(err, sql.ErrNoRows):
+		utils.WriteError(w, http.StatusNotFound, "User not found")
+		return
+	case errors.Is(err, services.ErrPrivateAccount):
+		utils.WriteError(w, http.StatusForbidden, err.Error())
+		return
+	case err != nil:
+		fmt.Println("❌ Failed to fetch mutuals:", err)
+		utils.WriteError(w, http.StatusInternalServerError, "Failed to fetch mutuals")
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, mutuals)
+}
+
+// GetRelationship gère GET /api/users/{id}/relationship
+func (h *ProfileHandler) GetRelationship(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	targetID, err := utils.ExtractIDFromPath(r.URL.Path, "/api/users/", "/relationship")
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	requesterID, ok := utils.GetUserIDFromContext(r.Context())
+	if !ok {
+		utils.WriteError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	rel, err := h.profileService.GetRelationship(requesterID, targetID)
+	if errors.Is(err, sql.ErrNoRows) {
+		utils.WriteError(w, http.StatusNotFound, "User not found")
+		return
+	}
+	if err != nil {
+		fmt.Println("❌ Failed to fetch relationship:", err)
+		utils.WriteError(w, http.StatusInternalServerError, "Failed to fetch relationship")
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, rel)
+}
+
 func (h *ProfileHandler) SearchUsers(w http.ResponseWriter, r *http.Request) {
+	requesterID, ok := utils.GetUserIDFromContext(r.Context())
+	if !ok {
+		utils.WriteError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
 	query := r.URL.Query().Get("query")
 	if query == "" {
 		utils.WriteError(w, http.StatusBadRequest, "Missing search query")
 		return
 	}
 
-	results, err := h.profileService.SearchUsers(query)
+	results, err := h.profileService.SearchUsers(requesterID, query)
 	if err != nil {
 		utils.WriteError(w, http.StatusInternalServerError, "Internal server error")
 		return
@@ -109,12 +198,40 @@ func (h *ProfileHandler) TogglePrivacy(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := h.profileService.TogglePrivacy(userID, req.IsPrivate); err != nil {
+	change, err := h.profileService.TogglePrivacy(userID, req.IsPrivate, req.RequireReapproval)
+	if err != nil {
 		utils.WriteError(w, http.StatusInternalServerError, "Database error")
 		return
 	}
 
-	utils.WriteSuccess(w, "Privacy updated successfully")
+	if h.Hub != nil {
+		for followerID, notification := range change.Notifications {
+			h.Hub.SendNotification(notification, followerID)
+		}
+		for _, followerID := range change.AutoAccepted {
+			h.Hub.SendEvent(followerID, models.FollowEvent{
+				Type:        "follow_request_response",
+				SenderID:    followerID,
+				RecipientID: userID,
+				Action:      "accepted",
+			})
+		}
+		for _, followerID := range change.PendingReapproval {
+			h.Hub.SendEvent(followerID, models.FollowEvent{
+				Type:        "follow_status_update",
+				SenderID:    followerID,
+				RecipientID: userID,
+				Action:      "pending",
+			})
+		}
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"message":            "Privacy updated successfully",
+		"is_private":         change.IsPrivate,
+		"auto_accepted":      change.AutoAccepted,
+		"pending_reapproval": change.PendingReapproval,
+	})
 }
 
 func (h *ProfileHandler) GetMe(w http.ResponseWriter, r *http.Request) {
@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"social/models"
+	"social/services"
+	"social/utils"
+)
+
+type SuggestionHandler struct {
+	Service *services.SuggestionService
+}
+
+func NewSuggestionHandler(s *services.SuggestionService) *SuggestionHandler {
+	return &SuggestionHandler{Service: s}
+}
+
+// GetUserSuggestions gère GET /api/suggestions/users?limit=
+func (h *SuggestionHandler) GetUserSuggestions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := utils.GetUserIDFromContext(r.Context())
+	if !ok {
+		utils.WriteError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	limit := utils.ExtractQueryIntWithDefault(r, "limit", 0)
+	suggestions, err := h.Service.GetUserSuggestions(userID, limit)
+	if err != nil {
+		fmt.Println("❌ Failed to fetch suggestions:", err)
+		utils.WriteError(w, http.StatusInternalServerError, "Failed to fetch suggestions")
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, suggestions)
+}
+
+// DismissSuggestion gère POST /api/suggestions/dismiss : la suggestion ne reviendra plus
+func (h *SuggestionHandler) DismissSuggestion(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := utils.GetUserIDFromContext(r.Context())
+	if !ok {
+		utils.WriteError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var req models.DismissSuggestionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.UserID <= 0 {
+		utils.WriteError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	err := h.Service.DismissSuggestion(userID, req.UserID)
+	switch {
+	case errors.Is(err, services.ErrInvalidSuggestion):
+		utils.WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	case errors.Is(err, services.ErrUserNotFound):
+		utils.WriteError(w, http.StatusNotFound, "User not found")
+		return
+	case err != nil:
+		fmt.Println("❌ Failed to dismiss suggestion:", err)
+		utils.WriteError(w, http.StatusInternalServerError, "Failed to dismiss suggestion")
+		return
+	}
+
+	utils.WriteSuccess(w, "Suggestion dismissed")
+}
@@ -0,0 +1,181 @@
+package hub
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"social/broker"
+	"social/models"
+)
+
+// Sujets du broker partagés par toutes les instances
+const (
+	subjectDeliver  = "hub.deliver"
+	subjectPresence = "hub.presence"
+)
+
+const (
+	presenceSyncInterval = 30 * time.Second
+	// Une instance muette depuis plus longtemps est considérée arrêtée
+	presenceExpiry = 3 * presenceSyncInterval
+	publishTimeout = 5 * time.Second
+	// Lots d'événements en attente de publication au-delà desquels deliverAll attend
+	publishQueueSize = 1024
+)
+
+// wireEvent est un événement à écrire sur les connexions de UserID, quelle que soit l'instance
+// qui les détient ; chacune le met au format de sa version du protocole. Seq vaut 0 pour un
+// événement éphémère.
+type wireEvent struct {
+	UserID int             `json:"user_id"`
+	Type   string          `json:"type"`
+	Seq    int64           `json:"seq,omitempty"`
+	Data   json.RawMessage `json:"data"`
+}
+
+// subscribe branche le hub sur le broker : chaque instance, y compris celle qui publie,
+// reçoit chaque événement une fois et l'écrit sur ses propres connexions seulement.
+// Les événements publiés pendant une coupure de l'abonnement sont rattrapés depuis le journal.
+func (h *Hub) subscribe() {
+	h.broker.OnResubscribe(h.resync)
+	if err := h.broker.Subscribe(subjectDeliver, h.onDeliver); err != nil {
+		fmt.Printf("❌ Failed to subscribe to %s: %v\n", subjectDeliver, err)
+	}
+	if err := h.broker.Subscribe(subjectGroups, h.onGroupInvalidated); err != nil {
+		fmt.Printf("❌ Failed to subscribe to %s: %v\n", subjectGroups, err)
+	}
+	if h.presenceService != nil {
+		if err := h.broker.Subscribe(subjectPresence, h.onPresenceSync); err != nil {
+			fmt.Printf("❌ Failed to subscribe to %s: %v\n", subjectPresence, err)
+		}
+	}
+}
+
+// publish met des événements en file pour toutes les instances ; ils partent dans l'ordre de la file,
+// sans que l'appelant attende le broker
+func (h *Hub) publish(events []wireEvent) {
+	if len(events) == 0 {
+		return
+	}
+	h.outbox <- events
+}
+
+// runPublisher publie les lots de la file un par un : une publication lente ne retarde que les suivantes
+func (h *Hub) runPublisher() {
+	for events := range h.outbox {
+		h.publishBatch(events)
+	}
+}
+
+// publishBatch diffuse un lot ; si rien n'a pu être publié, il est au moins livré aux connexions locales
+// (les autres instances le rattraperont via since). Un envoi non confirmé a pu être diffusé : seuls ses
+// événements numérotés sont renvoyés, une fois, chaque connexion écartant les seq déjà reçus ; à défaut
+// ils sont livrés localement. Les événements éphémères d'un envoi non confirmé sont abandonnés.
+func (h *Hub) publishBatch(events []wireEvent) {
+	err := h.publishEvents(events)
+	if errors.Is(err, broker.ErrUnconfirmed) {
+		fmt.Printf("⚠️ Publication of events not confirmed, resending numbered ones: %v\n", err)
+		numbered := make([]wireEvent, 0, len(events))
+		for _, e := range events {
+			if e.Seq > 0 {
+				numbered = append(numbered, e)
+			}
+		}
+		if len(numbered) == 0 {
+			return
+		}
+		events = numbered
+		err = h.publishEvents(events)
+	}
+	if err != nil {
+		fmt.Printf("❌ Failed to publish events, delivering locally only: %v\n", err)
+		if data, err := json.Marshal(events); err == nil {
+			h.onDeliver(data)
+		}
+	}
+}
+
+func (h *Hub) publishEvents(events []wireEvent) error {
+	data, err := json.Marshal(events)
+	if err != nil {
+		fmt.Printf("❌ Failed to marshal events: %v\n", err)
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), publishTimeout)
+	defer cancel()
+	return h.broker.Publish(ctx, subjectDeliver, data)
+}
+
+// onDeliver écrit les événements reçus du broker sur les connexions de cette instance
+func (h *Hub) onDeliver(data []byte) {
+	var events []wireEvent
+	if err := json.Unmarshal(data, &events); err != nil {
+		fmt.Printf("❌ Invalid event batch from broker: %v\n", err)
+		return
+	}
+
+	for _, e := range events {
+		// Toutes les connexions de l'utilisateur (onglets, appareils) reçoivent l'événement
+		for _, client := range h.getClients(e.UserID) {
+			data := client.frame(e.Type, e.Seq, e.Data)
+			if e.Seq > 0 {
+				client.push(h, e.Seq, data)
+			} else {
+				h.safeSend(client, data)
+			}
+		}
+	}
+}
+
+// publishPresence annonce aux autres instances les connexions locales de userID
+func (h *Hub) publishPresence(userID int) {
+	h.publishPresenceSync(models.PresenceSync{Users: h.presenceService.LocalState(userID)})
+}
+
+func (h *Hub) publishPresenceSync(sync models.PresenceSync) {
+	sync.Instance = h.instanceID
+	data, err := json.Marshal(sync)
+	if err != nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), publishTimeout)
+	defer cancel()
+	if err := h.broker.Publish(ctx, subjectPresence, data); err != nil {
+		fmt.Printf("❌ Failed to publish presence: %v\n", err)
+	}
+}
+
+// onPresenceSync enregistre l'état d'une autre instance ; les transitions online/offline sont
+// annoncées par l'instance où elles ont lieu, pas ici
+func (h *Hub) onPresenceSync(data []byte) {
+	var sync models.PresenceSync
+	if err := json.Unmarshal(data, &sync); err != nil || sync.Instance == h.instanceID {
+		return
+	}
+	h.presenceService.ApplyRemote(sync)
+
+	// Une instance qui démarre demande l'état des autres
+	if sync.Request {
+		go h.publishPresenceSync(models.PresenceSync{Full: true, Users: h.presenceService.LocalState()})
+	}
+}
+
+// syncPresence publie périodiquement un instantané des connexions locales et oublie
+// les instances qui ne donnent plus signe de vie
+func (h *Hub) syncPresence() {
+	if h.presenceService == nil {
+		return
+	}
+	h.publishPresenceSync(models.PresenceSync{Full: true, Request: true, Users: h.presenceService.LocalState()})
+
+	ticker := time.NewTicker(presenceSyncInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		h.presenceService.PruneRemote(presenceExpiry)
+		h.publishPresenceSync(models.PresenceSync{Full: true, Users: h.presenceService.LocalState()})
+	}
+}
@@ -0,0 +1,227 @@
+package hub
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+
+	"social/broker"
+	"social/broker/redistest"
+	"social/repositories"
+	"social/services"
+)
+
+// newEventService ouvre une base de test avec le journal des événements
+func newEventService(t *testing.T) *services.EventService {
+	t.Helper()
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "events.db")+"?_busy_timeout=5000")
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	schema, err := os.ReadFile("../db/migrations/sqlite/000024_create_user_events_table.up.sql")
+	if err != nil {
+		t.Fatalf("read migration: %v", err)
+	}
+	if _, err := db.Exec(string(schema)); err != nil {
+		t.Fatalf("create tables: %v", err)
+	}
+	return services.NewEventService(repositories.NewEventRepository(db))
+}
+
+// newTestHub crée une instance du backend reliée au serveur redis srv
+func newTestHub(t *testing.T, srv *redistest.Server, events *services.EventService) *Hub {
+	t.Helper()
+	b, err := broker.NewRedisBroker(srv.URL, "social")
+	if err != nil {
+		t.Fatalf("NewRedisBroker: %v", err)
+	}
+	t.Cleanup(func() { b.Close() })
+	return NewHub(nil, nil, nil, nil, events, nil, b)
+}
+
+// connect ouvre une connexion de userID sur h, sans websocket : les trames restent dans Send
+func connect(h *Hub, userID int) *Client {
+	c := &Client{ID: userID, ConnID: newConnID(), Send: make(chan []byte, 256)}
+	h.resumeFrom(c, "")
+	h.addClient(c)
+	return c
+}
+
+// receivedFrame est une trame du protocole historique : le payload avec son seq
+type receivedFrame struct {
+	Type string `json:"type"`
+	Seq  int64  `json:"seq"`
+	N    int    `json:"n"`
+}
+
+// expectEvents attend les événements numérotés want (dans n'importe quel ordre), chacun une fois,
+// et vérifie que rien d'autre n'arrive ensuite
+func expectEvents(t *testing.T, c *Client, want ...int) {
+	t.Helper()
+	pending := make(map[int]bool)
+	for _, n := range want {
+		pending[n] = true
+	}
+	seen := make(map[int64]bool)
+	timeout := time.After(3 * time.Second)
+	for {
+		if len(pending) == 0 {
+			select {
+			case data := <-c.Send:
+				var f receivedFrame
+				json.Unmarshal(data, &f)
+				if f.Type == "test" {
+					t.Fatalf("user %d: unexpected event %s", c.ID, data)
+				}
+				continue
+			case <-time.After(200 * time.Millisecond):
+				return
+			}
+		}
+		select {
+		case data := <-c.Send:
+			var f receivedFrame
+			if err := json.Unmarshal(data, &f); err != nil {
+				t.Fatalf("user %d: invalid frame %s", c.ID, data)
+			}
+			if f.Type != "test" {
+				continue // sync_complete
+			}
+			if seen[f.Seq] || !pending[f.N] {
+				t.Fatalf("user %d: event %d (seq %d) received twice or unexpected", c.ID, f.N, f.Seq)
+			}
+			seen[f.Seq] = true
+			delete(pending, f.N)
+		case <-timeout:
+			t.Fatalf("user %d: events %v not received", c.ID, pending)
+		}
+	}
+}
+
+func testEvent(n int) map[string]interface{} {
+	return map[string]interface{}{"type": "test", "n": n}
+}
+
+func waitSubscribed(t *testing.T, srv *redistest.Server, n int) {
+	t.Helper()
+	deadline := time.Now().Add(3 * time.Second)
+	for srv.Subscribers("social:"+subjectDeliver) != n {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d hubs subscribed, want %d", srv.Subscribers("social:"+subjectDeliver), n)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestTwoInstancesDeliverEachEventOnce(t *testing.T) {
+	srv := redistest.NewServer()
+	defer srv.Close()
+	events := newEventService(t)
+	a, b := newTestHub(t, srv, events), newTestHub(t, srv, events)
+	waitSubscribed(t, srv, 2)
+
+	// L'utilisateur 1 a un onglet sur chaque instance, l'utilisateur 2 est sur b
+	onA, onB, other := connect(a, 1), connect(b, 1), connect(b, 2)
+
+	a.SendEvent(1, testEvent(1))
+	b.SendEvent(1, testEvent(2))
+	a.SendEvent(2, testEvent(3))
+
+	// Réponse perdue après diffusion : le renvoi des événements numérotés ne crée pas de doublon
+	srv.LoseReplies(1)
+	a.SendEvent(1, testEvent(4))
+	b.SendEvent(1, testEvent(5))
+
+	// Publication perdue avant diffusion : les événements numérotés sont renvoyés
+	srv.DropPublishes(1)
+	b.SendEvent(1, testEvent(6))
+
+	expectEvents(t, onA, 1, 2, 4, 5, 6)
+	expectEvents(t, onB, 1, 2, 4, 5, 6)
+	expectEvents(t, other, 3)
+}
+
+// stalledBroker retient chaque publication jusqu'à ce que release soit fermé
+type stalledBroker struct {
+	*broker.MemoryBroker
+	release chan struct{}
+}
+
+func (b stalledBroker) Publish(ctx context.Context, subject string, data []byte) error {
+	<-b.release
+	return b.MemoryBroker.Publish(ctx, subject, data)
+}
+
+func TestSlowBrokerDoesNotBlockDelivery(t *testing.T) {
+	b := stalledBroker{MemoryBroker: broker.NewMemoryBroker(), release: make(chan struct{})}
+	h := NewHub(nil, nil, nil, nil, newEventService(t), nil, b)
+	c := connect(h, 1)
+
+	done := make(chan struct{})
+	go func() {
+		for n := 1; n <= 3; n++ {
+			h.SendEvent(1, testEvent(n))
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("SendEvent waited for the broker")
+	}
+
+	close(b.release)
+	expectEvents(t, c, 1, 2, 3)
+}
+
+func TestEventsMissedDuringResubscribeAreReplayed(t *testing.T) {
+	srv := redistest.NewServer()
+	defer srv.Close()
+	events := newEventService(t)
+	a, b := newTestHub(t, srv, events), newTestHub(t, srv, events)
+	waitSubscribed(t, srv, 2)
+
+	onA, onB := connect(a, 1), connect(b, 1)
+	a.SendEvent(1, testEvent(1))
+	expectEvents(t, onA, 1)
+	expectEvents(t, onB, 1)
+
+	// Abonnements coupés : les publications réussissent mais n'arrivent plus à personne
+	srv.RejectSubscriptions(true)
+	srv.DropSubscriptions()
+	waitSubscribed(t, srv, 0)
+	a.SendEvent(1, testEvent(2))
+	b.SendEvent(1, testEvent(3))
+
+	// Une fois réabonnées, les instances rattrapent les événements manqués depuis le journal
+	srv.RejectSubscriptions(false)
+	waitSubscribed(t, srv, 2)
+	a.SendEvent(1, testEvent(4))
+
+	expectEvents(t, onA, 2, 3, 4)
+	expectEvents(t, onB, 2, 3, 4)
+}
+
+func TestOutOfOrderEventsAreNotDropped(t *testing.T) {
+	h := NewHub(nil, nil, nil, nil, nil, nil, nil)
+	c := connect(h, 1)
+	c.lastSeq = 10
+
+	for _, seq := range []int64{12, 11, 12, 13, 11} {
+		c.push(h, seq, []byte{byte(seq)})
+	}
+	var got []byte
+	for len(c.Send) > 0 {
+		got = append(got, (<-c.Send)[0])
+	}
+	if string(got) != string([]byte{12, 11, 13}) {
+		t.Fatalf("sent seqs %v, want [12 11 13]", got)
+	}
+}
@@ -1,19 +1,36 @@
 package hub
 
 import (
-	"encoding/json"
 	"log"
+	"sync"
 	"time"
 
-	"social/models"
-
 	"github.com/gorilla/websocket"
 )
 
 type Client struct {
-	ID   int
-	Conn *websocket.Conn
-	Send chan []byte
+	ID     int
+	ConnID string // identifie cette connexion parmi celles de l'utilisateur
+	Conn   *websocket.Conn
+	Send   chan []byte
+
+	version int  // protocolLegacy, ou protocolV2 négocié avec /ws?v=2
+	sse     bool // flux /api/events/stream : pas de Conn, les événements sont écrits par ServeSSE
+
+	closeOnce sync.Once
+
+	// Rattrapage /ws?since= : tant que syncing est vrai, les événements en direct attendent dans pending
+	syncMu     sync.Mutex
+	syncing    bool
+	replayFrom int64
+	pending    []queuedEvent
+	lastSeq    int64              // plus grand seq envoyé sur la connexion
+	missing    map[int64]struct{} // seq inférieurs à lastSeq pas encore reçus (publiés dans le désordre)
+}
+
+// closeSend ferme Send une seule fois, même si le hub et safeSend le ferment tous les deux
+func (c *Client) closeSend() {
+	c.closeOnce.Do(func() { close(c.Send) })
 }
 
 const (
@@ -50,43 +67,25 @@ func (c *Client) readPump(hub *Hub) {
 			break
 		}
 
-		var msg models.Message
-		if err := json.Unmarshal(msgBytes, &msg); err != nil {
-			log.Printf("Invalid message JSON: %v, Raw: %s", err, string(msgBytes))
-			continue
-		}
-
-		log.Printf("📥 Message received from client %d: Type=%s, To=%d, GroupID=%d, Content=%q",
-			c.ID, msg.Type, msg.To, msg.GroupID, msg.Content)
-
-		// Validate required fields based on message type
-		if msg.Type == "" {
-			log.Printf("❌ Missing message type from client %d", c.ID)
-			continue
+		req, err := c.decode(msgBytes)
+		if err == nil {
+			log.Printf("📥 Message received from client %d: Type=%s, To=%d, GroupID=%d, Content=%q",
+				c.ID, req.msg.Type, req.msg.To, req.msg.GroupID, req.msg.Content)
+			c.stamp(&req.msg)
+			err = c.validate(&req.msg)
 		}
-
-		if msg.Type == "private" {
-			if msg.From == 0 || msg.To == 0 || msg.Content == "" {
-				log.Printf("❌ Missing required private message fields - From: %d, To: %d, Content: %q", msg.From, msg.To, msg.Content)
-				continue
-			}
-		} else if msg.Type == "group_message" {
-			if msg.From == 0 || msg.GroupID == 0 || msg.Content == "" {
-				log.Printf("❌ Missing required group message fields - From: %d, GroupID: %d", msg.From, msg.GroupID)
-				continue
-			}
+		if err == nil {
+			err = c.limit(hub.limiter, &req.msg)
 		}
-
-		// Force correct sender ID and add timestamp
-		msg.From = c.ID
-		parsedTime, err := time.Parse(time.RFC3339, msg.Timestamp)
-		if err != nil || parsedTime.IsZero() {
-			parsedTime = time.Now()
+		if err != nil {
+			// Trame refusée avant le hub (invalide ou hors budget) : le client v2 reçoit une erreur, le client historique rien
+			log.Printf("❌ Message refused from client %d: %v, Raw: %s", c.ID, err, string(msgBytes))
+			hub.replyError(req, err)
+			continue
 		}
-		msg.Timestamp = parsedTime.String()
 
-		log.Printf("✅ Message validated and forwarded to hub - From: %d, Type: %s", msg.From, msg.Type)
-		hub.Broadcast <- msg
+		log.Printf("✅ Message validated and forwarded to hub - From: %d, Type: %s", req.msg.From, req.msg.Type)
+		hub.requests <- req
 	}
 }
 
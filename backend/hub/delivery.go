@@ -0,0 +1,300 @@
+package hub
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"social/models"
+)
+
+// outgoing est un événement à livrer à un utilisateur ; kind remplace le type lu dans l'événement
+// pour l'enveloppe v2 (les notifications gardent leur type précis dans le payload)
+type outgoing struct {
+	userID int
+	event  interface{}
+	kind   string
+}
+
+// queuedEvent est un événement arrivé pendant le rattrapage d'une connexion
+type queuedEvent struct {
+	seq  int64
+	data []byte
+}
+
+// maxSeqGap borne les seq manquants suivis par connexion : un événement plus en retard
+// que cela sur le dernier envoyé n'est plus attendu en direct
+const maxSeqGap = 256
+
+// deliver numérote, journalise puis envoie un événement à userID (s'il est connecté).
+// Un utilisateur hors ligne le recevra en se reconnectant avec /ws?since=<seq>.
+func (h *Hub) deliver(userID int, event interface{}) {
+	h.deliverAll([]outgoing{{userID: userID, event: event}})
+}
+
+// deliverAll fait de même pour plusieurs destinataires, journalisés dans une seule transaction
+func (h *Hub) deliverAll(out []outgoing) {
+	events := make([]*models.UserEvent, 0, len(out))
+	for _, o := range out {
+		payload, err := json.Marshal(o.event)
+		if err != nil {
+			fmt.Printf("❌ Failed to marshal event: %v\n", err)
+			continue
+		}
+		events = append(events, &models.UserEvent{UserID: o.userID, Type: o.eventType(payload), Payload: payload})
+	}
+
+	// Numérotation et mise en file sous le même verrou : les événements d'une instance partent dans l'ordre
+	// des seq, sans attendre le broker
+	h.deliverMu.Lock()
+	defer h.deliverMu.Unlock()
+
+	if h.eventService != nil {
+		if err := h.eventService.Record(events); err != nil {
+			// L'événement part quand même en direct, sans seq : il ne pourra simplement pas être rejoué
+			fmt.Printf("❌ Failed to record events: %v\n", err)
+		}
+	}
+
+	wire := make([]wireEvent, 0, len(events))
+	for _, e := range events {
+		wire = append(wire, wireEvent{UserID: e.UserID, Type: e.Type, Seq: e.Seq, Data: e.Payload})
+	}
+	h.publish(wire)
+}
+
+// sendEphemeral envoie un événement éphémère (frappe, présence) sans le numéroter ni le journaliser :
+// il n'aurait plus de sens une fois rejoué
+func (h *Hub) sendEphemeral(userID int, event interface{}) {
+	h.sendEphemeralAll([]outgoing{{userID: userID, event: event}})
+}
+
+// sendEphemeralAll fait de même pour plusieurs destinataires, en une seule publication
+func (h *Hub) sendEphemeralAll(out []outgoing) {
+	wire := make([]wireEvent, 0, len(out))
+	for _, o := range out {
+		data, err := json.Marshal(o.event)
+		if err != nil {
+			fmt.Printf("❌ Failed to marshal event: %v\n", err)
+			continue
+		}
+		wire = append(wire, wireEvent{UserID: o.userID, Type: o.eventType(data), Data: data})
+	}
+	h.publish(wire)
+}
+
+// eventType retourne kind, ou à défaut le champ type de l'événement sérialisé
+func (o outgoing) eventType(payload []byte) string {
+	if o.kind != "" {
+		return o.kind
+	}
+	var head struct {
+		Type string `json:"type"`
+	}
+	json.Unmarshal(payload, &head)
+	return head.Type
+}
+
+// withSeq ajoute le champ seq en tête de l'objet JSON payload
+func withSeq(payload []byte, seq int64) []byte {
+	if len(payload) < 2 || payload[0] != '{' {
+		return payload
+	}
+	out := make([]byte, 0, len(payload)+24)
+	out = append(out, `{"seq":`...)
+	out = strconv.AppendInt(out, seq, 10)
+	if len(payload) > 2 {
+		out = append(out, ',')
+	}
+	return append(out, payload[1:]...)
+}
+
+// push envoie un événement numéroté à la connexion ; pendant un rattrapage il est mis en attente,
+// et un événement déjà envoyé (rejoué, ou reçu deux fois du broker) n'est pas renvoyé
+func (c *Client) push(h *Hub, seq int64, data []byte) {
+	c.syncMu.Lock()
+	if c.syncing {
+		c.pending = append(c.pending, queuedEvent{seq: seq, data: data})
+		c.syncMu.Unlock()
+		return
+	}
+	fresh := c.markSeq(seq)
+	c.syncMu.Unlock()
+
+	if fresh {
+		h.safeSend(c, data)
+	}
+}
+
+// markSeq note que seq est envoyé sur la connexion et retourne false s'il l'a déjà été
+// (syncMu tenu). Deux instances pouvant publier pour le même utilisateur, les seq arrivent
+// parfois dans le désordre : ceux sautés restent attendus dans missing.
+func (c *Client) markSeq(seq int64) bool {
+	if seq <= 0 {
+		return true
+	}
+	if seq > c.lastSeq {
+		if c.missing == nil {
+			c.missing = make(map[int64]struct{})
+		}
+		for s := max(c.lastSeq+1, seq-maxSeqGap); s < seq; s++ {
+			c.missing[s] = struct{}{}
+		}
+		for s := range c.missing {
+			if s <= seq-maxSeqGap {
+				delete(c.missing, s)
+			}
+		}
+		c.lastSeq = seq
+		return true
+	}
+	if _, ok := c.missing[seq]; ok {
+		delete(c.missing, seq)
+		return true
+	}
+	return false
+}
+
+// skipTo renonce aux événements jusqu'à seq (purgés du journal) ; syncMu tenu
+func (c *Client) skipTo(seq int64) {
+	c.lastSeq = max(c.lastSeq, seq)
+	for s := range c.missing {
+		if s <= seq {
+			delete(c.missing, s)
+		}
+	}
+}
+
+// resumeFrom positionne une nouvelle connexion dans le journal : rattrapage depuis since s'il est
+// donné (/ws?since=, Last-Event-ID), sinon direct à partir du dernier seq attribué
+func (h *Hub) resumeFrom(c *Client, since string) {
+	if h.eventService == nil {
+		return
+	}
+	if since != "" {
+		if seq, err := strconv.ParseInt(since, 10, 64); err == nil && seq >= 0 {
+			c.syncing = true
+			c.replayFrom = seq
+			c.lastSeq = seq
+			return
+		}
+	}
+	last, err := h.eventService.LastSeq(c.ID)
+	if err != nil {
+		fmt.Printf("❌ Failed to load last seq of user %d: %v\n", c.ID, err)
+	}
+	c.lastSeq = last
+}
+
+// resync rejoue aux connexions locales ce qu'elles ont manqué pendant une coupure de
+// l'abonnement au broker : les événements publiés entre-temps ne sont pas arrivés ici
+func (h *Hub) resync() {
+	if h.eventService == nil {
+		return
+	}
+	h.clientsMu.RLock()
+	var clients []*Client
+	for _, conns := range h.Clients {
+		for _, c := range conns {
+			clients = append(clients, c)
+		}
+	}
+	h.clientsMu.RUnlock()
+
+	fmt.Printf("🔁 Broker subscription restored, resyncing %d connections\n", len(clients))
+	for _, c := range clients {
+		c.syncMu.Lock()
+		if c.syncing {
+			// Déjà en rattrapage : il lira le journal de toute façon
+			c.syncMu.Unlock()
+			continue
+		}
+		since := c.lastSeq
+		for s := range c.missing {
+			since = min(since, s-1)
+		}
+		c.syncing = true
+		c.syncMu.Unlock()
+
+		go h.replay(c, since)
+	}
+}
+
+// replay rejoue les événements journalisés après since que la connexion n'a pas encore reçus,
+// puis ceux arrivés pendant le rattrapage, avant de laisser passer le trafic en direct
+func (h *Hub) replay(c *Client, since int64) {
+	last := since
+	defer h.finishSync(c)
+
+	truncated, oldest, err := h.eventService.Truncated(c.ID, since)
+	if err != nil {
+		fmt.Printf("❌ Failed to check event log of user %d: %v\n", c.ID, err)
+		return
+	}
+	if truncated {
+		c.syncMu.Lock()
+		c.skipTo(oldest - 1)
+		c.syncMu.Unlock()
+		data, _ := json.Marshal(models.SyncEvent{Type: "sync_truncated", Seq: since, OldestSeq: oldest})
+		if !c.replaySend(c.frame("sync_truncated", 0, data)) {
+			return
+		}
+	}
+
+	for {
+		events, err := h.eventService.Since(c.ID, last)
+		if err != nil {
+			fmt.Printf("❌ Failed to load missed events of user %d: %v\n", c.ID, err)
+			return
+		}
+		if len(events) == 0 {
+			break
+		}
+		for _, e := range events {
+			last = e.Seq
+			c.syncMu.Lock()
+			fresh := c.markSeq(e.Seq)
+			c.syncMu.Unlock()
+			if fresh && !c.replaySend(c.frame(e.Type, e.Seq, e.Payload)) {
+				return
+			}
+		}
+	}
+	fmt.Printf("🔁 Replayed events %d..%d to user %d\n", since+1, last, c.ID)
+}
+
+// finishSync envoie les événements mis en attente pendant le rattrapage (ceux qui n'ont pas déjà
+// été rejoués), signale la fin du rattrapage et repasse la connexion en direct
+func (h *Hub) finishSync(c *Client) {
+	c.syncMu.Lock()
+	defer c.syncMu.Unlock()
+
+	for _, q := range c.pending {
+		if c.markSeq(q.seq) {
+			h.safeSend(c, q.data)
+		}
+	}
+	c.pending = nil
+	c.syncing = false
+
+	data, _ := json.Marshal(models.SyncEvent{Type: "sync_complete", Seq: c.lastSeq})
+	h.safeSend(c, c.frame("sync_complete", 0, data))
+}
+
+// replaySend envoie un événement rejoué en attendant la place dans le buffer de la connexion ;
+// false si elle est fermée ou bloquée
+func (c *Client) replaySend(data []byte) (ok bool) {
+	defer func() {
+		if recover() != nil {
+			ok = false
+		}
+	}()
+
+	select {
+	case c.Send <- data:
+		return true
+	case <-time.After(writeWait):
+		return false
+	}
+}
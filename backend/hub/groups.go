@@ -0,0 +1,81 @@
+package hub
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"social/models"
+)
+
+// subjectGroups invalide le cache des membres d'un groupe sur toutes les instances
+const subjectGroups = "hub.groups"
+
+var errNotGroupMember = &frameErr{code: codeForbidden, message: "not a member of this group"}
+
+// OnMembershipChange est abonné aux changements d'adhésion de GroupService : le cache des membres
+// du groupe est invalidé partout, puis les personnes concernées reçoivent l'événement
+func (h *Hub) OnMembershipChange(event models.GroupMembershipEvent) {
+	h.InvalidateGroupMembers(event.GroupID)
+
+	recipients := map[int]bool{event.UserID: true, event.ActorID: true, event.CreatorID: true}
+	switch event.Type {
+	case models.GroupMemberJoined, models.GroupMemberLeft, models.GroupMemberRemoved:
+		// Tous les membres mettent à jour la liste ; celui qui part la reçoit aussi (ses autres appareils)
+		members, err := h.GetGroupMembers(event.GroupID)
+		if err != nil {
+			fmt.Printf("❌ Failed to get group members: %v\n", err)
+		}
+		for _, id := range members {
+			recipients[id] = true
+		}
+	}
+	delete(recipients, 0)
+
+	out := make([]outgoing, 0, len(recipients))
+	for id := range recipients {
+		out = append(out, outgoing{userID: id, event: event})
+	}
+	h.deliverAll(out)
+	fmt.Printf("👥 %s: user %d in group %d (%d notified)\n", event.Type, event.UserID, event.GroupID, len(out))
+}
+
+// InvalidateGroupMembers oublie les membres en cache du groupe, ici tout de suite puis sur les
+// autres instances via le broker ; ils seront relus à la prochaine utilisation
+func (h *Hub) InvalidateGroupMembers(groupID int) {
+	h.forgetGroupMembers(groupID)
+
+	data, _ := json.Marshal(groupID)
+	ctx, cancel := context.WithTimeout(context.Background(), publishTimeout)
+	defer cancel()
+	if err := h.broker.Publish(ctx, subjectGroups, data); err != nil {
+		fmt.Printf("❌ Failed to publish group %d invalidation: %v\n", groupID, err)
+	}
+}
+
+func (h *Hub) onGroupInvalidated(data []byte) {
+	var groupID int
+	if err := json.Unmarshal(data, &groupID); err == nil {
+		h.forgetGroupMembers(groupID)
+	}
+}
+
+func (h *Hub) forgetGroupMembers(groupID int) {
+	h.cacheMutex.Lock()
+	delete(h.groupMembersCache, groupID)
+	h.cacheMutex.Unlock()
+}
+
+// isGroupMember vérifie l'appartenance à partir du cache des membres
+func (h *Hub) isGroupMember(groupID, userID int) (bool, error) {
+	members, err := h.GetGroupMembers(groupID)
+	if err != nil {
+		return false, err
+	}
+	for _, id := range members {
+		if id == userID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
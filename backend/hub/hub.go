@@ -1,131 +1,114 @@
 package hub
 
 import (
-	"encoding/json"
 	"fmt"
 	"sync"
 
+	"social/broker"
 	"social/models"
 	"social/services"
 )
 
 type Hub struct {
-	Clients    map[int]*Client
+	Clients    map[int]map[string]*Client // userID -> connexions (onglets, appareils) par ConnID
+	clientsMu  sync.RWMutex               // Clients est lu depuis les handlers HTTP pendant que Run l'écrit
 	Register   chan *Client
 	Unregister chan *Client
-	Broadcast  chan models.Message
+	Broadcast  chan models.Message // messages injectés côté serveur, sans réponse
+	requests   chan request        // trames des clients, qui reçoivent un ack ou une erreur
 	services   *Handler
 	// Add group members cache
 	groupMembersCache map[int][]int // groupID -> []userIDs
 	cacheMutex        sync.RWMutex
 	messageService    *services.ChatService
+	roomService       *services.ChatRoomService
+	muteService       *services.MuteService
+	presenceService   *services.PresenceService
+	eventService      *services.EventService
+	limiter           *services.RateLimitService // budgets d'envoi par utilisateur
+	deliverMu         sync.Mutex                 // numérotation + mise en file des événements journalisés
+	outbox            chan []wireEvent           // lots à publier, dans l'ordre (runPublisher)
+	broker            broker.Broker              // diffusion des événements entre instances du backend
+	instanceID        string
 }
 
-func NewHub(messageService *services.ChatService) *Hub {
-	return &Hub{
-		Clients:           make(map[int]*Client),
+// NewHub crée le hub ; b relaie les événements entre instances (nil : instance unique, en mémoire),
+// limiter applique les budgets d'envoi (nil : pas de limite)
+func NewHub(messageService *services.ChatService, roomService *services.ChatRoomService, muteService *services.MuteService, presenceService *services.PresenceService, eventService *services.EventService, limiter *services.RateLimitService, b broker.Broker) *Hub {
+	if b == nil {
+		b = broker.NewMemoryBroker()
+	}
+	h := &Hub{
+		Clients:           make(map[int]map[string]*Client),
 		Register:          make(chan *Client),
 		Unregister:        make(chan *Client),
 		Broadcast:         make(chan models.Message),
+		requests:          make(chan request),
 		groupMembersCache: make(map[int][]int),
 		messageService:    messageService,
+		roomService:       roomService,
+		muteService:       muteService,
+		presenceService:   presenceService,
+		eventService:      eventService,
+		limiter:           limiter,
+		broker:            b,
+		outbox:            make(chan []wireEvent, publishQueueSize),
+		instanceID:        newConnID(),
 	}
+	h.subscribe()
+	go h.runPublisher()
+	return h
 }
 
 func (h *Hub) Run() {
+	go h.syncPresence()
+
 	for {
 		select {
 		case client := <-h.Register:
-			h.Clients[client.ID] = client
-			fmt.Printf("\n✅ === USER REGISTERED === \n")
-			fmt.Printf("   User ID: %d\n", client.ID)
-			fmt.Printf("   Total connected users: %d\n", len(h.Clients))
-			fmt.Printf("   Connected user IDs: %v\n\n", func() []int {
-				ids := make([]int, 0)
-				for id := range h.Clients {
-					ids = append(ids, id)
-				}
-				return ids
-			}())
-
-		case client := <-h.Unregister:
-			delete(h.Clients, client.ID)
-			close(client.Send)
-
-		case msg := <-h.Broadcast:
-			fmt.Printf("📨 Broadcast received - Type: %s, From: %d, To: %d\n", msg.Type, msg.From, msg.To)
-			fmt.Printf("🔍 Current connected clients: %v\n", func() []int {
-				ids := make([]int, 0)
-				for id := range h.Clients {
-					ids = append(ids, id)
-				}
-				return ids
-			}())
+			h.addClient(client)
 
-			msgBytes, err := json.Marshal(msg)
-			if err != nil {
-				fmt.Println("❌ Failed to marshal message:", err)
-				continue
+			// Reconnexion avec /ws?since= : rejouer ce qui a été manqué avant le direct
+			if client.syncing {
+				go h.replay(client, client.replayFrom)
 			}
 
-			switch msg.Type {
-			case "private":
-				// Process private message
-				if err := h.messageService.ProcessPrivateMessage(msg); err != nil {
-					fmt.Println("❌ Error processing private message:", err)
-					continue
-				}
-
-				// Send to recipient if connected
-				if recipient, ok := h.Clients[msg.To]; ok {
-					h.safeSend(recipient, msgBytes)
-				} else {
-					fmt.Printf("⚠️ Recipient user %d not connected\n", msg.To)
+			if h.presenceService != nil {
+				if h.presenceService.Connect(client.ID) {
+					go h.broadcastPresence(client.ID, models.PresenceOnline, nil)
 				}
+				go h.publishPresence(client.ID)
+			}
 
-				// IMPORTANT: Also send confirmation back to sender for real-time display
-				// This ensures the sender sees the message immediately
-				if sender, ok := h.Clients[msg.From]; ok {
-					h.safeSend(sender, msgBytes)
-				} else {
-					fmt.Printf("⚠️ Sender user %d not connected (no confirmation sent)\n", msg.From)
-				}
+			fmt.Printf("\n✅ === USER REGISTERED === \n")
+			fmt.Printf("   User ID: %d (connection %s)\n", client.ID, client.ConnID)
+			ids := h.connectedIDs()
+			fmt.Printf("   Total connected users: %d\n", len(ids))
+			fmt.Printf("   Connected user IDs: %v\n\n", ids)
 
-			case "group_message":
-				// Process group message
-				if err := h.messageService.ProcessGroupMessage(msg); err != nil {
-					fmt.Println("Error processing group message:", err)
-					continue
-				}
+		case client := <-h.Unregister:
+			h.removeClient(client)
+			client.closeSend()
 
-				// Get group members from cache or service
-				members, err := h.GetGroupMembers(msg.GroupID)
+			if h.presenceService != nil {
+				offline, lastSeen, err := h.presenceService.Disconnect(client.ID)
 				if err != nil {
-					fmt.Printf("❌ Failed to get group members: %v\n", err)
-					continue
+					fmt.Printf("❌ Failed to save last seen for user %d: %v\n", client.ID, err)
 				}
-				fmt.Println("member", members)
-
-				// Broadcast to all connected group members (including sender)
-				for _, memberID := range members {
-					if client, ok := h.Clients[memberID]; ok {
-						msgCopy := msg
-						msgCopy.To = memberID
-						msgBytesToSend, err := json.Marshal(msgCopy)
-						if err != nil {
-							fmt.Println("❌ Failed to marshal message for member:", err)
-							continue
-						}
-						h.safeSend(client, msgBytesToSend)
-					} else {
-						fmt.Printf("⚠️ User %d not connected\n", memberID)
-					}
+				if offline {
+					go h.broadcastPresence(client.ID, models.PresenceOffline, &lastSeen)
 				}
-				fmt.Printf("✅ Group message broadcast to %d members of group %d\n", len(members), msg.GroupID)
-
-			default:
-				fmt.Printf("❌ Unknown message type: %s\n", msg.Type)
+				go h.publishPresence(client.ID)
 			}
+
+		case req := <-h.requests:
+			fmt.Printf("📨 Message received - Type: %s, From: %d, To: %d\n", req.msg.Type, req.msg.From, req.msg.To)
+			h.dispatch(req)
+
+		case msg := <-h.Broadcast:
+			fmt.Printf("📨 Broadcast received - Type: %s, From: %d, To: %d\n", msg.Type, msg.From, msg.To)
+			fmt.Printf("🔍 Current connected clients: %v\n", h.connectedIDs())
+			h.dispatch(request{msg: msg})
 		}
 	}
 }
@@ -162,24 +145,65 @@ func (h *Hub) GetGroupMembers(groupID int) ([]int, error) {
 
 // After inserting notification in DB, fetch it and send:
 func (h *Hub) SendNotification(notification models.Notification, toID int) {
-	msgBytes, _ := json.Marshal(notification)
-	fmt.Println("message that will be sent :", string(msgBytes))
-	if recipient, ok := h.Clients[toID]; ok {
-		h.safeSend(recipient, msgBytes)
+	// Notifications from muted users are silently dropped
+	if h.muteService != nil && !h.muteService.ShouldNotify(toID, notification.SenderID) {
+		return
 	}
+	h.deliverAll([]outgoing{{userID: toID, event: notification, kind: frameNotification}})
 }
 
 func (h *Hub) SendMessageToUser(userID int, message models.Message) {
-	msgBytes, err := json.Marshal(message)
-	if err != nil {
-		fmt.Printf("❌ Failed to marshal message: %v\n", err)
-		return
+	h.deliver(userID, message)
+}
+
+// SendEvent pousse un événement JSON arbitraire (changement d'état, pas une notification) à userID ;
+// il est numéroté et journalisé comme les messages
+func (h *Hub) SendEvent(userID int, event interface{}) {
+	h.deliver(userID, event)
+}
+
+// addClient ajoute une connexion à celles de son utilisateur
+func (h *Hub) addClient(client *Client) {
+	h.clientsMu.Lock()
+	defer h.clientsMu.Unlock()
+	if h.Clients[client.ID] == nil {
+		h.Clients[client.ID] = make(map[string]*Client)
+	}
+	h.Clients[client.ID][client.ConnID] = client
+}
+
+// getClients retourne toutes les connexions ouvertes de userID
+func (h *Hub) getClients(userID int) []*Client {
+	h.clientsMu.RLock()
+	defer h.clientsMu.RUnlock()
+	conns := h.Clients[userID]
+	clients := make([]*Client, 0, len(conns))
+	for _, client := range conns {
+		clients = append(clients, client)
+	}
+	return clients
+}
+
+func (h *Hub) connectedIDs() []int {
+	h.clientsMu.RLock()
+	defer h.clientsMu.RUnlock()
+	ids := make([]int, 0, len(h.Clients))
+	for id := range h.Clients {
+		ids = append(ids, id)
 	}
+	return ids
+}
 
-	if client, ok := h.Clients[userID]; ok {
-		h.safeSend(client, msgBytes)
-	} else {
-		fmt.Printf("⚠️ User %d not connected\n", userID)
+// removeClient retire cette connexion (et seulement elle) des connexions de son utilisateur
+func (h *Hub) removeClient(client *Client) {
+	h.clientsMu.Lock()
+	defer h.clientsMu.Unlock()
+	conns := h.Clients[client.ID]
+	if current, ok := conns[client.ConnID]; ok && current == client {
+		delete(conns, client.ConnID)
+		if len(conns) == 0 {
+			delete(h.Clients, client.ID)
+		}
 	}
 }
 
@@ -190,12 +214,8 @@ func (h *Hub) safeSend(client *Client, data []byte) {
 		if r := recover(); r != nil {
 			fmt.Printf("❌ Recovered panic sending to client %d: %v\n", client.ID, r)
 			// Ensure channel closed and remove client
-			select {
-			default:
-				// best-effort close
-				close(client.Send)
-			}
-			delete(h.Clients, client.ID)
+			client.closeSend()
+			h.removeClient(client)
 		}
 	}()
 
@@ -205,8 +225,8 @@ func (h *Hub) safeSend(client *Client, data []byte) {
 	default:
 		fmt.Printf("⚠️ Failed to send to user %d (channel full)\n", client.ID)
 		// best-effort cleanup
-		close(client.Send)
-		delete(h.Clients, client.ID)
+		client.closeSend()
+		h.removeClient(client)
 	}
 }
 
@@ -1,9 +1,12 @@
 package hub
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
 	"log"
 	"net/http"
+	"time"
 
 	"social/models"
 	"social/services"
@@ -69,48 +72,46 @@ func (h *Handler) ServeWS(hub *Hub, w http.ResponseWriter, r *http.Request) {
 		log.Printf("✅ Authentication via session cookie - userID: %d\n", userID)
 	}
 
+	// /ws?v=2 : trames en enveloppe, avec ack et erreurs typées ; sinon format historique
+	version := protocolLegacy
+	switch r.URL.Query().Get("v") {
+	case "", "1":
+	case "2":
+		version = protocolV2
+	default:
+		log.Printf("❌ Unsupported protocol version: %s\n", r.URL.Query().Get("v"))
+		conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseUnsupportedData, "Unsupported protocol version"))
+		conn.Close()
+		return
+	}
+
 	client := &Client{
-		ID:   userID,
-		Conn: conn,
-		Send: make(chan []byte, 256),
+		ID:      userID,
+		ConnID:  newConnID(),
+		Conn:    conn,
+		Send:    make(chan []byte, 256),
+		version: version,
 	}
 
-	hub.Register <- client
+	// /ws?since=<seq> : les événements manqués depuis seq sont rejoués avant le direct
+	hub.resumeFrom(client, r.URL.Query().Get("since"))
 
-	go h.notifyGroupMembersOfOnlineStatus(userID, hub)
+	// Le hub prévient les groupes et contacts privés quand l'utilisateur passe en ligne
+	hub.Register <- client
 
 	go client.writePump()
 	go client.readPump(hub)
 }
 
-func (h *Handler) notifyGroupMembersOfOnlineStatus(userID int, hub *Hub) {
-	groups, err := h.group.GetGroupsForUser(userID)
-	if err != nil {
-		log.Printf("Error getting groups for user %d: %v", userID, err)
-		return
-	}
-
-	for _, group := range groups {
-		members, err := h.group.GetGroupMembers(group.ID)
-		if err != nil {
-			log.Printf("Error getting members for group %d: %v", group.ID, err)
-			continue
-		}
-
-		onlineStatusMsg := models.Message{
-			Type:    "user_online",
-			From:    userID,
-			GroupID: group.ID,
-		}
-
-		for _, member := range members {
-			if member.ID != userID {
-				hub.SendMessageToUser(member.ID, onlineStatusMsg)
-			}
-		}
-	}
-}
-
 func (h *Handler) GetGroupMembers(id int) ([]models.GroupMember, error) {
 	return h.group.GetGroupMembers(id)
 }
+
+// newConnID génère un identifiant aléatoire de connexion
+func newConnID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
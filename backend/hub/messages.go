@@ -0,0 +1,69 @@
+package hub
+
+import (
+	"fmt"
+
+	"social/models"
+	"social/services"
+)
+
+// EditMessage modifie un message privé (groupID = 0) ou de groupe et diffuse message_edited
+func (h *Hub) EditMessage(userID, messageID, groupID int, content string) (models.MessageUpdate, error) {
+	update, err := h.messageService.EditMessage(userID, messageID, groupID, content)
+	if err != nil {
+		return update, err
+	}
+	h.deliverMessageUpdate(update)
+	return update, nil
+}
+
+// DeleteMessage supprime un message privé (groupID = 0) ou de groupe et diffuse message_deleted
+func (h *Hub) DeleteMessage(userID, messageID, groupID int) (models.MessageUpdate, error) {
+	update, err := h.messageService.DeleteMessage(userID, messageID, groupID)
+	if err != nil {
+		return update, err
+	}
+	h.deliverMessageUpdate(update)
+	return update, nil
+}
+
+// React ajoute (add) ou retire une réaction sur un message privé (groupID = 0) ou de groupe
+// et diffuse reaction_added / reaction_removed ; rien n'est diffusé si la réaction était déjà dans cet état
+func (h *Hub) React(userID, messageID, groupID int, emoji string, add bool) (models.MessageUpdate, error) {
+	if groupID != 0 {
+		member, err := h.isGroupMember(groupID, userID)
+		if err != nil {
+			return models.MessageUpdate{}, fmt.Errorf("failed to get group members: %w", err)
+		}
+		if !member {
+			return models.MessageUpdate{}, services.ErrNotGroupMember
+		}
+	}
+
+	update, changed, err := h.messageService.React(userID, messageID, groupID, emoji, add)
+	if err != nil || !changed {
+		return update, err
+	}
+	h.deliverMessageUpdate(update)
+	return update, nil
+}
+
+// deliverMessageUpdate envoie la modification aux deux participants d'un message privé
+// ou à tous les membres connectés du groupe
+func (h *Hub) deliverMessageUpdate(update models.MessageUpdate) {
+	if update.GroupID == 0 {
+		h.deliverAll([]outgoing{{userID: update.From, event: update}, {userID: update.To, event: update}})
+		return
+	}
+
+	members, err := h.GetGroupMembers(update.GroupID)
+	if err != nil {
+		fmt.Printf("❌ Failed to get group members: %v\n", err)
+		return
+	}
+	out := make([]outgoing, len(members))
+	for i, memberID := range members {
+		out[i] = outgoing{userID: memberID, event: update}
+	}
+	h.deliverAll(out)
+}
@@ -0,0 +1,72 @@
+package hub
+
+import (
+	"fmt"
+	"time"
+
+	"social/models"
+)
+
+var presenceEventTypes = map[string]string{
+	models.PresenceOnline:  "user_online",
+	models.PresenceAway:    "user_away",
+	models.PresenceOffline: "user_offline",
+}
+
+// broadcastPresence prévient les membres des groupes de userID (un événement par groupe commun,
+// comme le chat de groupe l'attend) et ses contacts privés de son nouveau statut
+func (h *Hub) broadcastPresence(userID int, status string, lastSeen *time.Time) {
+	watchers, err := h.presenceService.GetWatchers(userID)
+	if err != nil {
+		fmt.Printf("❌ Failed to get presence watchers for user %d: %v\n", userID, err)
+		return
+	}
+
+	out := make([]outgoing, 0, len(watchers))
+	for _, w := range watchers {
+		out = append(out, outgoing{userID: w.UserID, event: models.PresenceEvent{
+			Type:     presenceEventTypes[status],
+			From:     userID,
+			GroupID:  w.GroupID,
+			Status:   status,
+			LastSeen: lastSeen,
+		}})
+	}
+	h.sendEphemeralAll(out)
+}
+
+// relayTyping transmet typing_start / typing_stop au destinataire privé ou aux membres du groupe,
+// si l'expéditeur y est autorisé ; un événement envoyé trop souvent est ignoré sans erreur
+func (h *Hub) relayTyping(msg models.Message) error {
+	if h.presenceService == nil {
+		return nil
+	}
+
+	event := models.TypingEvent{Type: msg.Type, From: msg.From, To: msg.To, GroupID: msg.GroupID}
+	if event.GroupID != 0 {
+		event.To = 0
+	}
+
+	relay, err := h.presenceService.AllowTyping(msg.From, event)
+	if err != nil || !relay {
+		return err
+	}
+
+	if event.GroupID == 0 {
+		h.sendEphemeral(event.To, event)
+		return nil
+	}
+
+	members, err := h.GetGroupMembers(event.GroupID)
+	if err != nil {
+		return fmt.Errorf("failed to get group members: %w", err)
+	}
+	out := make([]outgoing, 0, len(members))
+	for _, memberID := range members {
+		if memberID != msg.From {
+			out = append(out, outgoing{userID: memberID, event: event})
+		}
+	}
+	h.sendEphemeralAll(out)
+	return nil
+}
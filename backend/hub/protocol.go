@@ -0,0 +1,406 @@
+package hub
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"social/models"
+	"social/services"
+)
+
+// Versions du protocole : sans ?v=2, les trames restent au format historique (models.Message à plat,
+// événements sans enveloppe, ni ack ni erreur)
+const (
+	protocolLegacy = 1
+	protocolV2     = 2
+)
+
+// Codes des trames error
+const (
+	codeBadRequest         = "bad_request" // trame illisible ou champs invalides
+	codeUnsupportedVersion = "unsupported_version"
+	codeUnknownType        = "unknown_type"
+	codeForbidden          = "forbidden"
+	codeNotFound           = "not_found"
+	codeEditWindowExpired  = "edit_window_expired"
+	codeInternal           = "internal_error"
+	codeRateLimited        = services.LimitRateExceeded     // budget d'envoi épuisé, voir retry_after_ms
+	codeDuplicateContent   = services.LimitDuplicateContent // même contenu envoyé trop de fois
+)
+
+// Types des trames envoyées par le serveur en dehors des événements eux-mêmes
+const (
+	frameAck          = "ack"
+	frameError        = "error"
+	frameNotification = "notification" // enveloppe des notifications, dont le type précis reste dans le payload
+)
+
+// request est une trame client à traiter par Run. client est nil pour un message injecté
+// côté serveur via Broadcast : il n'y a alors personne à qui répondre.
+type request struct {
+	client  *Client
+	frameID string
+	msg     models.Message
+}
+
+// messageType décrit un type de trame accepté des clients : validate contrôle les champs à la
+// lecture, handle traite la trame dans Run et retourne le contenu de l'ack. action est le budget
+// du limiteur consommé par la trame (vide : trame non limitée).
+type messageType struct {
+	validate func(c *Client, msg *models.Message) error
+	handle   func(h *Hub, msg *models.Message) (models.Ack, error)
+	action   string
+}
+
+// messageTypes est la liste des types que les clients peuvent envoyer ; tout autre type est refusé
+var messageTypes = map[string]messageType{
+	"private":         {validate: validateChatMessage, handle: (*Hub).handlePrivateMessage, action: services.ActionMessage},
+	"group_message":   {validate: validateChatMessage, handle: (*Hub).handleGroupMessage, action: services.ActionMessage},
+	"room_message":    {validate: validateChatMessage, handle: (*Hub).handleRoomMessage, action: services.ActionMessage},
+	"mark_read":       {validate: validateMarkRead, handle: (*Hub).handleMarkRead},
+	"edit_message":    {validate: validateMessageUpdate, handle: (*Hub).handleEditMessage, action: services.ActionMessage},
+	"delete_message":  {validate: validateMessageUpdate, handle: (*Hub).handleDeleteMessage},
+	"add_reaction":    {validate: validateReaction, handle: (*Hub).handleAddReaction, action: services.ActionMessage},
+	"remove_reaction": {validate: validateReaction, handle: (*Hub).handleRemoveReaction},
+	"typing_start":    {validate: validateTyping, handle: (*Hub).handleTyping},
+	"typing_stop":     {validate: validateTyping, handle: (*Hub).handleTyping},
+	"presence":        {handle: (*Hub).handlePresence},
+}
+
+// frameErr est une erreur de protocole renvoyée telle quelle au client
+type frameErr struct {
+	code    string
+	message string
+}
+
+func (e *frameErr) Error() string { return e.message }
+
+func badRequest(format string, args ...interface{}) error {
+	return &frameErr{code: codeBadRequest, message: fmt.Sprintf(format, args...)}
+}
+
+// decode lit une trame client : message historique à plat, ou enveloppe v2 dont le payload
+// porte les champs du message
+func (c *Client) decode(data []byte) (request, error) {
+	req := request{client: c}
+	if c.version < protocolV2 {
+		if err := json.Unmarshal(data, &req.msg); err != nil {
+			return req, badRequest("invalid JSON")
+		}
+		return req, nil
+	}
+
+	var env models.Envelope
+	if err := json.Unmarshal(data, &env); err != nil {
+		return req, badRequest("invalid JSON")
+	}
+	req.frameID = env.ID
+	req.msg.ClientID = env.ClientID
+	if env.V != protocolV2 {
+		return req, &frameErr{code: codeUnsupportedVersion, message: fmt.Sprintf("protocol version %d is not supported", env.V)}
+	}
+	if len(env.Payload) > 0 {
+		if err := json.Unmarshal(env.Payload, &req.msg); err != nil {
+			return req, badRequest("invalid payload")
+		}
+	}
+	req.msg.Type = env.Type
+	if env.ClientID != "" {
+		req.msg.ClientID = env.ClientID
+	}
+	return req, nil
+}
+
+// validate vérifie que le type est connu et que les champs requis sont présents
+func (c *Client) validate(msg *models.Message) error {
+	if msg.Type == "" {
+		return badRequest("missing message type")
+	}
+	t, ok := messageTypes[msg.Type]
+	if !ok {
+		return &frameErr{code: codeUnknownType, message: fmt.Sprintf("unknown message type %q", msg.Type)}
+	}
+	if t.validate == nil {
+		return nil
+	}
+	return t.validate(c, msg)
+}
+
+// limit consomme le budget du type de la trame ; un même contenu répété est aussi refusé
+func (c *Client) limit(limiter *services.RateLimitService, msg *models.Message) error {
+	t := messageTypes[msg.Type]
+	if limiter == nil || t.action == "" {
+		return nil
+	}
+	return limiter.AllowContent(c.ID, t.action, msg.Content)
+}
+
+func validateChatMessage(c *Client, msg *models.Message) error {
+	if msg.Type == "private" && msg.To == 0 {
+		return badRequest("private message needs a recipient")
+	}
+	if msg.Type == "group_message" && msg.GroupID == 0 {
+		return badRequest("group message needs a group")
+	}
+	if msg.Type == "room_message" && msg.RoomID == 0 {
+		return badRequest("room message needs a room")
+	}
+	if msg.Type == "room_message" && len(msg.AttachmentIDs) > 0 {
+		return badRequest("chat rooms do not support attachments")
+	}
+	if msg.Content == "" && len(msg.AttachmentIDs) == 0 {
+		return badRequest("message needs content or attachments")
+	}
+	return nil
+}
+
+func validateTyping(c *Client, msg *models.Message) error {
+	if (msg.To == 0 && msg.GroupID == 0) || (msg.GroupID == 0 && msg.To == c.ID) {
+		return badRequest("typing event needs either a recipient or a group")
+	}
+	return nil
+}
+
+func validateMessageUpdate(c *Client, msg *models.Message) error {
+	if msg.ID <= 0 {
+		return badRequest("missing message id")
+	}
+	if msg.Type == "edit_message" && msg.Content == "" {
+		return badRequest("edited content cannot be empty")
+	}
+	return nil
+}
+
+func validateReaction(c *Client, msg *models.Message) error {
+	if msg.ID <= 0 {
+		return badRequest("missing message id")
+	}
+	if msg.Emoji == "" {
+		return badRequest("missing emoji")
+	}
+	return nil
+}
+
+func validateMarkRead(c *Client, msg *models.Message) error {
+	if msg.To == 0 || msg.To == c.ID || msg.MessageID < 0 {
+		return badRequest("mark_read needs the other participant and a valid message_id")
+	}
+	return nil
+}
+
+// dispatch traite une trame dans Run et répond par un ack ou une erreur
+func (h *Hub) dispatch(req request) {
+	t, ok := messageTypes[req.msg.Type]
+	if !ok {
+		fmt.Printf("❌ Unknown message type: %s\n", req.msg.Type)
+		h.replyError(req, &frameErr{code: codeUnknownType, message: fmt.Sprintf("unknown message type %q", req.msg.Type)})
+		return
+	}
+
+	ack, err := t.handle(h, &req.msg)
+	if err != nil {
+		fmt.Printf("❌ %s from user %d refused: %v\n", req.msg.Type, req.msg.From, err)
+		h.replyError(req, err)
+		return
+	}
+	h.reply(req, frameAck, ack)
+}
+
+// reply envoie un ack ou une erreur à la connexion qui a envoyé la trame (protocole v2 uniquement)
+func (h *Hub) reply(req request, frameType string, payload interface{}) {
+	if req.client == nil || req.client.version < protocolV2 {
+		return
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return
+	}
+	data, err := json.Marshal(models.Envelope{
+		V:        protocolV2,
+		Type:     frameType,
+		ID:       req.frameID,
+		ClientID: req.msg.ClientID,
+		Payload:  body,
+	})
+	if err != nil {
+		return
+	}
+	h.safeSend(req.client, data)
+}
+
+func (h *Hub) replyError(req request, err error) {
+	h.reply(req, frameError, protocolError(err))
+}
+
+// protocolError traduit une erreur de traitement en trame error ; les erreurs internes ne sont pas détaillées
+func protocolError(err error) models.ProtocolError {
+	var fe *frameErr
+	var rl *services.RateLimitError
+	switch {
+	case errors.As(err, &fe):
+		return models.ProtocolError{Code: fe.code, Message: fe.message}
+	case errors.As(err, &rl):
+		return models.ProtocolError{Code: rl.Reason, Message: rl.Error(), RetryAfter: rl.RetryAfter.Milliseconds()}
+	case errors.Is(err, services.ErrUserBlocked),
+		errors.Is(err, services.ErrNotMessageSender),
+		errors.Is(err, services.ErrNotGroupMember),
+		errors.Is(err, services.ErrChatNotAllowed),
+		errors.Is(err, services.ErrTypingNotAllowed):
+		return models.ProtocolError{Code: codeForbidden, Message: err.Error()}
+	case errors.Is(err, services.ErrMessageNotFound),
+		errors.Is(err, services.ErrAttachmentNotFound),
+		errors.Is(err, services.ErrReplyNotFound),
+		errors.Is(err, services.ErrRoomNotFound),
+		errors.Is(err, services.ErrUserNotFound):
+		return models.ProtocolError{Code: codeNotFound, Message: err.Error()}
+	case errors.Is(err, services.ErrEditWindowExpired):
+		return models.ProtocolError{Code: codeEditWindowExpired, Message: err.Error()}
+	case errors.Is(err, services.ErrEmptyMessage),
+		errors.Is(err, services.ErrTooManyAttachments),
+		errors.Is(err, services.ErrInvalidClientID),
+		errors.Is(err, services.ErrInvalidPresence),
+		errors.Is(err, services.ErrInvalidEmoji),
+		errors.Is(err, services.ErrTooManyReactions):
+		return models.ProtocolError{Code: codeBadRequest, Message: err.Error()}
+	default:
+		return models.ProtocolError{Code: codeInternal, Message: "internal error"}
+	}
+}
+
+// frame met un événement au format de la connexion : objet historique (seq ajouté en tête),
+// le même objet dans un événement SSE, ou enveloppe v2 avec l'événement en payload
+func (c *Client) frame(eventType string, seq int64, payload []byte) []byte {
+	if c.version < protocolV2 {
+		if seq > 0 {
+			payload = withSeq(payload, seq)
+		}
+		if c.sse {
+			return sseFrame(seq, payload)
+		}
+		return payload
+	}
+	data, err := json.Marshal(models.Envelope{V: protocolV2, Type: eventType, Seq: seq, Payload: payload})
+	if err != nil {
+		return payload
+	}
+	return data
+}
+
+func (h *Hub) handlePrivateMessage(msg *models.Message) (models.Ack, error) {
+	deliver, err := h.messageService.ProcessPrivateMessage(msg)
+	if err != nil {
+		if errors.Is(err, services.ErrDuplicateMessage) {
+			// Déjà enregistré et diffusé lors du premier envoi
+			return models.Ack{MessageID: msg.ID, Duplicate: true}, nil
+		}
+		return models.Ack{}, err
+	}
+
+	// Deliver to the recipient (replayed later if offline) and back to the sender
+	// so the sender sees the stored message immediately. A recipient who ignored
+	// the sender's message request gets nothing, without the sender knowing.
+	out := []outgoing{{userID: msg.From, event: *msg}}
+	if deliver {
+		out = append([]outgoing{{userID: msg.To, event: *msg}}, out...)
+	}
+	h.deliverAll(out)
+	return models.Ack{MessageID: msg.ID}, nil
+}
+
+func (h *Hub) handleGroupMessage(msg *models.Message) (models.Ack, error) {
+	// Un membre parti ou exclu ne peut plus écrire dans le groupe
+	member, err := h.isGroupMember(msg.GroupID, msg.From)
+	if err != nil {
+		return models.Ack{}, fmt.Errorf("failed to get group members: %w", err)
+	}
+	if !member {
+		return models.Ack{}, errNotGroupMember
+	}
+
+	if err := h.messageService.ProcessGroupMessage(msg); err != nil {
+		if errors.Is(err, services.ErrDuplicateMessage) {
+			return models.Ack{MessageID: msg.ID, Duplicate: true}, nil
+		}
+		return models.Ack{}, err
+	}
+
+	// Get group members from cache or service
+	members, err := h.GetGroupMembers(msg.GroupID)
+	if err != nil {
+		return models.Ack{}, fmt.Errorf("failed to get group members: %w", err)
+	}
+
+	// Deliver to all group members (including sender)
+	out := make([]outgoing, 0, len(members))
+	for _, memberID := range members {
+		msgCopy := *msg
+		msgCopy.To = memberID
+		out = append(out, outgoing{userID: memberID, event: msgCopy})
+	}
+	h.deliverAll(out)
+	fmt.Printf("✅ Group message broadcast to %d members of group %d\n", len(members), msg.GroupID)
+	return models.Ack{MessageID: msg.ID}, nil
+}
+
+func (h *Hub) handleMarkRead(msg *models.Message) (models.Ack, error) {
+	receipt, advanced, err := h.messageService.MarkConversationRead(msg.From, msg.To, msg.MessageID)
+	if err != nil || !advanced {
+		return models.Ack{}, err
+	}
+
+	// Relay the receipt to the other participant, and to the reader's other devices
+	// so they can clear their unread counters too
+	h.deliverAll([]outgoing{{userID: msg.To, event: receipt}, {userID: msg.From, event: receipt}})
+	return models.Ack{MessageID: receipt.MessageID}, nil
+}
+
+func (h *Hub) handleEditMessage(msg *models.Message) (models.Ack, error) {
+	update, err := h.EditMessage(msg.From, msg.ID, msg.GroupID, msg.Content)
+	return models.Ack{MessageID: update.ID}, err
+}
+
+func (h *Hub) handleDeleteMessage(msg *models.Message) (models.Ack, error) {
+	update, err := h.DeleteMessage(msg.From, msg.ID, msg.GroupID)
+	return models.Ack{MessageID: update.ID}, err
+}
+
+func (h *Hub) handleAddReaction(msg *models.Message) (models.Ack, error) {
+	update, err := h.React(msg.From, msg.ID, msg.GroupID, msg.Emoji, true)
+	return models.Ack{MessageID: update.ID}, err
+}
+
+func (h *Hub) handleRemoveReaction(msg *models.Message) (models.Ack, error) {
+	update, err := h.React(msg.From, msg.ID, msg.GroupID, msg.Emoji, false)
+	return models.Ack{MessageID: update.ID}, err
+}
+
+func (h *Hub) handleTyping(msg *models.Message) (models.Ack, error) {
+	return models.Ack{}, h.relayTyping(*msg)
+}
+
+func (h *Hub) handlePresence(msg *models.Message) (models.Ack, error) {
+	if h.presenceService == nil {
+		return models.Ack{}, nil
+	}
+	changed, err := h.presenceService.SetStatus(msg.From, msg.Content)
+	if err != nil {
+		return models.Ack{}, err
+	}
+	if changed {
+		go h.broadcastPresence(msg.From, h.presenceService.Status(msg.From), nil)
+	}
+	go h.publishPresence(msg.From)
+	return models.Ack{}, nil
+}
+
+// stamp force l'expéditeur à l'utilisateur de la connexion et date la trame
+func (c *Client) stamp(msg *models.Message) {
+	msg.From = c.ID
+	parsedTime, err := time.Parse(time.RFC3339, msg.Timestamp)
+	if err != nil || parsedTime.IsZero() {
+		parsedTime = time.Now()
+	}
+	msg.Timestamp = parsedTime.String()
+}
@@ -0,0 +1,52 @@
+package hub
+
+import (
+	"errors"
+
+	"social/models"
+	"social/services"
+)
+
+// SendRoomMessage enregistre et livre un message de discussion à plusieurs envoyé hors WebSocket
+func (h *Hub) SendRoomMessage(msg *models.Message) (models.Ack, error) {
+	return h.handleRoomMessage(msg)
+}
+
+// SendRoomEvent pousse un changement de discussion à ses participants, et à ceux qui viennent
+// de la quitter ou d'en être retirés
+func (h *Hub) SendRoomEvent(event models.RoomEvent) {
+	if event.Room == nil {
+		return
+	}
+	var out []outgoing
+	for _, p := range event.Room.Participants {
+		out = append(out, outgoing{userID: p.UserID, event: event})
+	}
+	if event.Type == models.RoomParticipantRemoved || event.Type == models.RoomParticipantLeft {
+		for _, userID := range event.UserIDs {
+			out = append(out, outgoing{userID: userID, event: event})
+		}
+	}
+	h.deliverAll(out)
+}
+
+func (h *Hub) handleRoomMessage(msg *models.Message) (models.Ack, error) {
+	if h.roomService == nil {
+		return models.Ack{}, &frameErr{code: codeUnknownType, message: "chat rooms are not available"}
+	}
+	recipients, err := h.roomService.ProcessRoomMessage(msg)
+	if err != nil {
+		if errors.Is(err, services.ErrDuplicateMessage) {
+			return models.Ack{MessageID: msg.ID, Duplicate: true}, nil
+		}
+		return models.Ack{}, err
+	}
+
+	// Comme pour un groupe, l'expéditeur reçoit aussi le message enregistré
+	out := make([]outgoing, len(recipients))
+	for i, userID := range recipients {
+		out[i] = outgoing{userID: userID, event: *msg}
+	}
+	h.deliverAll(out)
+	return models.Ack{MessageID: msg.ID}, nil
+}
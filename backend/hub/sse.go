@@ -0,0 +1,100 @@
+package hub
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"social/utils"
+)
+
+// sseHeartbeat garde le flux ouvert à travers les proxys qui coupent les connexions inactives
+const sseHeartbeat = 25 * time.Second
+
+// ServeSSE est le transport de repli de /ws pour les clients dont le proxy bloque les WebSockets :
+// GET /api/events/stream reçoit les mêmes événements, en lecture seule (l'envoi passe par l'API REST).
+// Chaque événement rejouable porte son seq en id ; à la reconnexion, le navigateur renvoie
+// Last-Event-ID et les événements manqués sont rejoués comme avec /ws?since=.
+func (h *Handler) ServeSSE(hub *Hub, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := utils.GetUserIDFromContext(r.Context())
+	if !ok {
+		utils.WriteError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	rc := http.NewResponseController(w)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no") // pas de mise en tampon par nginx
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write([]byte("retry: 3000\n\n")); err != nil || rc.Flush() != nil {
+		log.Printf("❌ SSE stream for user %d cannot be flushed\n", userID)
+		return
+	}
+
+	client := &Client{
+		ID:     userID,
+		ConnID: newConnID(),
+		Send:   make(chan []byte, 256),
+		sse:    true,
+	}
+
+	// Last-Event-ID (reconnexion automatique du navigateur) ou ?since= (première connexion)
+	resume := r.Header.Get("Last-Event-ID")
+	if resume == "" {
+		resume = r.URL.Query().Get("since")
+	}
+	hub.resumeFrom(client, resume)
+
+	hub.Register <- client
+	defer func() { hub.Unregister <- client }()
+	log.Printf("✅ SSE stream opened for user %d (connection %s)\n", userID, client.ConnID)
+
+	heartbeat := time.NewTicker(sseHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		var data []byte
+		select {
+		case <-r.Context().Done():
+			log.Printf("🔌 SSE stream closed by user %d\n", userID)
+			return
+		case message, ok := <-client.Send:
+			if !ok {
+				return
+			}
+			data = message
+		case <-heartbeat.C:
+			data = []byte(": ping\n\n")
+		}
+
+		rc.SetWriteDeadline(time.Now().Add(writeWait))
+		if _, err := w.Write(data); err != nil {
+			log.Printf("❌ SSE write error for user %d: %v\n", userID, err)
+			return
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// sseFrame écrit un événement au format text/event-stream ; seul un événement rejouable a un id
+func sseFrame(seq int64, data []byte) []byte {
+	out := make([]byte, 0, len(data)+32)
+	if seq > 0 {
+		out = append(out, "id: "...)
+		out = strconv.AppendInt(out, seq, 10)
+		out = append(out, '\n')
+	}
+	out = append(out, "data: "...)
+	out = append(out, data...)
+	return append(out, '\n', '\n')
+}
@@ -1,15 +1,18 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"os"
+	"social/broker"
 	"social/db/sqlite"
 	"social/handlers"
 	"social/handlers/group"
 	hubS "social/hub"
 	"social/repositories"
 	"social/services"
+	"social/storage"
 	"social/utils"
 )
 
@@ -18,27 +21,30 @@ func main() {
 	// 1. Initialize Database
 	sqlite.InitDB()
 	db := sqlite.GetDB()
-	folderName := []string{
-		"uploads/avatars",
-		"uploads/group_posts",
-	}
 
-	for _, folder := range folderName {
-		if err := os.MkdirAll(folder, os.ModePerm); err != nil {
-			fmt.Printf("❌ Failed to create '%s': %v\n", folder, err)
-			return
-		}
+	// Media storage (local disk or S3-compatible, see STORAGE_DRIVER)
+	if err := storage.Init(); err != nil {
+		fmt.Printf("❌ Failed to initialize media storage: %v\n", err)
+		return
 	}
 
 	// 2. Initialize Repositories (alphabetical order)
 	authRepo := repositories.NewUserRepository(db)
+	blockRepo := repositories.NewBlockRepository(db)
 	chatRepo := repositories.NewChatRepository(db)
+	chatRoomRepo := repositories.NewChatRoomRepository(db)
+	eventRepo := repositories.NewEventRepository(db)
 	followRepo := repositories.NewFollowRepository(db)
 	groupRepo := repositories.NewGroupRepository(db)
+	mediaRepo := repositories.NewMediaRepository(db)
+	messageRequestRepo := repositories.NewMessageRequestRepository(db)
+	muteRepo := repositories.NewMuteRepository(db)
 	notifRepo := repositories.NewNotificationRepository(db)
 	postRepo := repositories.NewPostRepository(db)
+	presenceRepo := repositories.NewPresenceRepository(db)
 	profileRepo := repositories.NewProfileRepository(db)
 	sessionRepo := repositories.NewSessionRepo(db)
+	suggestionRepo := repositories.NewSuggestionRepository(db)
 
 	// 3. Initialize Services (grouped by domain)
 	// Authentication & Session
@@ -46,33 +52,67 @@ func main() {
 	sessionService := services.NewSessionService(sessionRepo)
 
 	// Chat & Messaging
-	chatService := services.NewChatService(chatRepo)
+	chatService := services.NewChatService(chatRepo, messageRequestRepo, blockRepo)
+	chatRoomService := services.NewChatRoomService(chatRoomRepo, chatRepo, messageRequestRepo, blockRepo)
+	messageRequestService := services.NewMessageRequestService(messageRequestRepo, blockRepo)
+	presenceService := services.NewPresenceService(presenceRepo, chatRepo, groupRepo, blockRepo)
+	eventService := services.NewEventService(eventRepo)
 
 	// Social Features
-	followService := services.NewFollowService(followRepo, notifRepo)
+	blockService := services.NewBlockService(blockRepo)
+	followService := services.NewFollowService(followRepo, notifRepo, blockRepo)
+	muteService := services.NewMuteService(muteRepo, blockRepo)
 	notifService := services.NewNotificationService(notifRepo)
-	profileService := services.NewProfileService(*profileRepo)
+	profileService := services.NewProfileService(*profileRepo, mediaRepo, blockRepo, muteRepo)
+	suggestionService := services.NewSuggestionService(suggestionRepo, blockRepo)
 
 	// Content Features
-	groupService := services.NewGroupService(groupRepo)
-	postService := services.NewPostService(postRepo)
+	groupService := services.NewGroupService(groupRepo, mediaRepo, blockRepo)
+	mediaService := services.NewMediaService(mediaRepo)
+	postService := services.NewPostService(postRepo, mediaRepo, blockRepo)
+
+	// Nettoyage périodique des uploads orphelins (voir MEDIA_GC_*)
+	mediaService.StartOrphanSweeper(context.Background(), services.SweeperConfigFromEnv())
+	// Purge du journal des événements temps réel (voir EVENT_LOG_RETENTION)
+	eventService.StartPruner(context.Background())
+
+	// Limites d'envoi par utilisateur, partagées par le hub et les routes HTTP (voir RATE_LIMIT_*)
+	rateLimiter := services.NewRateLimitService(services.RateLimitConfigFromEnv())
+	rateLimiter.StartJanitor(context.Background())
 
 	// 4. Initialize Hub with required services
-	hub := hubS.NewHub(chatService)
+	// Broker entre instances (BROKER_DRIVER=memory pour une instance unique, redis pour plusieurs)
+	eventBroker, err := broker.New(broker.ConfigFromEnv())
+	if err != nil {
+		fmt.Printf("❌ Failed to initialize broker: %v\n", err)
+		return
+	}
+	defer eventBroker.Close()
+	hub := hubS.NewHub(chatService, chatRoomService, muteService, presenceService, eventService, rateLimiter, eventBroker)
+	// Le hub suit les adhésions aux groupes (cache des membres, événements aux clients)
+	groupService.OnMembershipChange(hub.OnMembershipChange)
 	go hub.Run()
 
 	// 5. Initialize Handlers
-	authHandler := handlers.NewHandler(authService, sessionService, hub)
-	chatHandler := handlers.NewChatHandler(chatService, sessionService)
+	authHandler := handlers.NewHandler(authService, mediaService, sessionService, hub)
+	blockHandler := handlers.NewBlockHandler(blockService)
+	chatHandler := handlers.NewChatHandler(chatService, mediaService, sessionService, rateLimiter, hub)
+	chatRoomHandler := handlers.NewChatRoomHandler(chatRoomService, rateLimiter, hub)
 	followHandler := handlers.NewFollowHandler(followService, sessionService, hub)
-	groupHandler := group.NewHandler(groupService, sessionService, hub)
+	groupHandler := group.NewHandler(groupService, mediaService, sessionService, rateLimiter, hub)
 	hubHandler := hubS.NewHandler(authService, sessionService, groupService, hub)
 	notifHandler := handlers.NewNotificationHandler(notifService, sessionService)
-	postHandler := handlers.NewPostHandler(postService, sessionService)
+	postHandler := handlers.NewPostHandler(postService, mediaService, sessionService, rateLimiter)
+	presenceHandler := handlers.NewPresenceHandler(presenceService)
+	mediaHandler := handlers.NewMediaHandler(mediaService, sessionService)
+	messageRequestHandler := handlers.NewMessageRequestHandler(messageRequestService, hub)
+	muteHandler := handlers.NewMuteHandler(muteService)
 	profileHandler := handlers.NewProfileHandler(profileService, sessionService, hub)
+	suggestionHandler := handlers.NewSuggestionHandler(suggestionService)
 
 	// 6. Create Auth Middleware
 	authMiddleware := utils.AuthMiddleware(sessionService)
+	rateLimit := utils.RateLimitMiddleware(rateLimiter)
 
 	// 7. Setup Router
 	mux := http.NewServeMux()
@@ -84,7 +124,7 @@ func main() {
 
 	// User profile routes (PROTÉGÉES)
 	mux.Handle("/api/profile/", authMiddleware(http.HandlerFunc(profileHandler.ProfileHandler)))
-	mux.Handle("/api/users/", authMiddleware(http.HandlerFunc(profileHandler.GetUserByIDHandler)))
+	mux.Handle("/api/users/", authMiddleware(http.HandlerFunc(profileHandler.UsersRouterHandler)))
 	mux.Handle("/api/search", authMiddleware(http.HandlerFunc(profileHandler.SearchUsers)))
 	mux.Handle("/api/user/toggle-privacy", authMiddleware(http.HandlerFunc(profileHandler.TogglePrivacy)))
 	mux.Handle("/api/auth/me", authMiddleware(http.HandlerFunc(profileHandler.GetMe)))
@@ -101,13 +141,37 @@ func main() {
 	mux.Handle("/api/follow/accept", authMiddleware(http.HandlerFunc(followHandler.AcceptFollow)))
 	mux.Handle("/api/follow/reject", authMiddleware(http.HandlerFunc(followHandler.RejectFollow)))
 	mux.Handle("/api/unfollow", authMiddleware(http.HandlerFunc(followHandler.UnfollowUser)))
+	mux.Handle("/api/follow/requests", authMiddleware(http.HandlerFunc(followHandler.FollowRequestsHandler)))
+	mux.Handle("/api/follow/requests/", authMiddleware(http.HandlerFunc(followHandler.FollowRequestsRouterHandler)))
+	mux.Handle("/api/followers/", authMiddleware(http.HandlerFunc(followHandler.RemoveFollower)))
 	mux.Handle("/api/users-followers/", authMiddleware(http.HandlerFunc(followHandler.GetFollowersHandler)))
 	mux.Handle("/api/users-following/", authMiddleware(http.HandlerFunc(followHandler.GetFollowingHandler)))
 	mux.Handle("/api/recipients", authMiddleware(http.HandlerFunc(followHandler.GetRecipientsHandler)))
 
+	// Block routes (PROTÉGÉES)
+	mux.Handle("/api/blocks", authMiddleware(http.HandlerFunc(blockHandler.BlocksHandler)))
+	mux.Handle("/api/blocks/", authMiddleware(http.HandlerFunc(blockHandler.UnblockUser)))
+
+	// Suggestion routes (PROTÉGÉES)
+	mux.Handle("/api/suggestions/users", authMiddleware(http.HandlerFunc(suggestionHandler.GetUserSuggestions)))
+	mux.Handle("/api/suggestions/dismiss", authMiddleware(http.HandlerFunc(suggestionHandler.DismissSuggestion)))
+
+	// Mute routes (PROTÉGÉES)
+	mux.Handle("/api/mutes", authMiddleware(http.HandlerFunc(muteHandler.MutesHandler)))
+	mux.Handle("/api/mutes/", authMiddleware(http.HandlerFunc(muteHandler.MuteRouterHandler)))
+
 	// Chat routes (PROTÉGÉES)
 	mux.Handle("/api/chat-users", authMiddleware(http.HandlerFunc(chatHandler.GetAllChatUsers)))
 	mux.Handle("/api/chat/history", authMiddleware(http.HandlerFunc(chatHandler.GetChatHistory)))
+	mux.Handle("/api/conversations", authMiddleware(http.HandlerFunc(chatHandler.GetConversations)))
+	mux.Handle("/api/chat/messages/", authMiddleware(http.HandlerFunc(chatHandler.MessageHandler)))
+	mux.Handle("/api/chat/attachments", authMiddleware(rateLimit(services.ActionUpload)(http.HandlerFunc(chatHandler.UploadAttachment))))
+	mux.Handle("/api/chat/rooms", authMiddleware(http.HandlerFunc(chatRoomHandler.RoomsHandler)))
+	mux.Handle("/api/chat/rooms/", authMiddleware(http.HandlerFunc(chatRoomHandler.RoomRouterHandler)))
+	mux.Handle("/api/chat/requests", authMiddleware(http.HandlerFunc(messageRequestHandler.GetRequests)))
+	mux.Handle("/api/chat/requests/", authMiddleware(http.HandlerFunc(messageRequestHandler.RequestRouterHandler)))
+	mux.Handle("/api/chat/settings", authMiddleware(http.HandlerFunc(messageRequestHandler.SettingsHandler)))
+	mux.Handle("/api/presence", authMiddleware(http.HandlerFunc(presenceHandler.GetPresence)))
 
 	// Notification routes (PROTÉGÉES)
 	mux.Handle("/api/notifications", authMiddleware(http.HandlerFunc(notifHandler.GetUserNotifications)))
@@ -123,9 +187,13 @@ func main() {
 		fmt.Println("🧲 WebSocket connection initiated")
 		hubHandler.ServeWS(hub, w, r)
 	})
+	// Repli Server-Sent Events de /ws (PROTÉGÉE)
+	mux.Handle("/api/events/stream", authMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hubHandler.ServeSSE(hub, w, r)
+	})))
 
-	// Static files route (NON protégée)
-	mux.Handle("/uploads/", http.StripPrefix("/uploads/", http.FileServer(http.Dir("uploads"))))
+	// Media route (URL signée ou session, vérifiée dans le handler)
+	mux.HandleFunc("/uploads/", mediaHandler.ServeMedia)
 
 	// 8. Setup Middleware
 	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -139,8 +207,12 @@ func main() {
 	})
 
 	// 9. Start Server
-	fmt.Println("✅ Server started on :8080")
-	if err := http.ListenAndServe(":8080", handler); err != nil {
+	port := os.Getenv("PORT")
+	if port == "" {
+		port = "8080"
+	}
+	fmt.Printf("✅ Server started on :%s\n", port)
+	if err := http.ListenAndServe(":"+port, handler); err != nil {
 		fmt.Printf("❌ Server error: %v\n", err)
 	}
 }
\ No newline at end of file
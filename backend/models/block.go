@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// BlockedUser est une entrée de la liste de blocage d'un utilisateur
+type BlockedUser struct {
+	ID        int       `json:"id"`
+	FirstName string    `json:"first_name"`
+	LastName  string    `json:"last_name"`
+	Nickname  string    `json:"nickname"`
+	Avatar    string    `json:"avatar"`
+	BlockedAt time.Time `json:"blocked_at"`
+}
+
+type BlockRequest struct {
+	UserID int `json:"user_id"`
+}
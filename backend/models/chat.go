@@ -2,6 +2,7 @@ package models
 
 import (
 	"database/sql"
+	"time"
 )
 
 type ChatUser struct {
@@ -11,6 +12,69 @@ type ChatUser struct {
 	IsPrivate    bool   `json:"is_private"`
 	FollowStatus string `json:"follow_status"`
 	CanChat      bool   `json:"can_chat"`
+	UnreadCount  int    `json:"unread_count"`
+}
+
+// ConversationPreview résume le dernier message d'une conversation
+type ConversationPreview struct {
+	ID          int    `json:"id"`
+	From        int    `json:"from"`
+	Content     string `json:"content"` // tronqué
+	Deleted     bool   `json:"deleted,omitempty"`
+	Attachments int    `json:"attachments,omitempty"` // nombre de pièces jointes
+	Timestamp   string `json:"timestamp"`
+}
+
+// Conversation est une discussion privée de GET /api/conversations
+type Conversation struct {
+	UserID      int                 `json:"user_id"`
+	FullName    string              `json:"full_name"`
+	Nickname    string              `json:"nickname"`
+	Avatar      string              `json:"avatar"`
+	LastMessage ConversationPreview `json:"last_message"`
+	UnreadCount int                 `json:"unread_count"`
+	CanChat     bool                `json:"can_chat"`
+}
+
+// ConversationPage est une page de GET /api/conversations, de la plus récemment active à la plus ancienne ;
+// next_before (ID du dernier message de la dernière conversation) demande la page suivante
+type ConversationPage struct {
+	Conversations []Conversation `json:"conversations"`
+	Limit         int            `json:"limit"`
+	NextBefore    int            `json:"next_before,omitempty"`
+}
+
+// MessageRef est ce qu'il faut savoir d'un message enregistré pour autoriser sa modification
+type MessageRef struct {
+	ID             int
+	From           int
+	To             int // message privé
+	GroupID        int // message de groupe
+	GroupCreatorID int
+	SentAt         time.Time
+	Deleted        bool
+}
+
+// Pièce jointe de message : kind vaut image, audio ou file
+type Attachment struct {
+	ID        int    `json:"id"`
+	Kind      string `json:"kind"`
+	URL       string `json:"url"`
+	FileName  string `json:"file_name"`
+	MimeType  string `json:"mime_type"`
+	Size      int64  `json:"size"`
+	Width     int    `json:"width,omitempty"`
+	Height    int    `json:"height,omitempty"`
+	BlurHash  string `json:"blurhash,omitempty"`
+	ThumbURL  string `json:"thumb_url,omitempty"`
+	MessageID int    `json:"-"`
+}
+
+// Reaction regroupe les réactions d'un message avec un même emoji, dans l'ordre de la première
+type Reaction struct {
+	Emoji   string `json:"emoji"`
+	Count   int    `json:"count"`
+	UserIDs []int  `json:"user_ids"`
 }
 
 type ChatRepository struct {
@@ -0,0 +1,57 @@
+package models
+
+import "time"
+
+// Rôles dans une discussion à plusieurs : les admins renomment la discussion et gèrent les participants,
+// le créateur nomme les admins
+const (
+	RoomRoleAdmin  = "admin"
+	RoomRoleMember = "member"
+)
+
+// Types des événements d'une discussion à plusieurs
+const (
+	RoomCreated            = "room_created"
+	RoomRenamed            = "room_renamed"
+	RoomParticipantsAdded  = "room_participants_added"
+	RoomParticipantRemoved = "room_participant_removed" // retiré par un admin
+	RoomParticipantLeft    = "room_participant_left"
+	RoomRoleChanged        = "room_role_changed"
+)
+
+type RoomParticipant struct {
+	UserID   int    `json:"user_id"`
+	Nickname string `json:"nickname"`
+	FullName string `json:"full_name"`
+	Avatar   string `json:"avatar"`
+	Role     string `json:"role"`
+}
+
+// ChatRoom est une discussion à plusieurs, indépendante des groupes
+type ChatRoom struct {
+	ID           int               `json:"id"`
+	Title        string            `json:"title"`
+	CreatorID    int               `json:"creator_id"`
+	CreatedAt    time.Time         `json:"created_at"`
+	Participants []RoomParticipant `json:"participants,omitempty"`
+	// GET /api/chat/rooms : rôle du demandeur et dernier message visible
+	Role        string               `json:"role,omitempty"`
+	LastMessage *ConversationPreview `json:"last_message,omitempty"`
+}
+
+type CreateRoomRequest struct {
+	Title          string `json:"title"`
+	ParticipantIDs []int  `json:"participant_ids"` // sans le créateur
+}
+
+// RoomEvent est poussé aux participants quand une discussion change ; Room est son état après
+// le changement, et UserIDs les participants concernés (ajoutés, retiré, parti, nouveau rôle)
+type RoomEvent struct {
+	Type      string    `json:"type"`
+	RoomID    int       `json:"room_id"`
+	ActorID   int       `json:"actor_id"`
+	UserIDs   []int     `json:"user_ids,omitempty"`
+	Role      string    `json:"role,omitempty"`
+	Room      *ChatRoom `json:"room,omitempty"`
+	Timestamp string    `json:"timestamp"`
+}
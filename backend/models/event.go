@@ -0,0 +1,24 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// UserEvent est un événement temps réel du journal d'un utilisateur : Seq croît strictement
+// pour chaque utilisateur et Payload est le JSON envoyé sur le WebSocket (sans seq)
+type UserEvent struct {
+	UserID    int
+	Seq       int64
+	Type      string
+	Payload   json.RawMessage
+	CreatedAt time.Time
+}
+
+// SyncEvent encadre le rattrapage d'une reconnexion /ws?since= : sync_truncated quand des événements
+// ont déjà été purgés (le client doit recharger par l'API REST), puis sync_complete une fois le rattrapage fini
+type SyncEvent struct {
+	Type      string `json:"type"`
+	Seq       int64  `json:"seq"`                  // dernier seq envoyé
+	OldestSeq int64  `json:"oldest_seq,omitempty"` // sync_truncated : plus ancien événement encore disponible
+}
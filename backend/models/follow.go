@@ -1,5 +1,6 @@
 package models
 
+import "time"
 
 type FollowRequest struct {
 	FollowerID int
@@ -29,4 +30,36 @@ type Following struct {
 	FirstName string
 	LastName  string
 	Avatar    string
-}
\ No newline at end of file
+}
+
+// FollowRequestUser est l'autre personne d'une demande d'abonnement en attente
+type FollowRequestUser struct {
+	ID          int       `json:"id"`
+	FirstName   string    `json:"first_name"`
+	LastName    string    `json:"last_name"`
+	Nickname    string    `json:"nickname"`
+	Avatar      string    `json:"avatar"`
+	RequestedAt time.Time `json:"requested_at"`
+}
+
+// FollowRequestPage est une page de GET /api/follow/requests
+type FollowRequestPage struct {
+	Direction string              `json:"direction"`
+	Requests  []FollowRequestUser `json:"requests"`
+	Total     int                 `json:"total"`
+	Limit     int                 `json:"limit"`
+	Offset    int                 `json:"offset"`
+}
+
+type BulkFollowActionRequest struct {
+	SenderIDs []int `json:"sender_ids"`
+}
+
+// FollowEvent est poussé par WebSocket quand une demande change d'état
+// (follow_request_response, follow_request_cancelled, follow_status_update)
+type FollowEvent struct {
+	Type        string `json:"type"`
+	SenderID    int    `json:"sender_id"`    // celui qui suit ou demande à suivre
+	RecipientID int    `json:"recipient_id"` // celui qui est suivi
+	Action      string `json:"action,omitempty"`
+}
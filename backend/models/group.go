@@ -54,6 +54,7 @@ type GroupPost struct {
 	AuthorName    string `json:"author_name"`
 	AuthorAvatar  string `json:"author_avatar"`
 	CommentsCount int    `json:"comments_count"`
+	ImageMeta     *Media `json:"image_meta,omitempty"`
 }
 
 type GroupPostComment struct {
@@ -103,22 +104,103 @@ type GroupEvent struct {
 }
 
 type Message struct {
+	ID        int    `json:"id,omitempty"`
 	From      int    `json:"from"` // Changed to lowercase to match frontend
 	To        int    `json:"to"`
 	GroupID   int    `json:"groupId"` // Changed to match frontend's groupId
 	Content   string `json:"content"`
 	Type      string `json:"type"`
 	Timestamp string `json:"timestamp"`
+
+	// mark_read / read_receipt : dernier message lu de la conversation
+	MessageID int `json:"message_id,omitempty"`
+	// Historique : le destinataire a lu ce message (uniquement pour les messages envoyés)
+	Read bool `json:"read,omitempty"`
+	// Historique : message modifié, ou supprimé (le contenu est alors vide)
+	EditedAt string `json:"edited_at,omitempty"`
+	Deleted  bool   `json:"deleted,omitempty"`
+
+	// Pièces jointes : IDs des uploads référencés par le client, métadonnées renvoyées par le serveur
+	AttachmentIDs []int        `json:"attachment_ids,omitempty"`
+	Attachments   []Attachment `json:"attachments,omitempty"`
+
+	// Clé d'idempotence choisie par le client : un renvoi ne crée pas de doublon
+	ClientID string `json:"client_id,omitempty"`
+
+	// Message auquel celui-ci répond, dans la même conversation
+	ReplyTo int `json:"reply_to,omitempty"`
+	// room_message : discussion à plusieurs du message
+	RoomID int `json:"room_id,omitempty"`
+	// Message privé en attente dans les demandes de message du destinataire
+	Request bool `json:"request,omitempty"`
+	// add_reaction / remove_reaction : emoji posé sur le message ID
+	Emoji string `json:"emoji,omitempty"`
+	// Historique : réactions au message, regroupées par emoji
+	Reactions []Reaction `json:"reactions,omitempty"`
+}
+
+// Types des événements d'adhésion à un groupe
+const (
+	GroupMemberJoined      = "group_member_joined"      // demande approuvée ou invitation acceptée
+	GroupMemberLeft        = "group_member_left"        // le membre a quitté le groupe
+	GroupMemberRemoved     = "group_member_removed"     // exclu par le créateur
+	GroupRequestDeclined   = "group_request_declined"   // demande refusée par le créateur
+	GroupInvitationRefused = "group_invitation_refused" // invitation refusée par l'invité
+)
+
+// GroupMembershipEvent est publié par GroupService quand l'adhésion de UserID change,
+// et poussé aux personnes concernées. ActorID est celui qui a fait le changement.
+type GroupMembershipEvent struct {
+	Type      string `json:"type"`
+	GroupID   int    `json:"groupId"`
+	UserID    int    `json:"user_id"`
+	ActorID   int    `json:"actor_id"`
+	CreatorID int    `json:"creator_id"`
+	Timestamp string `json:"timestamp"`
+}
+
+// MessageUpdate est diffusé quand un message privé ou de groupe est modifié (message_edited),
+// supprimé (message_deleted) ou qu'une réaction y est ajoutée ou retirée (reaction_added, reaction_removed)
+type MessageUpdate struct {
+	Type      string `json:"type"`
+	ID        int    `json:"id"`
+	From      int    `json:"from"`              // auteur du message
+	To        int    `json:"to,omitempty"`      // destinataire d'un message privé
+	GroupID   int    `json:"groupId,omitempty"` // groupe d'un message de groupe
+	Content   string `json:"content"`
+	EditedAt  string `json:"edited_at,omitempty"`
+	DeletedBy int    `json:"deleted_by,omitempty"`
+	Timestamp string `json:"timestamp"`
+
+	// Réactions : qui a réagi, avec quel emoji, et toutes les réactions du message après le changement
+	UserID    int        `json:"user_id,omitempty"`
+	Emoji     string     `json:"emoji,omitempty"`
+	Reactions []Reaction `json:"reactions,omitempty"`
 }
 
 type GroupMessage struct {
-	ID             int       `json:"id"`
-	GroupID        int       `json:"group_id"`
-	SenderID       int       `json:"sender_id"`
-	Content        string    `json:"content"`
-	Timestamp      time.Time `json:"timestamp"`
-	SenderNickname string    `json:"sender_nickname"`
-	SenderAvatar   string    `json:"sender_avatar"`
+	ID             int          `json:"id"`
+	GroupID        int          `json:"group_id"`
+	SenderID       int          `json:"sender_id"`
+	Content        string       `json:"content"`
+	Timestamp      time.Time    `json:"timestamp"`
+	SenderNickname string       `json:"sender_nickname"`
+	SenderAvatar   string       `json:"sender_avatar"`
+	EditedAt       *time.Time   `json:"edited_at,omitempty"`
+	Deleted        bool         `json:"deleted,omitempty"`
+	Attachments    []Attachment `json:"attachments,omitempty"`
+	ReplyTo        int          `json:"reply_to,omitempty"`
+	ReplyCount     int          `json:"reply_count,omitempty"` // réponses directes, voir GroupThread
+	Reactions      []Reaction   `json:"reactions,omitempty"`
+}
+
+// GroupThread est le fil d'un message de groupe : le message, puis ses réponses (et les réponses
+// à ces réponses) du plus ancien au plus récent ; next_before demande les réponses plus anciennes
+type GroupThread struct {
+	Root       GroupMessage   `json:"root"`
+	Replies    []GroupMessage `json:"replies"`
+	Limit      int            `json:"limit"`
+	NextBefore int            `json:"next_before,omitempty"`
 }
 
 type GroupWithStatus struct {
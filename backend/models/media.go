@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// Media contient les métadonnées d'un fichier uploadé (images ré-encodées et leurs variantes)
+type Media struct {
+	ID         int       `json:"-"`
+	Path       string    `json:"url"`
+	OwnerID    int       `json:"-"`
+	MimeType   string    `json:"mime_type"`
+	Size       int64     `json:"size"`
+	Width      int       `json:"width"`
+	Height     int       `json:"height"`
+	BlurHash   string    `json:"blurhash,omitempty"`
+	ThumbPath  string    `json:"thumb_url,omitempty"`
+	MediumPath string    `json:"medium_url,omitempty"`
+	CreatedAt  time.Time `json:"-"`
+}
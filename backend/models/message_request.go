@@ -0,0 +1,43 @@
+package models
+
+// Réglage dm_policy : qui peut écrire en privé à l'utilisateur
+const (
+	DMPolicyEveryone  = "everyone"
+	DMPolicyFollowers = "followers" // abonnés acceptés
+	DMPolicyMutuals   = "mutuals"   // abonnements réciproques
+	DMPolicyNobody    = "nobody"
+)
+
+// Statuts d'une demande de message
+const (
+	MessageRequestPending  = "pending"
+	MessageRequestAccepted = "accepted"
+	MessageRequestIgnored  = "ignored"
+)
+
+// Événement poussé à l'expéditeur quand le destinataire accepte sa demande
+const MessageRequestAcceptedEvent = "message_request_accepted"
+
+// MessageRequest est une conversation en attente dans la boîte des demandes du destinataire
+type MessageRequest struct {
+	UserID       int                 `json:"user_id"` // expéditeur
+	FullName     string              `json:"full_name"`
+	Nickname     string              `json:"nickname"`
+	Avatar       string              `json:"avatar"`
+	Status       string              `json:"status"`
+	MessageCount int                 `json:"message_count"`
+	LastMessage  ConversationPreview `json:"last_message"`
+	CreatedAt    string              `json:"created_at"`
+}
+
+// MessageRequestEvent prévient l'expéditeur que UserID a accepté sa demande
+type MessageRequestEvent struct {
+	Type      string `json:"type"`
+	UserID    int    `json:"user_id"`
+	Timestamp string `json:"timestamp"`
+}
+
+// DMSettings est le corps de GET / PUT /api/chat/settings
+type DMSettings struct {
+	DMPolicy string `json:"dm_policy"`
+}
@@ -0,0 +1,37 @@
+package models
+
+import "time"
+
+// MutedUser est un utilisateur masqué ; ExpiresAt est nil pour un masquage sans fin
+type MutedUser struct {
+	ID        int        `json:"id"`
+	FirstName string     `json:"first_name"`
+	LastName  string     `json:"last_name"`
+	Nickname  string     `json:"nickname"`
+	Avatar    string     `json:"avatar"`
+	ExpiresAt *time.Time `json:"expires_at"`
+	MutedAt   time.Time  `json:"muted_at"`
+}
+
+type MutedKeyword struct {
+	ID        int        `json:"id"`
+	Keyword   string     `json:"keyword"`
+	ExpiresAt *time.Time `json:"expires_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// Mutes est la réponse de GET /api/mutes
+type Mutes struct {
+	Users    []MutedUser    `json:"users"`
+	Keywords []MutedKeyword `json:"keywords"`
+}
+
+type MuteRequest struct {
+	UserID    int        `json:"user_id"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+type MuteKeywordRequest struct {
+	Keyword   string     `json:"keyword"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
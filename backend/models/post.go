@@ -19,6 +19,7 @@ type PostFetch struct {
     CreatedAt    time.Time `json:"created_at"`
     AuthorAvatar string    `json:"author_avatar"`
     Recipients   []int     `json:"recipients,omitempty"`
+    ImageMeta    *Media    `json:"image_meta,omitempty"`
 }
 
 type CommentWithUser struct {
@@ -31,4 +32,5 @@ type CommentWithUser struct {
 		LastName  string `json:"last_name"`
 		Avatar    string `json:"avatar"`
 	} `json:"author"`
+	ImageMeta *Media `json:"image_meta,omitempty"`
 }
\ No newline at end of file
@@ -0,0 +1,56 @@
+package models
+
+import "time"
+
+// Statuts de présence
+const (
+	PresenceOnline  = "online"
+	PresenceAway    = "away"
+	PresenceOffline = "offline"
+)
+
+// Presence est l'état d'un utilisateur tel que vu par les autres
+type Presence struct {
+	UserID   int        `json:"user_id"`
+	Status   string     `json:"status"`
+	LastSeen *time.Time `json:"last_seen,omitempty"` // dernière déconnexion, absent si en ligne
+}
+
+// PresenceEvent est poussé par le hub : user_online, user_away, user_offline
+type PresenceEvent struct {
+	Type     string     `json:"type"`
+	From     int        `json:"from"`
+	GroupID  int        `json:"groupId,omitempty"` // groupe partagé, absent pour un contact privé
+	Status   string     `json:"status"`
+	LastSeen *time.Time `json:"last_seen,omitempty"`
+}
+
+// PresenceWatcher est un utilisateur à prévenir des changements de présence d'un autre,
+// via un groupe commun (GroupID) ou une conversation privée (GroupID = 0)
+type PresenceWatcher struct {
+	UserID  int
+	GroupID int
+}
+
+// PresenceCount est le nombre de connexions d'un utilisateur sur une instance du backend
+type PresenceCount struct {
+	Connections int  `json:"connections"`
+	Away        bool `json:"away,omitempty"`
+}
+
+// PresenceSync est échangé entre instances via le broker : les connexions ouvertes sur Instance.
+// Full indique un instantané complet, Request demande aux autres instances d'envoyer le leur.
+type PresenceSync struct {
+	Instance string                `json:"instance"`
+	Full     bool                  `json:"full,omitempty"`
+	Request  bool                  `json:"request,omitempty"`
+	Users    map[int]PresenceCount `json:"users"`
+}
+
+// TypingEvent est relayé par le hub : typing_start, typing_stop
+type TypingEvent struct {
+	Type    string `json:"type"`
+	From    int    `json:"from"`
+	To      int    `json:"to,omitempty"`
+	GroupID int    `json:"groupId,omitempty"`
+}
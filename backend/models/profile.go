@@ -10,6 +10,10 @@ type Profile struct {
 	Avatar      string `json:"avatar"`
 	DateOfBirth string `json:"date_of_birth"`
 	IsPrivate   bool   `json:"is_private"`
+	AvatarMeta  *Media `json:"avatar_meta,omitempty"`
+
+	FollowersCount int `json:"followers_count"`
+	FollowingCount int `json:"following_count"`
 
 	// Meta info (not stored in DB)
 	IsOwner    bool `json:"is_owner"`
@@ -24,6 +28,46 @@ type SearchResult struct {
 	Nickname  string `json:"nickname"`
 }
 
+// UserSummary est la forme courte d'un utilisateur dans les listes (mutuals...)
+type UserSummary struct {
+	ID        int    `json:"id"`
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name"`
+	Nickname  string `json:"nickname"`
+	Avatar    string `json:"avatar"`
+}
+
+type SharedGroup struct {
+	ID    int    `json:"id"`
+	Title string `json:"title"`
+}
+
+// Relationship décrit le lien entre l'utilisateur connecté et un autre utilisateur
+type Relationship struct {
+	UserID          int           `json:"user_id"`
+	Follows         bool          `json:"follows"`          // je le suis (accepté)
+	FollowedBy      bool          `json:"followed_by"`      // il me suit (accepté)
+	RequestSent     bool          `json:"request_sent"`     // ma demande est en attente
+	RequestReceived bool          `json:"request_received"` // sa demande est en attente
+	Blocking        bool          `json:"blocking"`         // je l'ai bloqué
+	BlockedBy       bool          `json:"blocked_by"`       // il m'a bloqué
+	Muted           bool          `json:"muted"`            // je l'ai mis en sourdine
+	MutualCount     int           `json:"mutual_count"`
+	SharedGroups    []SharedGroup `json:"shared_groups"`
+}
+
 type PrivacyRequest struct {
 	IsPrivate bool `json:"is_private"`
+	// En passant en privé, les abonnés actuels repassent en attente et doivent être ré-approuvés
+	RequireReapproval bool `json:"require_reapproval"`
+}
+
+// PrivacyChange résume les effets d'un changement de confidentialité
+type PrivacyChange struct {
+	IsPrivate         bool  `json:"is_private"`
+	AutoAccepted      []int `json:"auto_accepted"`      // demandes acceptées automatiquement (passage en public)
+	PendingReapproval []int `json:"pending_reapproval"` // abonnés à ré-approuver (passage en privé)
+
+	// Notifications enregistrées pendant la transaction, par destinataire, à pousser en temps réel
+	Notifications map[int]Notification `json:"-"`
 }
@@ -0,0 +1,31 @@
+package models
+
+import "encoding/json"
+
+// Envelope est une trame du protocole WebSocket v2 (/ws?v=2), dans les deux sens.
+// Côté client, ID identifie la trame et est repris par l'ack ou l'erreur qui lui répond ;
+// ClientID est la clé d'idempotence d'un envoi de message. Côté serveur, Seq numérote
+// les événements rejouables (absent pour les événements éphémères, acks et erreurs).
+type Envelope struct {
+	V        int             `json:"v"`
+	Type     string          `json:"type"`
+	ID       string          `json:"id,omitempty"`
+	ClientID string          `json:"client_id,omitempty"`
+	Seq      int64           `json:"seq,omitempty"`
+	Payload  json.RawMessage `json:"payload,omitempty"`
+}
+
+// Ack confirme le traitement d'une trame client ; Duplicate indique un client_id déjà reçu
+// (le message n'a pas été enregistré ni diffusé une seconde fois)
+type Ack struct {
+	MessageID int  `json:"message_id,omitempty"`
+	Duplicate bool `json:"duplicate,omitempty"`
+}
+
+// ProtocolError est le contenu d'une trame error ; RetryAfter (en millisecondes) accompagne
+// les codes rate_limited et duplicate_content
+type ProtocolError struct {
+	Code       string `json:"code"`
+	Message    string `json:"message"`
+	RetryAfter int64  `json:"retry_after_ms,omitempty"`
+}
@@ -0,0 +1,19 @@
+package models
+
+// UserSuggestion est un utilisateur suggéré, avec ce qui le relie au demandeur
+type UserSuggestion struct {
+	ID            int    `json:"id"`
+	FirstName     string `json:"first_name"`
+	LastName      string `json:"last_name"`
+	Nickname      string `json:"nickname"`
+	Avatar        string `json:"avatar"`
+	IsPrivate     bool   `json:"is_private"`
+	MutualFollows int    `json:"mutual_follows"` // personnes suivies par le demandeur qui suivent ce compte
+	SharedGroups  int    `json:"shared_groups"`
+	SharedEvents  int    `json:"shared_events"` // événements auxquels les deux participent
+	Score         int    `json:"score"`
+}
+
+type DismissSuggestionRequest struct {
+	UserID int `json:"user_id"`
+}
@@ -0,0 +1,122 @@
+package repositories
+
+import (
+	"database/sql"
+	"social/models"
+)
+
+type BlockRepository struct {
+	DB *sql.DB
+}
+
+func NewBlockRepository(db *sql.DB) *BlockRepository {
+	return &BlockRepository{DB: db}
+}
+
+// notBlockedSQL retourne une condition vraie quand il n'existe aucun blocage, dans un sens
+// ou dans l'autre, entre la colonne donnée et l'utilisateur passé en paramètre (deux fois).
+func notBlockedSQL(column string) string {
+	return `NOT EXISTS (
+		SELECT 1 FROM blocks b
+		WHERE (b.blocker_id = ? AND b.blocked_id = ` + column + `)
+		   OR (b.blocked_id = ? AND b.blocker_id = ` + column + `)
+	)`
+}
+
+// BlockUser enregistre le blocage et supprime, dans la même transaction, les relations
+// d'abonnement (acceptées ou en attente), les demandes d'abonnement et les demandes de message
+// entre les deux utilisateurs
+func (r *BlockRepository) BlockUser(blockerID, blockedID int) error {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`
+		INSERT OR IGNORE INTO blocks (blocker_id, blocked_id) VALUES (?, ?)
+	`, blockerID, blockedID); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`
+		DELETE FROM followers
+		WHERE (follower_id = ? AND followed_id = ?) OR (follower_id = ? AND followed_id = ?)
+	`, blockerID, blockedID, blockedID, blockerID); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`
+		DELETE FROM notifications
+		WHERE type = 'follow_request'
+		  AND ((user_id = ? AND sender_id = ?) OR (user_id = ? AND sender_id = ?))
+	`, blockerID, blockedID, blockedID, blockerID); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`
+		DELETE FROM message_requests
+		WHERE (sender_id = ? AND recipient_id = ?) OR (sender_id = ? AND recipient_id = ?)
+	`, blockerID, blockedID, blockedID, blockerID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *BlockRepository) UnblockUser(blockerID, blockedID int) error {
+	_, err := r.DB.Exec(`
+		DELETE FROM blocks WHERE blocker_id = ? AND blocked_id = ?
+	`, blockerID, blockedID)
+	return err
+}
+
+// IsBlockedBetween indique si l'un des deux utilisateurs a bloqué l'autre
+func (r *BlockRepository) IsBlockedBetween(userID1, userID2 int) (bool, error) {
+	var exists bool
+	err := r.DB.QueryRow(`
+		SELECT EXISTS(
+			SELECT 1 FROM blocks
+			WHERE (blocker_id = ? AND blocked_id = ?) OR (blocker_id = ? AND blocked_id = ?)
+		)`, userID1, userID2, userID2, userID1).Scan(&exists)
+	return exists, err
+}
+
+// IsBlocking indique si blockerID a bloqué blockedID
+func (r *BlockRepository) IsBlocking(blockerID, blockedID int) (bool, error) {
+	var exists bool
+	err := r.DB.QueryRow(`
+		SELECT EXISTS(SELECT 1 FROM blocks WHERE blocker_id = ? AND blocked_id = ?)
+	`, blockerID, blockedID).Scan(&exists)
+	return exists, err
+}
+
+func (r *BlockRepository) GetBlockedUsers(blockerID int) ([]models.BlockedUser, error) {
+	rows, err := r.DB.Query(`
+		SELECT u.id, u.first_name, u.last_name, COALESCE(u.nickname, ''), COALESCE(u.avatar, ''), b.created_at
+		FROM blocks b
+		JOIN users u ON u.id = b.blocked_id
+		WHERE b.blocker_id = ?
+		ORDER BY b.created_at DESC
+	`, blockerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []models.BlockedUser{}
+	for rows.Next() {
+		var u models.BlockedUser
+		if err := rows.Scan(&u.ID, &u.FirstName, &u.LastName, &u.Nickname, &u.Avatar, &u.BlockedAt); err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+	return users, rows.Err()
+}
+
+func (r *BlockRepository) UserExists(userID int) (bool, error) {
+	var exists bool
+	err := r.DB.QueryRow(`SELECT EXISTS(SELECT 1 FROM users WHERE id = ?)`, userID).Scan(&exists)
+	return exists, err
+}
@@ -5,6 +5,8 @@ import (
 	"errors"
 	"fmt"
 	"social/models"
+	"social/storage"
+	"strings"
 	"time"
 )
 
@@ -17,9 +19,18 @@ func NewChatRepository(db *sql.DB) *ChatRepository {
 	return &ChatRepository{DB: db}
 }
 
-// GetAllUsers returns all users with their privacy info and follow status related to requesterID.
+// GetAllUsers returns all users with their privacy info, the follow status of requesterID
+// towards them and whether they can chat together, in a single query.
 func (r *ChatRepository) GetAllUsers(requesterID int) ([]models.ChatUser, error) {
-	rows, err := r.DB.Query(`SELECT id, first_name, last_name, avatar, is_private FROM users`)
+	// Tous les paramètres désignent le demandeur : u.id != ?, canChatSQL, la jointure et notBlockedSQL
+	args := append([]interface{}{requesterID}, canChatArgs(requesterID)...)
+	args = append(args, requesterID, requesterID, requesterID)
+	rows, err := r.DB.Query(`
+		SELECT u.id, u.first_name, u.last_name, u.avatar, u.is_private, COALESCE(f.status, ''),
+		       u.id != ? AND `+canChatSQL("u.id")+`
+		FROM users u
+		LEFT JOIN followers f ON f.follower_id = ? AND f.followed_id = u.id
+		WHERE `+notBlockedSQL("u.id"), args...)
 	if err != nil {
 		return nil, err
 	}
@@ -28,76 +39,104 @@ func (r *ChatRepository) GetAllUsers(requesterID int) ([]models.ChatUser, error)
 	var users []models.ChatUser
 
 	for rows.Next() {
-		var id int
-		var firstName, lastName, avatar string
-		var isPrivate bool
+		var user models.ChatUser
+		var firstName, lastName string
 
-		if err := rows.Scan(&id, &firstName, &lastName, &avatar, &isPrivate); err != nil {
+		if err := rows.Scan(&user.ID, &firstName, &lastName, &user.Avatar, &user.IsPrivate, &user.FollowStatus, &user.CanChat); err != nil {
 			continue
 		}
 
-		fullName := firstName + " " + lastName
-		user := models.ChatUser{
-			ID:        id,
-			FullName:  fullName,
-			Avatar:    avatar,
-			IsPrivate: isPrivate,
-		}
-
-		// Fetch follow status if not self
-		if id != requesterID {
-			var status string
-			err := r.DB.QueryRow(`SELECT status FROM followers WHERE follower_id = ? AND followed_id = ?`, requesterID, id).Scan(&status)
-			if err == nil {
-				user.FollowStatus = status
-			} else {
-				user.FollowStatus = ""
-			}
-		}
-
+		user.FullName = firstName + " " + lastName
 		users = append(users, user)
 	}
 
-	return users, nil
+	return users, rows.Err()
 }
 
 func (r *ChatRepository) CanUsersChat(userID1, userID2 int) (bool, error) {
-	// Vérifier si user1 suit user2 ET user2 suit user1 (suivi mutuel)
-	var count int
+	var canChat bool
 	err := r.DB.QueryRow(`
-		SELECT COUNT(*) FROM followers 
-		WHERE (follower_id = ? AND followed_id = ? AND status = 'accepted')
-		OR EXISTS (
-			SELECT 1 FROM followers 
-			WHERE follower_id = ? AND followed_id = ? AND status = 'accepted'
-		)
-	`, userID1, userID2, userID2, userID1).Scan(&count)
+		SELECT EXISTS (SELECT 1 FROM users o WHERE o.id = ? AND `+canChatSQL("o.id")+`)
+	`, append([]interface{}{userID2}, canChatArgs(userID1)...)...).Scan(&canChat)
 	if err != nil {
 		return false, err
 	}
 
-	return count > 0, nil
+	return canChat, nil
 }
 
-func (r *ChatRepository) GetChatHistory(userID, otherID int) ([]models.Message, error) {
+// canChatSQL est vraie quand l'utilisateur de la colonne other peut discuter avec le demandeur
+// (paramètres : canChatArgs). Il faut qu'au moins l'un suive l'autre (abonnement accepté), et un compte privé
+// ne discute qu'avec ses abonnés acceptés : c'est relu à chaque appel, donc un passage
+// en privé s'applique aussi aux conversations existantes.
+// Une demande de message acceptée, dans un sens ou dans l'autre, suffit aussi.
+// Un blocage, dans un sens ou dans l'autre, interdit toujours la discussion
+func canChatSQL(other string) string {
+	return `(
+		((
+			EXISTS (
+				SELECT 1 FROM followers
+				WHERE ((follower_id = ? AND followed_id = ` + other + `) OR (follower_id = ` + other + ` AND followed_id = ?))
+				AND status = 'accepted'
+			)
+			AND ((SELECT is_private FROM users WHERE id = ` + other + `) = 0 OR EXISTS (
+				SELECT 1 FROM followers WHERE follower_id = ? AND followed_id = ` + other + ` AND status = 'accepted'
+			))
+			AND ((SELECT is_private FROM users WHERE id = ?) = 0 OR EXISTS (
+				SELECT 1 FROM followers WHERE follower_id = ` + other + ` AND followed_id = ? AND status = 'accepted'
+			))
+		) OR EXISTS (
+			SELECT 1 FROM message_requests
+			WHERE ((sender_id = ? AND recipient_id = ` + other + `) OR (sender_id = ` + other + ` AND recipient_id = ?))
+			AND status = 'accepted'
+		))
+		AND ` + notBlockedSQL(other) + `
+	)`
+}
+
+// canChatArgs retourne les paramètres de canChatSQL pour le demandeur
+func canChatArgs(requesterID int) []interface{} {
+	args := make([]interface{}, 9)
+	for i := range args {
+		args[i] = requesterID
+	}
+	return args
+}
+
+// GetChatHistory retourne au plus limit messages échangés avec otherID, plus anciens que le message
+// before (0 = les plus récents), du plus ancien au plus récent
+func (r *ChatRepository) GetChatHistory(userID, otherID, before, limit int) ([]models.Message, error) {
 	rows, err := r.DB.Query(`
-		SELECT from_id, to_id, content, type, timestamp
+		SELECT id, from_id, to_id, content, type, timestamp, edited_at, deleted_at IS NOT NULL, COALESCE(reply_to, 0)
 		FROM messages
-		WHERE (from_id = ? AND to_id = ?) OR (from_id = ? AND to_id = ?)
-		ORDER BY timestamp ASC
-	`, userID, otherID, otherID, userID)
+		WHERE ((from_id = ? AND to_id = ?) OR (from_id = ? AND to_id = ?))
+		  AND (? = 0 OR id < ?)
+		ORDER BY id DESC
+		LIMIT ?
+	`, userID, otherID, otherID, userID, before, before, limit)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
+	// Jusqu'où l'autre participant a lu les messages de userID
+	readUpTo, err := r.GetReadPosition(otherID, userID)
+	if err != nil {
+		return nil, err
+	}
+
 	var messages []models.Message
 	for rows.Next() {
 		var msg models.Message
 		var ts string
-		if err := rows.Scan(&msg.From, &msg.To, &msg.Content, &msg.Type, &ts); err != nil {
+		var editedAt sql.NullTime
+		if err := rows.Scan(&msg.ID, &msg.From, &msg.To, &msg.Content, &msg.Type, &ts, &editedAt, &msg.Deleted, &msg.ReplyTo); err != nil {
 			continue
 		}
+		if editedAt.Valid && !msg.Deleted {
+			msg.EditedAt = editedAt.Time.Format(time.RFC3339)
+		}
+		msg.Read = msg.From == userID && msg.ID <= readUpTo
 		// Parse timestamp string to time.Time
 		t, err := time.Parse(time.RFC3339, ts)
 		if err != nil {
@@ -106,23 +145,517 @@ func (r *ChatRepository) GetChatHistory(userID, otherID int) ([]models.Message,
 		msg.Timestamp = t.Format(time.RFC3339)
 		messages = append(messages, msg)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Du plus ancien au plus récent
+	for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
+		messages[i], messages[j] = messages[j], messages[i]
+	}
+
+	ids := make([]int, len(messages))
+	for i, msg := range messages {
+		ids[i] = msg.ID
+	}
+	attachments, err := attachmentsByMessage(r.DB, "private", ids)
+	if err != nil {
+		return nil, err
+	}
+	reactions, err := reactionsByMessage(r.DB, "private", ids)
+	if err != nil {
+		return nil, err
+	}
+	for i := range messages {
+		messages[i].Attachments = attachments[messages[i].ID]
+		messages[i].Reactions = reactions[messages[i].ID]
+	}
 	return messages, nil
 }
 
-func (r *ChatRepository) SavePrivateMessage(msg models.Message) error {
-	_, err := r.DB.Exec(`
-		INSERT INTO messages (from_id, to_id, content, type, timestamp)
-		VALUES (?, ?, ?, ?, ?)
-	`, msg.From, msg.To, msg.Content, "private", time.Now())
-	return err
+// SavePrivateMessage enregistre le message, lui rattache ses pièces jointes et retourne son ID.
+// sql.ErrNoRows si l'une des pièces jointes n'existe pas, n'appartient pas à l'expéditeur ou est déjà utilisée.
+// Si l'expéditeur a déjà envoyé un message avec le même client_id, rien n'est enregistré :
+// l'ID du message existant est retourné avec duplicate à true.
+// request est le statut de la demande de message qu'il alimente ("" pour une discussion établie) :
+// les demandes entre les deux utilisateurs sont mises à jour dans la même transaction (updateRequests).
+func (r *ChatRepository) SavePrivateMessage(msg models.Message, request string) (id int, duplicate bool, err error) {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return 0, false, err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`
+		INSERT INTO messages (from_id, to_id, content, type, timestamp, client_id, reply_to)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT DO NOTHING
+	`, msg.From, msg.To, msg.Content, "private", time.Now(), nullClientID(msg.ClientID), nullReplyTo(msg.ReplyTo))
+	if err != nil {
+		return 0, false, err
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		if err == nil {
+			err = tx.QueryRow(`SELECT id FROM messages WHERE from_id = ? AND client_id = ?`, msg.From, msg.ClientID).Scan(&id)
+		}
+		return id, err == nil, err
+	}
+	lastID, err := res.LastInsertId()
+	if err != nil {
+		return 0, false, err
+	}
+
+	if err := claimAttachments(tx, "private", int(lastID), msg.From, msg.AttachmentIDs); err != nil {
+		return 0, false, err
+	}
+	if msg.From != msg.To {
+		if err := updateRequests(tx, msg.From, msg.To, request); err != nil {
+			return 0, false, err
+		}
+	}
+	return int(lastID), false, tx.Commit()
+}
+
+// GetConversations retourne les discussions privées de userID, de la plus récemment active à la plus
+// ancienne : l'autre participant, le dernier message, les messages non lus et si la discussion est
+// encore possible. before (0 = depuis le début) est l'ID du dernier message de la page précédente.
+// Les demandes de message reçues et pas encore acceptées n'y figurent pas.
+func (r *ChatRepository) GetConversations(userID, before, limit int) ([]models.Conversation, error) {
+	args := []interface{}{userID, userID, userID, userID}
+	args = append(args, canChatArgs(userID)...)
+	args = append(args, userID, userID, userID, before, before, limit)
+
+	rows, err := r.DB.Query(`
+		WITH conv AS (
+			SELECT CASE WHEN from_id = ? THEN to_id ELSE from_id END AS other_id, MAX(id) AS last_id
+			FROM messages
+			WHERE from_id = ? OR to_id = ?
+			GROUP BY other_id
+		)
+		SELECT u.id, u.first_name, u.last_name, COALESCE(u.nickname, ''), COALESCE(u.avatar, ''),
+		       m.id, m.from_id, m.content, m.timestamp, m.deleted_at IS NOT NULL,
+		       (SELECT COUNT(*) FROM chat_attachments ca WHERE ca.conversation = 'private' AND ca.message_id = m.id),
+		       (SELECT COUNT(*) FROM messages um
+		        LEFT JOIN conversation_reads cr ON cr.user_id = um.to_id AND cr.other_id = um.from_id
+		        WHERE um.from_id = u.id AND um.to_id = ? AND um.id > COALESCE(cr.last_read_message_id, 0)),
+		       `+canChatSQL("u.id")+`
+		FROM conv
+		JOIN messages m ON m.id = conv.last_id
+		JOIN users u ON u.id = conv.other_id
+		WHERE `+notBlockedSQL("u.id")+`
+		  AND NOT EXISTS (
+			SELECT 1 FROM message_requests mr
+			WHERE mr.sender_id = u.id AND mr.recipient_id = ? AND mr.status != 'accepted'
+		  )
+		  AND (? = 0 OR m.id < ?)
+		ORDER BY m.id DESC
+		LIMIT ?
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var conversations []models.Conversation
+	for rows.Next() {
+		var c models.Conversation
+		var firstName, lastName string
+		var ts time.Time
+		if err := rows.Scan(&c.UserID, &firstName, &lastName, &c.Nickname, &c.Avatar,
+			&c.LastMessage.ID, &c.LastMessage.From, &c.LastMessage.Content, &ts, &c.LastMessage.Deleted,
+			&c.LastMessage.Attachments, &c.UnreadCount, &c.CanChat); err != nil {
+			return nil, err
+		}
+		c.FullName = firstName + " " + lastName
+		c.LastMessage.Timestamp = ts.Format(time.RFC3339)
+		conversations = append(conversations, c)
+	}
+	return conversations, rows.Err()
+}
+
+// GetReadPosition retourne l'ID du dernier message de otherID lu par userID (0 si aucun)
+func (r *ChatRepository) GetReadPosition(userID, otherID int) (int, error) {
+	var lastRead int
+	err := r.DB.QueryRow(`
+		SELECT last_read_message_id FROM conversation_reads WHERE user_id = ? AND other_id = ?
+	`, userID, otherID).Scan(&lastRead)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	return lastRead, err
+}
+
+// MarkConversationRead avance la position de lecture de userID dans sa conversation avec otherID
+// jusqu'au message upTo (ou jusqu'au dernier message reçu si upTo vaut 0).
+// La position ne recule jamais ; advanced indique si elle a bougé.
+func (r *ChatRepository) MarkConversationRead(userID, otherID, upTo int) (lastRead int, advanced bool, err error) {
+	var target sql.NullInt64
+	err = r.DB.QueryRow(`
+		SELECT MAX(id) FROM messages
+		WHERE from_id = ? AND to_id = ? AND (? = 0 OR id <= ?)
+	`, otherID, userID, upTo, upTo).Scan(&target)
+	if err != nil {
+		return 0, false, err
+	}
+	if !target.Valid {
+		return 0, false, nil
+	}
+
+	res, err := r.DB.Exec(`
+		INSERT INTO conversation_reads (user_id, other_id, last_read_message_id, read_at)
+		VALUES (?, ?, ?, CURRENT_TIMESTAMP)
+		ON CONFLICT(user_id, other_id) DO UPDATE SET
+			last_read_message_id = excluded.last_read_message_id,
+			read_at = excluded.read_at
+		WHERE excluded.last_read_message_id > conversation_reads.last_read_message_id
+	`, userID, otherID, target.Int64)
+	if err != nil {
+		return 0, false, err
+	}
+	n, _ := res.RowsAffected()
+	return int(target.Int64), n > 0, nil
+}
+
+// GetUnreadCounts retourne, par expéditeur, le nombre de messages non lus par userID
+func (r *ChatRepository) GetUnreadCounts(userID int) (map[int]int, error) {
+	rows, err := r.DB.Query(`
+		SELECT m.from_id, COUNT(*)
+		FROM messages m
+		LEFT JOIN conversation_reads cr ON cr.user_id = m.to_id AND cr.other_id = m.from_id
+		WHERE m.to_id = ? AND m.id > COALESCE(cr.last_read_message_id, 0)
+		GROUP BY m.from_id
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[int]int)
+	for rows.Next() {
+		var fromID, count int
+		if err := rows.Scan(&fromID, &count); err != nil {
+			return nil, err
+		}
+		counts[fromID] = count
+	}
+	return counts, rows.Err()
+}
+
+// SaveGroupMessage enregistre le message de groupe, lui rattache ses pièces jointes et retourne son ID ;
+// comme SavePrivateMessage, un client_id déjà utilisé retourne le message existant
+func (r *ChatRepository) SaveGroupMessage(msg models.Message) (id int, duplicate bool, err error) {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return 0, false, err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`
+		INSERT INTO group_messages (group_id, sender_id, content, timestamp, client_id, reply_to)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT DO NOTHING
+	`, msg.GroupID, msg.From, msg.Content, time.Now(), nullClientID(msg.ClientID), nullReplyTo(msg.ReplyTo))
+	if err != nil {
+		return 0, false, err
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		if err == nil {
+			err = tx.QueryRow(`SELECT id FROM group_messages WHERE sender_id = ? AND client_id = ?`, msg.From, msg.ClientID).Scan(&id)
+		}
+		return id, err == nil, err
+	}
+	lastID, err := res.LastInsertId()
+	if err != nil {
+		return 0, false, err
+	}
+
+	if err := claimAttachments(tx, "group", int(lastID), msg.From, msg.AttachmentIDs); err != nil {
+		return 0, false, err
+	}
+	return int(lastID), false, tx.Commit()
+}
+
+// nullClientID enregistre NULL quand le client n'a pas fourni de client_id (l'index unique l'ignore)
+func nullClientID(clientID string) sql.NullString {
+	return sql.NullString{String: clientID, Valid: clientID != ""}
+}
+
+// nullReplyTo enregistre NULL pour un message qui ne répond à aucun autre
+func nullReplyTo(replyTo int) sql.NullInt64 {
+	return sql.NullInt64{Int64: int64(replyTo), Valid: replyTo != 0}
+}
+
+// CreateAttachment enregistre une pièce jointe uploadée, pas encore rattachée à un message
+func (r *ChatRepository) CreateAttachment(mediaID, uploaderID int, kind, fileName string) (int, error) {
+	res, err := r.DB.Exec(`
+		INSERT INTO chat_attachments (media_id, uploader_id, kind, file_name)
+		VALUES (?, ?, ?, ?)
+	`, mediaID, uploaderID, kind, fileName)
+	if err != nil {
+		return 0, err
+	}
+	id, err := res.LastInsertId()
+	return int(id), err
+}
+
+// GetAttachments retourne les pièces jointes des messages donnés, indexées par ID de message
+func (r *ChatRepository) GetAttachments(conversation string, messageIDs []int) (map[int][]models.Attachment, error) {
+	return attachmentsByMessage(r.DB, conversation, messageIDs)
+}
+
+// claimAttachments rattache au message des pièces jointes libres de l'uploader ; toutes doivent l'être
+func claimAttachments(tx *sql.Tx, conversation string, messageID, uploaderID int, attachmentIDs []int) error {
+	if len(attachmentIDs) == 0 {
+		return nil
+	}
+
+	args := []interface{}{conversation, messageID, uploaderID}
+	for _, id := range attachmentIDs {
+		args = append(args, id)
+	}
+	res, err := tx.Exec(`
+		UPDATE chat_attachments SET conversation = ?, message_id = ?
+		WHERE uploader_id = ? AND message_id IS NULL
+		  AND id IN (`+strings.TrimSuffix(strings.Repeat("?,", len(attachmentIDs)), ",")+`)
+	`, args...)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); int(n) != len(attachmentIDs) {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// attachmentsByMessage charge les pièces jointes (avec les URLs publiques de leurs fichiers)
+// des messages privés ou de groupe donnés
+func attachmentsByMessage(db *sql.DB, conversation string, messageIDs []int) (map[int][]models.Attachment, error) {
+	result := make(map[int][]models.Attachment)
+	if len(messageIDs) == 0 {
+		return result, nil
+	}
+
+	args := []interface{}{conversation}
+	for _, id := range messageIDs {
+		args = append(args, id)
+	}
+	rows, err := db.Query(`
+		SELECT ca.id, ca.message_id, ca.kind, ca.file_name, m.path, m.mime_type, m.size,
+		       m.width, m.height, COALESCE(m.blurhash, ''), COALESCE(m.thumb_path, '')
+		FROM chat_attachments ca
+		JOIN media m ON m.id = ca.media_id
+		WHERE ca.conversation = ?
+		  AND ca.message_id IN (`+strings.TrimSuffix(strings.Repeat("?,", len(messageIDs)), ",")+`)
+		ORDER BY ca.id
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var a models.Attachment
+		if err := rows.Scan(&a.ID, &a.MessageID, &a.Kind, &a.FileName, &a.URL, &a.MimeType, &a.Size,
+			&a.Width, &a.Height, &a.BlurHash, &a.ThumbURL); err != nil {
+			return nil, err
+		}
+		a.URL = storage.MediaURL(a.URL)
+		a.ThumbURL = storage.MediaURL(a.ThumbURL)
+		result[a.MessageID] = append(result[a.MessageID], a)
+	}
+	return result, rows.Err()
+}
+
+// GetPrivateMessageRef retourne l'auteur, le destinataire et l'état d'un message privé
+func (r *ChatRepository) GetPrivateMessageRef(messageID int) (models.MessageRef, error) {
+	ref := models.MessageRef{ID: messageID}
+	err := r.DB.QueryRow(`
+		SELECT from_id, to_id, timestamp, deleted_at IS NOT NULL FROM messages WHERE id = ?
+	`, messageID).Scan(&ref.From, &ref.To, &ref.SentAt, &ref.Deleted)
+	return ref, err
+}
+
+// GetGroupMessageRef retourne l'auteur, le groupe (et son créateur) et l'état d'un message de groupe
+func (r *ChatRepository) GetGroupMessageRef(messageID int) (models.MessageRef, error) {
+	ref := models.MessageRef{ID: messageID}
+	err := r.DB.QueryRow(`
+		SELECT gm.sender_id, gm.group_id, COALESCE(g.creator_id, 0), gm.timestamp, gm.deleted_at IS NOT NULL
+		FROM group_messages gm
+		JOIN groups g ON g.id = gm.group_id
+		WHERE gm.id = ?
+	`, messageID).Scan(&ref.From, &ref.GroupID, &ref.GroupCreatorID, &ref.SentAt, &ref.Deleted)
+	return ref, err
+}
+
+// messageTables associe le type de conversation de message_edits à sa table
+var messageTables = map[string]string{
+	"private": "messages",
+	"group":   "group_messages",
+}
+
+// EditMessage remplace le contenu d'un message non supprimé en gardant l'ancien dans message_edits
+func (r *ChatRepository) EditMessage(conversation string, messageID, editorID int, content string) (time.Time, error) {
+	table := messageTables[conversation]
+	editedAt := time.Now().UTC()
+
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return editedAt, err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`
+		INSERT INTO message_edits (conversation, message_id, previous_content, edited_by, edited_at)
+		SELECT ?, id, content, ?, ? FROM `+table+` WHERE id = ? AND deleted_at IS NULL
+	`, conversation, editorID, editedAt, messageID)
+	if err != nil {
+		return editedAt, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return editedAt, sql.ErrNoRows
+	}
+
+	if _, err := tx.Exec(`
+		UPDATE `+table+` SET content = ?, edited_at = ? WHERE id = ?
+	`, content, editedAt, messageID); err != nil {
+		return editedAt, err
+	}
+
+	return editedAt, tx.Commit()
+}
+
+// DeleteMessage remplace un message par une trace de suppression : contenu vidé et deleted_at renseigné.
+// L'historique des modifications et les pièces jointes sont effacés avec lui.
+func (r *ChatRepository) DeleteMessage(conversation string, messageID, deletedBy int) error {
+	table := messageTables[conversation]
+
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `UPDATE ` + table + ` SET content = '', deleted_at = ? WHERE id = ? AND deleted_at IS NULL`
+	args := []interface{}{time.Now().UTC(), messageID}
+	if conversation == "group" {
+		query = `UPDATE group_messages SET content = '', deleted_at = ?, deleted_by = ? WHERE id = ? AND deleted_at IS NULL`
+		args = []interface{}{time.Now().UTC(), deletedBy, messageID}
+	}
+
+	res, err := tx.Exec(query, args...)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+
+	if _, err := tx.Exec(`
+		DELETE FROM message_edits WHERE conversation = ? AND message_id = ?
+	`, conversation, messageID); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`
+		DELETE FROM message_reactions WHERE conversation = ? AND message_id = ?
+	`, conversation, messageID); err != nil {
+		return err
+	}
+
+	// Les fichiers joints ne sont plus référencés : le nettoyage des orphelins les supprimera
+	if _, err := tx.Exec(`
+		DELETE FROM chat_attachments WHERE conversation = ? AND message_id = ?
+	`, conversation, messageID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
-func (r *ChatRepository) SaveGroupMessage(msg models.Message) error {
-	_, err := r.DB.Exec(`
-		INSERT INTO group_messages (group_id, sender_id, content, timestamp)
+// AddReaction pose emoji sur un message ; false si userID l'y avait déjà mis
+func (r *ChatRepository) AddReaction(conversation string, messageID, userID int, emoji string) (bool, error) {
+	res, err := r.DB.Exec(`
+		INSERT INTO message_reactions (conversation, message_id, user_id, emoji)
 		VALUES (?, ?, ?, ?)
-	`, msg.GroupID, msg.From, msg.Content, time.Now())
-	return err
+		ON CONFLICT DO NOTHING
+	`, conversation, messageID, userID, emoji)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// RemoveReaction retire l'emoji de userID d'un message ; false s'il n'y était pas
+func (r *ChatRepository) RemoveReaction(conversation string, messageID, userID int, emoji string) (bool, error) {
+	res, err := r.DB.Exec(`
+		DELETE FROM message_reactions
+		WHERE conversation = ? AND message_id = ? AND user_id = ? AND emoji = ?
+	`, conversation, messageID, userID, emoji)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// CountUserReactions retourne le nombre d'emojis différents que userID a posés sur un message
+func (r *ChatRepository) CountUserReactions(conversation string, messageID, userID int) (int, error) {
+	var count int
+	err := r.DB.QueryRow(`
+		SELECT COUNT(*) FROM message_reactions WHERE conversation = ? AND message_id = ? AND user_id = ?
+	`, conversation, messageID, userID).Scan(&count)
+	return count, err
+}
+
+// GetReactions retourne les réactions des messages donnés, indexées par ID de message
+func (r *ChatRepository) GetReactions(conversation string, messageIDs []int) (map[int][]models.Reaction, error) {
+	return reactionsByMessage(r.DB, conversation, messageIDs)
+}
+
+// reactionsByMessage regroupe par emoji les réactions des messages privés ou de groupe donnés,
+// chaque emoji à la place de sa première réaction
+func reactionsByMessage(db *sql.DB, conversation string, messageIDs []int) (map[int][]models.Reaction, error) {
+	result := make(map[int][]models.Reaction)
+	if len(messageIDs) == 0 {
+		return result, nil
+	}
+
+	args := []interface{}{conversation}
+	for _, id := range messageIDs {
+		args = append(args, id)
+	}
+	rows, err := db.Query(`
+		SELECT message_id, emoji, user_id
+		FROM message_reactions
+		WHERE conversation = ?
+		  AND message_id IN (`+strings.TrimSuffix(strings.Repeat("?,", len(messageIDs)), ",")+`)
+		ORDER BY id
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var messageID, userID int
+		var emoji string
+		if err := rows.Scan(&messageID, &emoji, &userID); err != nil {
+			return nil, err
+		}
+		reactions := result[messageID]
+		i := 0
+		for i < len(reactions) && reactions[i].Emoji != emoji {
+			i++
+		}
+		if i == len(reactions) {
+			reactions = append(reactions, models.Reaction{Emoji: emoji})
+		}
+		reactions[i].Count++
+		reactions[i].UserIDs = append(reactions[i].UserIDs, userID)
+		result[messageID] = reactions
+	}
+	return result, rows.Err()
 }
 
 // repository/message_repository.go
@@ -215,36 +748,3 @@ func (r *ChatRepository) GetGroupMembers(groupID int) ([]models.GroupMember, err
 
 	return members, nil
 }
-
-func (r *ChatRepository) CheckPrivateProfileAccess(senderID, recipientID int) (bool, error) {
-	if senderID == recipientID {
-		return true, nil
-	}
-
-	var isPrivate bool
-	err := r.DB.QueryRow(`
-		SELECT is_private FROM users WHERE id = ?
-	`, recipientID).Scan(&isPrivate)
-	if err != nil {
-		return false, err
-	}
-
-	if !isPrivate {
-		return true, nil
-	}
-
-	var status string
-	err = r.DB.QueryRow(`
-		SELECT status FROM followers 
-		WHERE follower_id = ? AND followed_id = ? AND status = 'accepted'
-	`, senderID, recipientID).Scan(&status)
-
-	if errors.Is(err, sql.ErrNoRows) {
-		return false, nil
-	}
-	if err != nil {
-		return false, err
-	}
-
-	return status == "accepted", nil
-}
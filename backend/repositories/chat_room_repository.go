@@ -0,0 +1,318 @@
+package repositories
+
+import (
+	"database/sql"
+	"social/models"
+	"social/storage"
+	"time"
+)
+
+type ChatRoomRepository struct {
+	DB *sql.DB
+}
+
+func NewChatRoomRepository(db *sql.DB) *ChatRoomRepository {
+	return &ChatRoomRepository{DB: db}
+}
+
+// CreateRoom crée la discussion avec son créateur (admin) et ses autres participants
+func (r *ChatRoomRepository) CreateRoom(title string, creatorID int, participantIDs []int) (int, error) {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`INSERT INTO chat_rooms (title, creator_id) VALUES (?, ?)`, title, creatorID)
+	if err != nil {
+		return 0, err
+	}
+	roomID, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	if _, err := tx.Exec(`
+		INSERT INTO chat_room_participants (room_id, user_id, role) VALUES (?, ?, ?)
+	`, roomID, creatorID, models.RoomRoleAdmin); err != nil {
+		return 0, err
+	}
+	for _, userID := range participantIDs {
+		if _, err := tx.Exec(`
+			INSERT OR IGNORE INTO chat_room_participants (room_id, user_id, role) VALUES (?, ?, ?)
+		`, roomID, userID, models.RoomRoleMember); err != nil {
+			return 0, err
+		}
+	}
+
+	return int(roomID), tx.Commit()
+}
+
+// GetRoom retourne la discussion et ses participants (admins d'abord) ; sql.ErrNoRows si elle n'existe pas
+func (r *ChatRoomRepository) GetRoom(roomID int) (models.ChatRoom, error) {
+	room := models.ChatRoom{ID: roomID}
+	err := r.DB.QueryRow(`
+		SELECT title, creator_id, created_at FROM chat_rooms WHERE id = ?
+	`, roomID).Scan(&room.Title, &room.CreatorID, &room.CreatedAt)
+	if err != nil {
+		return room, err
+	}
+
+	rows, err := r.DB.Query(`
+		SELECT u.id, u.nickname, u.first_name || ' ' || u.last_name, COALESCE(u.avatar, ''), p.role
+		FROM chat_room_participants p
+		JOIN users u ON u.id = p.user_id
+		WHERE p.room_id = ?
+		ORDER BY p.role = 'admin' DESC, p.joined_at, p.rowid
+	`, roomID)
+	if err != nil {
+		return room, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var p models.RoomParticipant
+		if err := rows.Scan(&p.UserID, &p.Nickname, &p.FullName, &p.Avatar, &p.Role); err != nil {
+			return room, err
+		}
+		p.Avatar = storage.MediaURL(p.Avatar)
+		room.Participants = append(room.Participants, p)
+	}
+	return room, rows.Err()
+}
+
+// GetRoomsForUser retourne les discussions de userID avec son rôle et le dernier message qu'il peut voir,
+// de la plus récemment active à la plus ancienne
+func (r *ChatRoomRepository) GetRoomsForUser(userID int) ([]models.ChatRoom, error) {
+	rows, err := r.DB.Query(`
+		SELECT cr.id, cr.title, cr.creator_id, cr.created_at, p.role,
+		       m.id, m.sender_id, m.content, m.timestamp
+		FROM chat_room_participants p
+		JOIN chat_rooms cr ON cr.id = p.room_id
+		LEFT JOIN chat_room_messages m ON m.id = (
+			SELECT MAX(id) FROM chat_room_messages WHERE room_id = cr.id AND id > p.history_from
+		)
+		WHERE p.user_id = ?
+		ORDER BY COALESCE(m.timestamp, p.joined_at) DESC, cr.id DESC
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rooms []models.ChatRoom
+	for rows.Next() {
+		var room models.ChatRoom
+		var msgID, senderID sql.NullInt64
+		var content sql.NullString
+		var sentAt sql.NullTime
+		if err := rows.Scan(&room.ID, &room.Title, &room.CreatorID, &room.CreatedAt, &room.Role,
+			&msgID, &senderID, &content, &sentAt); err != nil {
+			return nil, err
+		}
+		if msgID.Valid {
+			room.LastMessage = &models.ConversationPreview{
+				ID:        int(msgID.Int64),
+				From:      int(senderID.Int64),
+				Content:   content.String,
+				Timestamp: sentAt.Time.Format(time.RFC3339),
+			}
+		}
+		rooms = append(rooms, room)
+	}
+	return rooms, rows.Err()
+}
+
+// GetParticipantRole retourne le rôle de userID dans la discussion ; sql.ErrNoRows s'il n'y participe pas
+func (r *ChatRoomRepository) GetParticipantRole(roomID, userID int) (string, error) {
+	var role string
+	err := r.DB.QueryRow(`
+		SELECT role FROM chat_room_participants WHERE room_id = ? AND user_id = ?
+	`, roomID, userID).Scan(&role)
+	return role, err
+}
+
+// GetParticipantIDs retourne les IDs des participants actuels
+func (r *ChatRoomRepository) GetParticipantIDs(roomID int) ([]int, error) {
+	rows, err := r.DB.Query(`SELECT user_id FROM chat_room_participants WHERE room_id = ?`, roomID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// AddParticipants ajoute des membres à la discussion ; ils ne verront que les messages envoyés après.
+// Retourne ceux qui n'y participaient pas déjà.
+func (r *ChatRoomRepository) AddParticipants(roomID int, userIDs []int) ([]int, error) {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var added []int
+	for _, userID := range userIDs {
+		res, err := tx.Exec(`
+			INSERT OR IGNORE INTO chat_room_participants (room_id, user_id, role, history_from)
+			VALUES (?, ?, ?, (SELECT COALESCE(MAX(id), 0) FROM chat_room_messages WHERE room_id = ?))
+		`, roomID, userID, models.RoomRoleMember, roomID)
+		if err != nil {
+			return nil, err
+		}
+		if n, _ := res.RowsAffected(); n > 0 {
+			added = append(added, userID)
+		}
+	}
+	return added, tx.Commit()
+}
+
+// RemoveParticipant retire userID de la discussion (false s'il n'y participait pas). Si c'était le créateur,
+// le plus ancien admin (à défaut le plus ancien participant) le devient ; s'il ne reste aucun admin,
+// le plus ancien participant est nommé admin.
+func (r *ChatRoomRepository) RemoveParticipant(roomID, userID int) (bool, error) {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`DELETE FROM chat_room_participants WHERE room_id = ? AND user_id = ?`, roomID, userID)
+	if err != nil {
+		return false, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return false, nil
+	}
+
+	var successor int
+	err = tx.QueryRow(`
+		SELECT user_id FROM chat_room_participants
+		WHERE room_id = ?
+		ORDER BY role = 'admin' DESC, joined_at, rowid
+		LIMIT 1
+	`, roomID).Scan(&successor)
+	if err == sql.ErrNoRows {
+		// Plus personne : la discussion reste telle quelle
+		return true, tx.Commit()
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if _, err := tx.Exec(`
+		UPDATE chat_rooms SET creator_id = ? WHERE id = ? AND creator_id = ?
+	`, successor, roomID, userID); err != nil {
+		return false, err
+	}
+	if _, err := tx.Exec(`
+		UPDATE chat_room_participants SET role = ?
+		WHERE room_id = ? AND user_id = ?
+		  AND (user_id = (SELECT creator_id FROM chat_rooms WHERE id = ?)
+		       OR NOT EXISTS (SELECT 1 FROM chat_room_participants WHERE room_id = ? AND role = ?))
+	`, models.RoomRoleAdmin, roomID, successor, roomID, roomID, models.RoomRoleAdmin); err != nil {
+		return false, err
+	}
+
+	return true, tx.Commit()
+}
+
+// SetRole change le rôle d'un participant ; false s'il n'y participe pas ou avait déjà ce rôle
+func (r *ChatRoomRepository) SetRole(roomID, userID int, role string) (bool, error) {
+	res, err := r.DB.Exec(`
+		UPDATE chat_room_participants SET role = ? WHERE room_id = ? AND user_id = ? AND role != ?
+	`, role, roomID, userID, role)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+func (r *ChatRoomRepository) RenameRoom(roomID int, title string) error {
+	_, err := r.DB.Exec(`UPDATE chat_rooms SET title = ? WHERE id = ?`, title, roomID)
+	return err
+}
+
+// SaveRoomMessage enregistre le message et retourne son ID ; comme SavePrivateMessage,
+// un client_id déjà utilisé par l'expéditeur retourne le message existant avec duplicate à true
+func (r *ChatRoomRepository) SaveRoomMessage(msg models.Message) (id int, duplicate bool, err error) {
+	res, err := r.DB.Exec(`
+		INSERT INTO chat_room_messages (room_id, sender_id, content, timestamp, client_id, reply_to)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT DO NOTHING
+	`, msg.RoomID, msg.From, msg.Content, time.Now(), nullClientID(msg.ClientID), nullReplyTo(msg.ReplyTo))
+	if err != nil {
+		return 0, false, err
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		if err == nil {
+			err = r.DB.QueryRow(`
+				SELECT id FROM chat_room_messages WHERE sender_id = ? AND client_id = ?
+			`, msg.From, msg.ClientID).Scan(&id)
+		}
+		return id, err == nil, err
+	}
+	lastID, err := res.LastInsertId()
+	return int(lastID), false, err
+}
+
+// IsVisibleMessage indique si le message messageID de la discussion fait partie de ce que userID peut voir
+func (r *ChatRoomRepository) IsVisibleMessage(roomID, userID, messageID int) (bool, error) {
+	var visible bool
+	err := r.DB.QueryRow(`
+		SELECT EXISTS (
+			SELECT 1 FROM chat_room_messages m
+			JOIN chat_room_participants p ON p.room_id = m.room_id AND p.user_id = ?
+			WHERE m.id = ? AND m.room_id = ? AND m.id > p.history_from
+		)
+	`, userID, messageID, roomID).Scan(&visible)
+	return visible, err
+}
+
+// GetRoomHistory retourne au plus limit messages de la discussion visibles par userID, plus anciens que
+// le message before (0 = les plus récents), du plus ancien au plus récent
+func (r *ChatRoomRepository) GetRoomHistory(roomID, userID, before, limit int) ([]models.Message, error) {
+	rows, err := r.DB.Query(`
+		SELECT m.id, m.sender_id, m.content, m.timestamp, COALESCE(m.reply_to, 0)
+		FROM chat_room_messages m
+		JOIN chat_room_participants p ON p.room_id = m.room_id AND p.user_id = ?
+		WHERE m.room_id = ? AND m.id > p.history_from
+		  AND (? = 0 OR m.id < ?)
+		ORDER BY m.id DESC
+		LIMIT ?
+	`, userID, roomID, before, before, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var messages []models.Message
+	for rows.Next() {
+		msg := models.Message{Type: "room_message", RoomID: roomID}
+		var sentAt time.Time
+		if err := rows.Scan(&msg.ID, &msg.From, &msg.Content, &sentAt, &msg.ReplyTo); err != nil {
+			return nil, err
+		}
+		msg.Timestamp = sentAt.Format(time.RFC3339)
+		messages = append(messages, msg)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Du plus ancien au plus récent
+	for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
+		messages[i], messages[j] = messages[j], messages[i]
+	}
+	return messages, nil
+}
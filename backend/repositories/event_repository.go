@@ -0,0 +1,90 @@
+package repositories
+
+import (
+	"database/sql"
+	"social/models"
+	"time"
+)
+
+type EventRepository struct {
+	DB *sql.DB
+}
+
+func NewEventRepository(db *sql.DB) *EventRepository {
+	return &EventRepository{DB: db}
+}
+
+// AppendEvents enregistre les événements dans une seule transaction et renseigne leur Seq
+func (r *EventRepository) AppendEvents(events []*models.UserEvent) error {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := time.Now().UTC()
+	for _, e := range events {
+		if err := tx.QueryRow(`
+			INSERT INTO user_event_seqs (user_id, last_seq) VALUES (?, 1)
+			ON CONFLICT(user_id) DO UPDATE SET last_seq = last_seq + 1
+			RETURNING last_seq
+		`, e.UserID).Scan(&e.Seq); err != nil {
+			return err
+		}
+
+		e.CreatedAt = now
+		if _, err := tx.Exec(`
+			INSERT INTO user_events (user_id, seq, type, payload, created_at) VALUES (?, ?, ?, ?, ?)
+		`, e.UserID, e.Seq, e.Type, string(e.Payload), now.Format(sqliteTimeFormat)); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetEventsSince retourne au plus limit événements de userID dont le seq est supérieur à since, dans l'ordre
+func (r *EventRepository) GetEventsSince(userID int, since int64, limit int) ([]models.UserEvent, error) {
+	rows, err := r.DB.Query(`
+		SELECT seq, type, payload, created_at
+		FROM user_events
+		WHERE user_id = ? AND seq > ?
+		ORDER BY seq
+		LIMIT ?
+	`, userID, since, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []models.UserEvent
+	for rows.Next() {
+		e := models.UserEvent{UserID: userID}
+		var payload string
+		if err := rows.Scan(&e.Seq, &e.Type, &payload, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		e.Payload = []byte(payload)
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
+
+// GetSeqRange retourne le plus ancien seq encore dans le journal de userID (0 si vide)
+// et le dernier seq attribué (0 si aucun)
+func (r *EventRepository) GetSeqRange(userID int) (oldest, last int64, err error) {
+	err = r.DB.QueryRow(`
+		SELECT COALESCE((SELECT MIN(seq) FROM user_events WHERE user_id = ?), 0),
+		       COALESCE((SELECT last_seq FROM user_event_seqs WHERE user_id = ?), 0)
+	`, userID, userID).Scan(&oldest, &last)
+	return oldest, last, err
+}
+
+// PruneEvents supprime les événements enregistrés avant before et retourne leur nombre
+func (r *EventRepository) PruneEvents(before time.Time) (int64, error) {
+	res, err := r.DB.Exec(`DELETE FROM user_events WHERE created_at < ?`, before.UTC().Format(sqliteTimeFormat))
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
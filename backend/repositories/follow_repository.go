@@ -67,45 +67,21 @@ func (r *FollowRepository) GetFollowStatus(followerID, followedID int) (string,
 	return status, nil
 }
 
-func (r *FollowRepository) AcceptFollowRequest(followerID, followedID int) error {
-	_, err := r.DB.Exec(`
-		UPDATE followers SET status = 'accepted'
-		WHERE follower_id = ? AND followed_id = ?
-	`, followerID, followedID)
-	return err
-}
-
-func (r *FollowRepository) RejectFollowRequest(followerID, followedID int) error {
-	_, err := r.DB.Exec(`
-		DELETE FROM followers
-		WHERE follower_id = ? AND followed_id = ?
-	`, followerID, followedID)
-	return err
-}
-
-// Notification logic
-func (r *FollowRepository) UpdateFollowNotificationStatus(senderID, userID int, status string) error {
-	_, err := r.DB.Exec(`
-		UPDATE notifications 
-		SET seen = 1
-		WHERE sender_id = ? AND user_id = ? AND type = 'follow_request'
-	`, senderID, userID)
-	return err
-}
-
 func (r *FollowRepository) UnfollowUser(followerID, followedID int) error {
 	query := `DELETE FROM followers WHERE follower_id = ? AND followed_id = ?`
 	_, err := r.DB.Exec(query, followerID, followedID)
 	return err
 }
 
-func (r *FollowRepository) GetFollowers(userID int) ([]models.Follower, error) {
+// GetFollowers retourne les abonnés de userID, sans ceux bloqués par (ou bloquant) viewerID
+func (r *FollowRepository) GetFollowers(userID, viewerID int) ([]models.Follower, error) {
 	rows, err := r.DB.Query(`
 		SELECT users.id, users.first_name, users.last_name, users.nickname, users.avatar
 		FROM followers
 		JOIN users ON users.id = followers.follower_id
 		WHERE followers.followed_id = ? AND followers.status = 'accepted'
-	`, userID)
+		AND `+notBlockedSQL("users.id")+`
+	`, userID, viewerID, viewerID)
 	if err != nil {
 		return nil, err
 	}
@@ -122,14 +98,16 @@ func (r *FollowRepository) GetFollowers(userID int) ([]models.Follower, error) {
 	return followers, nil
 }
 
-func (r *FollowRepository) GetFollowing(userID int) ([]models.Following, error) {
+// GetFollowing retourne les abonnements de userID, sans ceux bloqués par (ou bloquant) viewerID
+func (r *FollowRepository) GetFollowing(userID, viewerID int) ([]models.Following, error) {
 	query := `
 		SELECT u.id, u.nickname, u.first_name, u.last_name, u.avatar
 		FROM followers f
 		JOIN users u ON f.followed_id = u.id
 		WHERE f.follower_id = ? AND f.status = 'accepted'
+		AND ` + notBlockedSQL("u.id") + `
 	`
-	rows, err := r.DB.Query(query, userID)
+	rows, err := r.DB.Query(query, userID, viewerID, viewerID)
 	if err != nil {
 		return nil, err
 	}
@@ -168,4 +146,118 @@ func (r *FollowRepository) GetAcceptedFollowers(userID int) ([]models.Follower,
 	}
 
 	return followers, nil
-}
\ No newline at end of file
+}
+
+// GetFollowRequests liste les demandes en attente reçues (incoming) ou envoyées (outgoing) par userID,
+// les plus récentes d'abord, avec le nombre total pour la pagination
+func (r *FollowRepository) GetFollowRequests(userID int, incoming bool, limit, offset int) ([]models.FollowRequestUser, int, error) {
+	self, other := "f.followed_id", "f.follower_id"
+	if !incoming {
+		self, other = other, self
+	}
+
+	var total int
+	err := r.DB.QueryRow(`
+		SELECT COUNT(*) FROM followers f
+		WHERE `+self+` = ? AND f.status = 'pending'
+	`, userID).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := r.DB.Query(`
+		SELECT u.id, u.first_name, u.last_name, COALESCE(u.nickname, ''), COALESCE(u.avatar, ''), f.created_at
+		FROM followers f
+		JOIN users u ON u.id = `+other+`
+		WHERE `+self+` = ? AND f.status = 'pending'
+		ORDER BY f.created_at DESC, u.id DESC
+		LIMIT ? OFFSET ?
+	`, userID, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	requests := []models.FollowRequestUser{}
+	for rows.Next() {
+		var u models.FollowRequestUser
+		if err := rows.Scan(&u.ID, &u.FirstName, &u.LastName, &u.Nickname, &u.Avatar, &u.RequestedAt); err != nil {
+			return nil, 0, err
+		}
+		requests = append(requests, u)
+	}
+	return requests, total, rows.Err()
+}
+
+// RespondToFollowRequests accepte ou refuse les demandes en attente envoyées à followedID par senderIDs,
+// et marque leurs notifications comme vues, dans une seule transaction.
+// Retourne les IDs réellement traités (les demandes inexistantes sont ignorées).
+func (r *FollowRepository) RespondToFollowRequests(followedID int, senderIDs []int, accept bool) ([]int, error) {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	query := `DELETE FROM followers WHERE follower_id = ? AND followed_id = ? AND status = 'pending'`
+	if accept {
+		query = `UPDATE followers SET status = 'accepted' WHERE follower_id = ? AND followed_id = ? AND status = 'pending'`
+	}
+
+	processed := []int{}
+	for _, senderID := range senderIDs {
+		res, err := tx.Exec(query, senderID, followedID)
+		if err != nil {
+			return nil, err
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			continue
+		}
+		if _, err := tx.Exec(`
+			UPDATE notifications SET seen = 1
+			WHERE sender_id = ? AND user_id = ? AND type = 'follow_request'
+		`, senderID, followedID); err != nil {
+			return nil, err
+		}
+		processed = append(processed, senderID)
+	}
+
+	return processed, tx.Commit()
+}
+
+// CancelFollowRequest retire la demande en attente de followerID vers followedID
+// et la notification follow_request correspondante
+func (r *FollowRepository) CancelFollowRequest(followerID, followedID int) (bool, error) {
+	return r.deleteFollowWithNotification(followerID, followedID, `AND status = 'pending'`)
+}
+
+// RemoveFollower retire followerID des abonnés de userID (acceptés ou en attente)
+func (r *FollowRepository) RemoveFollower(userID, followerID int) (bool, error) {
+	return r.deleteFollowWithNotification(followerID, userID, "")
+}
+
+func (r *FollowRepository) deleteFollowWithNotification(followerID, followedID int, condition string) (bool, error) {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`
+		DELETE FROM followers WHERE follower_id = ? AND followed_id = ? `+condition, followerID, followedID)
+	if err != nil {
+		return false, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return false, nil
+	}
+
+	if _, err := tx.Exec(`
+		DELETE FROM notifications
+		WHERE sender_id = ? AND user_id = ? AND type = 'follow_request'
+	`, followerID, followedID); err != nil {
+		return false, err
+	}
+
+	return true, tx.Commit()
+}
@@ -4,6 +4,7 @@ import (
 	"database/sql"
 	"fmt"
 	"social/models"
+	"social/storage"
 	"time"
 )
 
@@ -146,19 +147,29 @@ func (r *GroupRepository) UpsertGroupInvitation(groupID, userID int) error {
 	return err
 }
 
-func (r *GroupRepository) ApproveMembershipRequest(groupID, userID int) error {
-	_, err := r.db.Exec(`
+// ApproveMembershipRequest accepte la demande en attente de userID ; false s'il n'y en avait pas
+func (r *GroupRepository) ApproveMembershipRequest(groupID, userID int) (bool, error) {
+	res, err := r.db.Exec(`
 		UPDATE group_memberships
 		SET status = 'accepted'
 		WHERE group_id = ? AND user_id = ? AND status = 'pending'`, groupID, userID)
-	return err
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
 }
 
-func (r *GroupRepository) DeclineMembershipRequest(groupID, userID int) error {
-	_, err := r.db.Exec(`
+// DeclineMembershipRequest supprime la demande en attente de userID ; false s'il n'y en avait pas
+func (r *GroupRepository) DeclineMembershipRequest(groupID, userID int) (bool, error) {
+	res, err := r.db.Exec(`
 		DELETE FROM group_memberships
 		WHERE group_id = ? AND user_id = ? AND status = 'pending'`, groupID, userID)
-	return err
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
 }
 
 func (r *GroupRepository) GetNonGroupMembers(groupID, userID int) ([]map[string]interface{}, error) {
@@ -167,9 +178,10 @@ func (r *GroupRepository) GetNonGroupMembers(groupID, userID int) ([]map[string]
 		WHERE id NOT IN (
 			SELECT user_id FROM group_memberships WHERE group_id = ?
 		)
-		AND id != ?`
+		AND id != ?
+		AND ` + notBlockedSQL("users.id")
 
-	rows, err := r.db.Query(query, groupID, userID)
+	rows, err := r.db.Query(query, groupID, userID, userID, userID)
 	if err != nil {
 		return nil, err
 	}
@@ -191,6 +203,8 @@ func (r *GroupRepository) GetNonGroupMembers(groupID, userID int) ([]map[string]
 	return users, nil
 }
 
+// GetGroupPosts retourne les posts du groupe visibles par userID : sans ceux des utilisateurs qu'il masque
+// ni de ceux bloqués par (ou bloquant) lui, dont les commentaires ne sont pas comptés non plus
 func (r *GroupRepository) GetGroupPosts(groupID, userID int) ([]models.GroupPost, error) {
 	// Check if user is member or creator of the group
 	var memberExists int
@@ -207,17 +221,23 @@ func (r *GroupRepository) GetGroupPosts(groupID, userID int) ([]models.GroupPost
 		return nil, fmt.Errorf("user not authorized")
 	}
 
+	muted, err := mutedKeywordsFor(r.db, userID)
+	if err != nil {
+		return nil, err
+	}
+
 	rows, err := r.db.Query(`
 		SELECT gp.id, gp.group_id, gp.author_id, gp.content, gp.image, gp.created_at,
 			   u.nickname as author_name, u.avatar as avatar,
 			   COUNT(gpc.id) as comments_count
 		FROM group_posts gp
 		JOIN users u ON gp.author_id = u.id
-		LEFT JOIN group_post_comments gpc ON gp.id = gpc.post_id
-		WHERE gp.group_id = ?
+		LEFT JOIN group_post_comments gpc ON gp.id = gpc.post_id AND `+notBlockedSQL("gpc.author_id")+`
+		WHERE gp.group_id = ? AND `+notMutedSQL("gp.author_id")+`
+		  AND `+notBlockedSQL("gp.author_id")+`
 		GROUP BY gp.id
 		ORDER BY gp.created_at DESC`,
-		groupID)
+		userID, userID, groupID, userID, userID, userID, userID)
 	if err != nil {
 		return nil, err
 	}
@@ -232,9 +252,12 @@ func (r *GroupRepository) GetGroupPosts(groupID, userID int) ([]models.GroupPost
 		); err != nil {
 			return nil, err
 		}
+		if muted.hides(post.AuthorID, post.Content) {
+			continue
+		}
 
 		if post.AuthorAvatar != "" {
-			post.AuthorAvatar = "http://localhost:8080/" + post.AuthorAvatar
+			post.AuthorAvatar = storage.MediaURL(post.AuthorAvatar)
 		}
 
 		posts = append(posts, post)
@@ -289,12 +312,13 @@ func (r *GroupRepository) CreateGroupPost(post models.GroupPost) (*models.GroupP
 
 	// Prepend avatar URL if exists
 	if fullPost.AuthorAvatar != "" {
-		fullPost.AuthorAvatar = "http://localhost:8080/" + fullPost.AuthorAvatar
+		fullPost.AuthorAvatar = storage.MediaURL(fullPost.AuthorAvatar)
 	}
 
 	return &fullPost, nil
 }
 
+// GetGroupPostComments retourne les commentaires du post, sans ceux des utilisateurs bloqués par (ou bloquant) userID
 func (r *GroupRepository) GetGroupPostComments(postID, userID int) ([]models.GroupPostComment, error) {
 	// Verify user has access to the group post
 	var groupID int
@@ -322,9 +346,9 @@ func (r *GroupRepository) GetGroupPostComments(postID, userID int) ([]models.Gro
 			   u.nickname as author_name, u.avatar as avatar
 		FROM group_post_comments gpc
 		JOIN users u ON gpc.author_id = u.id
-		WHERE gpc.post_id = ?
+		WHERE gpc.post_id = ? AND `+notBlockedSQL("gpc.author_id")+`
 		ORDER BY gpc.created_at ASC`,
-		postID)
+		postID, userID, userID)
 	if err != nil {
 		return []models.GroupPostComment{}, err
 	}
@@ -342,7 +366,7 @@ func (r *GroupRepository) GetGroupPostComments(postID, userID int) ([]models.Gro
 		}
 
 		if comment.AuthorAvatar != "" {
-			comment.AuthorAvatar = "http://localhost:8080/" + comment.AuthorAvatar
+			comment.AuthorAvatar = storage.MediaURL(comment.AuthorAvatar)
 		}
 
 		comments = append(comments, comment)
@@ -388,7 +412,7 @@ func (r *GroupRepository) GetGroupPostCommentByID(commentID int) (models.GroupPo
 	}
 
 	if comment.AuthorAvatar != "" {
-		comment.AuthorAvatar = "http://localhost:8080/" + comment.AuthorAvatar
+		comment.AuthorAvatar = storage.MediaURL(comment.AuthorAvatar)
 	}
 
 	return comment, nil
@@ -644,7 +668,7 @@ func (r *GroupRepository) GetGroupMembers(groupID int) ([]models.GroupMember, er
         
         member.JoinedAt = joinedAt.Format(time.RFC3339)
         if member.Avatar != "" {
-            member.Avatar = "http://localhost:8080/" + member.Avatar
+            member.Avatar = storage.MediaURL(member.Avatar)
         }
         
         members = append(members, member)
@@ -663,65 +687,141 @@ func (r *GroupRepository) GroupExists(groupID int) (bool, error) {
     return exists, err
 }
 
+// groupMessageColumns sont les colonnes lues par scanGroupMessages (alias gm pour group_messages, u pour l'expéditeur)
+const groupMessageColumns = `
+	gm.id, gm.group_id, gm.sender_id, gm.content, gm.timestamp,
+	u.nickname, u.avatar, gm.edited_at, gm.deleted_at IS NOT NULL, COALESCE(gm.reply_to, 0),
+	(SELECT COUNT(*) FROM group_messages r WHERE r.reply_to = gm.id)`
+
 func (r *GroupRepository) GetGroupChatHistory(groupID int, limit int) ([]models.GroupMessage, error) {
-    query := `
-        SELECT 
-            gm.id, 
-            gm.group_id, 
-            gm.sender_id, 
-            gm.content, 
-            gm.timestamp,
-            u.nickname as sender_nickname,
-            u.avatar as sender_avatar
-        FROM group_messages gm
-        JOIN users u ON gm.sender_id = u.id
-        WHERE gm.group_id = ?
-        ORDER BY gm.timestamp DESC
-        LIMIT ?
-    `
+	rows, err := r.db.Query(`
+		SELECT `+groupMessageColumns+`
+		FROM group_messages gm
+		JOIN users u ON gm.sender_id = u.id
+		WHERE gm.group_id = ?
+		ORDER BY gm.timestamp DESC
+		LIMIT ?
+	`, groupID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query group chat history: %w", err)
+	}
 
-    rows, err := r.db.Query(query, groupID, limit)
-    if err != nil {
-        return nil, fmt.Errorf("failed to query group chat history: %w", err)
-    }
-    defer rows.Close()
+	messages, err := r.scanGroupMessages(rows)
+	if err != nil {
+		return nil, err
+	}
 
-    var messages []models.GroupMessage
-    for rows.Next() {
-        var msg models.GroupMessage
-        var timestamp time.Time
-        
-        err := rows.Scan(
-            &msg.ID,
-            &msg.GroupID,
-            &msg.SenderID,
-            &msg.Content,
-            &timestamp,
-            &msg.SenderNickname,
-            &msg.SenderAvatar,
-        )
-        if err != nil {
-            return nil, fmt.Errorf("failed to scan message row: %w", err)
-        }
-        
-        msg.Timestamp = timestamp
-        if msg.SenderAvatar != "" {
-            msg.SenderAvatar = "http://localhost:8080/" + msg.SenderAvatar
-        }
-        
-        messages = append(messages, msg)
-    }
+	// Reverse the order to have oldest messages first
+	for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
+		messages[i], messages[j] = messages[j], messages[i]
+	}
+	return messages, nil
+}
 
-    if err := rows.Err(); err != nil {
-        return nil, fmt.Errorf("rows iteration error: %w", err)
-    }
+// GetGroupThread retourne le message rootID du groupe et au plus limit de ses réponses, directes ou
+// non, plus anciennes que before (0 = les plus récentes), de la plus ancienne à la plus récente.
+// sql.ErrNoRows si le message n'est pas dans le groupe.
+func (r *GroupRepository) GetGroupThread(groupID, rootID, before, limit int) (models.GroupMessage, []models.GroupMessage, error) {
+	rows, err := r.db.Query(`
+		SELECT `+groupMessageColumns+`
+		FROM group_messages gm
+		JOIN users u ON gm.sender_id = u.id
+		WHERE gm.id = ? AND gm.group_id = ?
+	`, rootID, groupID)
+	if err != nil {
+		return models.GroupMessage{}, nil, fmt.Errorf("failed to query thread root: %w", err)
+	}
+	roots, err := r.scanGroupMessages(rows)
+	if err != nil {
+		return models.GroupMessage{}, nil, err
+	}
+	if len(roots) == 0 {
+		return models.GroupMessage{}, nil, sql.ErrNoRows
+	}
 
-    // Reverse the order to have oldest messages first
-    for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
-        messages[i], messages[j] = messages[j], messages[i]
-    }
+	rows, err = r.db.Query(`
+		WITH RECURSIVE thread(id) AS (
+			SELECT id FROM group_messages WHERE reply_to = ? AND group_id = ?
+			UNION
+			SELECT gm.id FROM group_messages gm JOIN thread t ON gm.reply_to = t.id
+		)
+		SELECT `+groupMessageColumns+`
+		FROM group_messages gm
+		JOIN users u ON gm.sender_id = u.id
+		WHERE gm.id IN (SELECT id FROM thread)
+		  AND (? = 0 OR gm.id < ?)
+		ORDER BY gm.id DESC
+		LIMIT ?
+	`, rootID, groupID, before, before, limit)
+	if err != nil {
+		return models.GroupMessage{}, nil, fmt.Errorf("failed to query thread replies: %w", err)
+	}
+	replies, err := r.scanGroupMessages(rows)
+	if err != nil {
+		return models.GroupMessage{}, nil, err
+	}
+
+	for i, j := 0, len(replies)-1; i < j; i, j = i+1, j-1 {
+		replies[i], replies[j] = replies[j], replies[i]
+	}
+	return roots[0], replies, nil
+}
 
-    return messages, nil
+// scanGroupMessages lit des lignes de groupMessageColumns et ferme rows, puis charge les pièces jointes
+// et les réactions des messages
+func (r *GroupRepository) scanGroupMessages(rows *sql.Rows) ([]models.GroupMessage, error) {
+	defer rows.Close()
+
+	var messages []models.GroupMessage
+	for rows.Next() {
+		var msg models.GroupMessage
+		var editedAt sql.NullTime
+		err := rows.Scan(
+			&msg.ID,
+			&msg.GroupID,
+			&msg.SenderID,
+			&msg.Content,
+			&msg.Timestamp,
+			&msg.SenderNickname,
+			&msg.SenderAvatar,
+			&editedAt,
+			&msg.Deleted,
+			&msg.ReplyTo,
+			&msg.ReplyCount,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan message row: %w", err)
+		}
+		if editedAt.Valid && !msg.Deleted {
+			msg.EditedAt = &editedAt.Time
+		}
+		if msg.SenderAvatar != "" {
+			msg.SenderAvatar = storage.MediaURL(msg.SenderAvatar)
+		}
+
+		messages = append(messages, msg)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration error: %w", err)
+	}
+
+	ids := make([]int, len(messages))
+	for i, msg := range messages {
+		ids[i] = msg.ID
+	}
+	attachments, err := attachmentsByMessage(r.db, "group", ids)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load attachments: %w", err)
+	}
+	reactions, err := reactionsByMessage(r.db, "group", ids)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load reactions: %w", err)
+	}
+	for i := range messages {
+		messages[i].Attachments = attachments[messages[i].ID]
+		messages[i].Reactions = reactions[messages[i].ID]
+	}
+	return messages, nil
 }
 
 func (r *GroupRepository) CreateNotification(recipientID int, notification models.Notification) (int, error) {
@@ -0,0 +1,290 @@
+package repositories
+
+import (
+	"database/sql"
+	"social/models"
+	"strings"
+)
+
+type MediaRepository struct {
+	DB *sql.DB
+}
+
+func NewMediaRepository(db *sql.DB) *MediaRepository {
+	return &MediaRepository{DB: db}
+}
+
+// CreateMedia enregistre les métadonnées d'un fichier uploadé (ownerID = 0 pour un upload anonyme)
+func (r *MediaRepository) CreateMedia(media *models.Media) error {
+	var ownerID interface{}
+	if media.OwnerID > 0 {
+		ownerID = media.OwnerID
+	}
+
+	res, err := r.DB.Exec(`
+		INSERT INTO media (path, owner_id, mime_type, size, width, height, blurhash, thumb_path, medium_path)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		media.Path, ownerID, media.MimeType, media.Size, media.Width, media.Height,
+		media.BlurHash, media.ThumbPath, media.MediumPath,
+	)
+	if err != nil {
+		return err
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	media.ID = int(id)
+	return nil
+}
+
+// GetMediaByPaths retourne les métadonnées connues pour les chemins donnés, indexées par chemin
+func (r *MediaRepository) GetMediaByPaths(paths []string) (map[string]*models.Media, error) {
+	result := make(map[string]*models.Media)
+
+	var args []interface{}
+	for _, p := range paths {
+		if p != "" {
+			args = append(args, p)
+		}
+	}
+	if len(args) == 0 {
+		return result, nil
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(args)), ",")
+	rows, err := r.DB.Query(`
+		SELECT id, path, COALESCE(owner_id, 0), mime_type, size, width, height,
+		       COALESCE(blurhash, ''), COALESCE(thumb_path, ''), COALESCE(medium_path, '')
+		FROM media
+		WHERE path IN (`+placeholders+`)`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var m models.Media
+		if err := rows.Scan(
+			&m.ID, &m.Path, &m.OwnerID, &m.MimeType, &m.Size, &m.Width, &m.Height,
+			&m.BlurHash, &m.ThumbPath, &m.MediumPath,
+		); err != nil {
+			return nil, err
+		}
+		result[m.Path] = &m
+	}
+
+	return result, rows.Err()
+}
+
+// FindOriginal retourne le chemin de l'upload d'origine (et son propriétaire) pour un chemin
+// qui peut être l'original ou l'une de ses variantes. Chaîne vide si le fichier est inconnu.
+func (r *MediaRepository) FindOriginal(paths []string) (string, int, error) {
+	if len(paths) == 0 {
+		return "", 0, nil
+	}
+	in, args := inClause(paths)
+	args = append(append(args, args...), args...)
+
+	var original string
+	var ownerID int
+	err := r.DB.QueryRow(`
+		SELECT path, COALESCE(owner_id, 0)
+		FROM media
+		WHERE path IN (`+in+`) OR thumb_path IN (`+in+`) OR medium_path IN (`+in+`)
+		LIMIT 1`, args...).Scan(&original, &ownerID)
+	if err == sql.ErrNoRows {
+		return "", 0, nil
+	}
+	return original, ownerID, err
+}
+
+// GetMimeType retourne le type MIME détecté à l'upload pour un chemin qui peut être l'original
+// ou l'une de ses variantes. Chaîne vide si le fichier est inconnu.
+func (r *MediaRepository) GetMimeType(paths []string) (string, error) {
+	if len(paths) == 0 {
+		return "", nil
+	}
+	in, args := inClause(paths)
+	args = append(append(args, args...), args...)
+
+	var mimeType string
+	err := r.DB.QueryRow(`
+		SELECT mime_type
+		FROM media
+		WHERE path IN (`+in+`) OR thumb_path IN (`+in+`) OR medium_path IN (`+in+`)
+		LIMIT 1`, args...).Scan(&mimeType)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return mimeType, err
+}
+
+// IsAvatar indique si l'un des chemins est l'avatar d'un utilisateur
+func (r *MediaRepository) IsAvatar(paths []string) (bool, error) {
+	in, args := inClause(paths)
+	var count int
+	err := r.DB.QueryRow(`SELECT COUNT(*) FROM users WHERE avatar IN (`+in+`)`, args...).Scan(&count)
+	return count > 0, err
+}
+
+// postVisibleCondition est vraie quand le viewer (6 paramètres) peut voir le post p de l'auteur u
+// (les deux derniers paramètres excluent les auteurs bloqués par, ou bloquant, le viewer)
+var postVisibleCondition = `(
+	p.author_id = ?
+	OR (
+		(u.is_private = 0 OR EXISTS(
+			SELECT 1 FROM followers f
+			WHERE f.follower_id = ? AND f.followed_id = p.author_id AND f.status = 'accepted'
+		))
+		AND (
+			p.privacy IN ('public', 'followers')
+			OR (p.privacy = 'custom' AND EXISTS(
+				SELECT 1 FROM post_permissions pp WHERE pp.post_id = p.id AND pp.user_id = ?
+			))
+		)
+		AND (p.privacy != 'followers' OR u.is_private = 1 OR EXISTS(
+			SELECT 1 FROM followers f
+			WHERE f.follower_id = ? AND f.followed_id = p.author_id AND f.status = 'accepted'
+		))
+		AND ` + notBlockedSQL("p.author_id") + `
+	)
+)`
+
+// PostImageAccess retourne si l'un des chemins est l'image d'un post, et si le viewer peut le voir
+func (r *MediaRepository) PostImageAccess(viewerID int, paths []string) (bool, bool, error) {
+	in, args := inClause(paths)
+	return r.countAccess(`
+		SELECT COUNT(*), COALESCE(SUM(CASE WHEN `+postVisibleCondition+` THEN 1 ELSE 0 END), 0)
+		FROM posts p
+		JOIN users u ON u.id = p.author_id
+		WHERE p.image_url IN (`+in+`)`,
+		append([]interface{}{viewerID, viewerID, viewerID, viewerID, viewerID, viewerID}, args...)...)
+}
+
+// CommentImageAccess retourne si l'un des chemins est l'image d'un commentaire, et si le viewer voit le post parent
+func (r *MediaRepository) CommentImageAccess(viewerID int, paths []string) (bool, bool, error) {
+	in, args := inClause(paths)
+	return r.countAccess(`
+		SELECT COUNT(*), COALESCE(SUM(CASE WHEN `+postVisibleCondition+` THEN 1 ELSE 0 END), 0)
+		FROM comments c
+		JOIN posts p ON p.id = c.post_id
+		JOIN users u ON u.id = p.author_id
+		WHERE c.image IN (`+in+`)`,
+		append([]interface{}{viewerID, viewerID, viewerID, viewerID, viewerID, viewerID}, args...)...)
+}
+
+// GroupPostImageAccess retourne si l'un des chemins est l'image d'un post de groupe, et si le viewer en est membre
+func (r *MediaRepository) GroupPostImageAccess(viewerID int, paths []string) (bool, bool, error) {
+	in, args := inClause(paths)
+	return r.countAccess(`
+		SELECT COUNT(*), COALESCE(SUM(CASE WHEN g.creator_id = ? OR EXISTS(
+			SELECT 1 FROM group_memberships gm
+			WHERE gm.group_id = g.id AND gm.user_id = ? AND gm.status = 'accepted'
+		) THEN 1 ELSE 0 END), 0)
+		FROM group_posts gp
+		JOIN groups g ON g.id = gp.group_id
+		WHERE gp.image IN (`+in+`)`,
+		append([]interface{}{viewerID, viewerID}, args...)...)
+}
+
+// ChatAttachmentAccess retourne si l'un des chemins est une pièce jointe envoyée dans le chat, et si le viewer
+// participe à la conversation privée ou est membre du groupe
+func (r *MediaRepository) ChatAttachmentAccess(viewerID int, paths []string) (bool, bool, error) {
+	in, args := inClause(paths)
+	return r.countAccess(`
+		SELECT COUNT(*), COALESCE(SUM(CASE WHEN
+			(ca.conversation = 'private' AND EXISTS(
+				SELECT 1 FROM messages msg WHERE msg.id = ca.message_id AND ? IN (msg.from_id, msg.to_id)
+			))
+			OR (ca.conversation = 'group' AND EXISTS(
+				SELECT 1 FROM group_messages gmsg
+				JOIN groups g ON g.id = gmsg.group_id
+				WHERE gmsg.id = ca.message_id AND (g.creator_id = ? OR EXISTS(
+					SELECT 1 FROM group_memberships gm
+					WHERE gm.group_id = g.id AND gm.user_id = ? AND gm.status = 'accepted'
+				))
+			))
+		THEN 1 ELSE 0 END), 0)
+		FROM chat_attachments ca
+		JOIN media m ON m.id = ca.media_id
+		WHERE ca.message_id IS NOT NULL AND m.path IN (`+in+`)`,
+		append([]interface{}{viewerID, viewerID, viewerID}, args...)...)
+}
+
+func (r *MediaRepository) countAccess(query string, args ...interface{}) (bool, bool, error) {
+	var total, visible int
+	if err := r.DB.QueryRow(query, args...).Scan(&total, &visible); err != nil {
+		return false, false, err
+	}
+	return total > 0, visible > 0, nil
+}
+
+// inClause construit les placeholders d'une clause IN pour une liste de chaînes
+func inClause(values []string) (string, []interface{}) {
+	args := make([]interface{}, len(values))
+	for i, v := range values {
+		args[i] = v
+	}
+	return strings.TrimSuffix(strings.Repeat("?,", len(values)), ","), args
+}
+
+// GetStorageUsage retourne le total (en octets, variantes comprises) des fichiers d'un utilisateur
+func (r *MediaRepository) GetStorageUsage(ownerID int) (int64, error) {
+	var total int64
+	err := r.DB.QueryRow(`SELECT COALESCE(SUM(size), 0) FROM media WHERE owner_id = ?`, ownerID).Scan(&total)
+	return total, err
+}
+
+// ReferencedPaths retourne tous les chemins de fichiers encore utilisés par l'application
+func (r *MediaRepository) ReferencedPaths() (map[string]bool, error) {
+	rows, err := r.DB.Query(`
+		SELECT avatar FROM users WHERE avatar IS NOT NULL AND avatar != ''
+		UNION SELECT image_url FROM posts WHERE image_url IS NOT NULL AND image_url != ''
+		UNION SELECT image FROM comments WHERE image IS NOT NULL AND image != ''
+		UNION SELECT image FROM group_posts WHERE image IS NOT NULL AND image != ''
+		UNION SELECT m.path FROM chat_attachments ca JOIN media m ON m.id = ca.media_id WHERE ca.message_id IS NOT NULL`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	paths := make(map[string]bool)
+	for rows.Next() {
+		var p string
+		if err := rows.Scan(&p); err != nil {
+			return nil, err
+		}
+		paths[p] = true
+	}
+	return paths, rows.Err()
+}
+
+// ListMedia retourne toutes les métadonnées enregistrées (utilisé par le nettoyage des orphelins)
+func (r *MediaRepository) ListMedia() ([]models.Media, error) {
+	rows, err := r.DB.Query(`
+		SELECT id, path, COALESCE(owner_id, 0), size,
+		       COALESCE(thumb_path, ''), COALESCE(medium_path, ''), created_at
+		FROM media`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var media []models.Media
+	for rows.Next() {
+		var m models.Media
+		if err := rows.Scan(&m.ID, &m.Path, &m.OwnerID, &m.Size, &m.ThumbPath, &m.MediumPath, &m.CreatedAt); err != nil {
+			return nil, err
+		}
+		media = append(media, m)
+	}
+	return media, rows.Err()
+}
+
+// DeleteMedia supprime les métadonnées d'un fichier
+func (r *MediaRepository) DeleteMedia(id int) error {
+	_, err := r.DB.Exec(`DELETE FROM media WHERE id = ?`, id)
+	return err
+}
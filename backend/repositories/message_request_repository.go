@@ -0,0 +1,166 @@
+package repositories
+
+import (
+	"database/sql"
+	"errors"
+	"social/models"
+	"time"
+)
+
+type MessageRequestRepository struct {
+	DB *sql.DB
+}
+
+func NewMessageRequestRepository(db *sql.DB) *MessageRequestRepository {
+	return &MessageRequestRepository{DB: db}
+}
+
+// dmPolicySQL est le réglage effectif de l'utilisateur de l'alias u : sans choix explicite,
+// un compte privé n'accepte que ses abonnés et un compte public tout le monde
+const dmPolicySQL = `COALESCE(u.dm_policy, CASE WHEN u.is_private = 1 THEN 'followers' ELSE 'everyone' END)`
+
+// GetDMPolicy retourne le réglage effectif de userID ; sql.ErrNoRows si l'utilisateur n'existe pas
+func (r *MessageRequestRepository) GetDMPolicy(userID int) (string, error) {
+	var policy string
+	err := r.DB.QueryRow(`SELECT `+dmPolicySQL+` FROM users u WHERE u.id = ?`, userID).Scan(&policy)
+	return policy, err
+}
+
+func (r *MessageRequestRepository) SetDMPolicy(userID int, policy string) error {
+	_, err := r.DB.Exec(`UPDATE users SET dm_policy = ? WHERE id = ?`, policy, userID)
+	return err
+}
+
+// AllowsMessagesFrom indique si le réglage de recipientID laisse senderID lui écrire ;
+// sql.ErrNoRows si le destinataire n'existe pas
+func (r *MessageRequestRepository) AllowsMessagesFrom(recipientID, senderID int) (bool, error) {
+	var allowed bool
+	err := r.DB.QueryRow(`
+		SELECT CASE `+dmPolicySQL+`
+			WHEN 'everyone' THEN 1
+			WHEN 'followers' THEN EXISTS (
+				SELECT 1 FROM followers WHERE follower_id = ? AND followed_id = u.id AND status = 'accepted'
+			)
+			WHEN 'mutuals' THEN EXISTS (
+				SELECT 1 FROM followers WHERE follower_id = ? AND followed_id = u.id AND status = 'accepted'
+			) AND EXISTS (
+				SELECT 1 FROM followers WHERE follower_id = u.id AND followed_id = ? AND status = 'accepted'
+			)
+			ELSE 0
+		END
+		FROM users u WHERE u.id = ?
+	`, senderID, senderID, senderID, recipientID).Scan(&allowed)
+	return allowed, err
+}
+
+// GetStatus retourne le statut de la demande de senderID à recipientID ("" s'il n'y en a pas)
+func (r *MessageRequestRepository) GetStatus(senderID, recipientID int) (string, error) {
+	var status string
+	err := r.DB.QueryRow(`
+		SELECT status FROM message_requests WHERE sender_id = ? AND recipient_id = ?
+	`, senderID, recipientID).Scan(&status)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	return status, err
+}
+
+// IsAccepted indique si l'un des deux utilisateurs a accepté une demande de l'autre
+func (r *MessageRequestRepository) IsAccepted(userID1, userID2 int) (bool, error) {
+	var accepted bool
+	err := r.DB.QueryRow(`
+		SELECT EXISTS (
+			SELECT 1 FROM message_requests
+			WHERE ((sender_id = ? AND recipient_id = ?) OR (sender_id = ? AND recipient_id = ?))
+			  AND status = 'accepted'
+		)
+	`, userID1, userID2, userID2, userID1).Scan(&accepted)
+	return accepted, err
+}
+
+// HasRequestBetween indique s'il existe une demande, quel que soit son statut, entre les deux utilisateurs
+func (r *MessageRequestRepository) HasRequestBetween(userID1, userID2 int) (bool, error) {
+	var exists bool
+	err := r.DB.QueryRow(`
+		SELECT EXISTS (
+			SELECT 1 FROM message_requests
+			WHERE (sender_id = ? AND recipient_id = ?) OR (sender_id = ? AND recipient_id = ?)
+		)
+	`, userID1, userID2, userID2, userID1).Scan(&exists)
+	return exists, err
+}
+
+// updateRequests applique l'effet d'un message de senderID à recipientID sur leurs demandes, dans la transaction
+// qui l'enregistre. Dans une discussion établie (request vide), répondre accepte la demande reçue de recipientID
+// et une demande en attente de l'expéditeur est acceptée ; sinon sa demande est ouverte si elle n'existe pas déjà.
+func updateRequests(tx *sql.Tx, senderID, recipientID int, request string) error {
+	if request != "" {
+		_, err := tx.Exec(`
+			INSERT OR IGNORE INTO message_requests (sender_id, recipient_id, status) VALUES (?, ?, ?)
+		`, senderID, recipientID, models.MessageRequestPending)
+		return err
+	}
+	_, err := tx.Exec(`
+		UPDATE message_requests SET status = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE ((sender_id = ? AND recipient_id = ?) OR (sender_id = ? AND recipient_id = ? AND status = ?))
+		  AND status != ?
+	`, models.MessageRequestAccepted, recipientID, senderID, senderID, recipientID, models.MessageRequestPending,
+		models.MessageRequestAccepted)
+	return err
+}
+
+// SetStatus change le statut de la demande de senderID à recipientID ; false si elle n'existe pas
+// ou avait déjà ce statut
+func (r *MessageRequestRepository) SetStatus(senderID, recipientID int, status string) (bool, error) {
+	res, err := r.DB.Exec(`
+		UPDATE message_requests SET status = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE sender_id = ? AND recipient_id = ? AND status != ?
+	`, status, senderID, recipientID, status)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// GetRequests retourne les demandes reçues par recipientID avec ce statut, avec leur dernier message,
+// de la plus récemment active à la plus ancienne
+func (r *MessageRequestRepository) GetRequests(recipientID int, status string) ([]models.MessageRequest, error) {
+	rows, err := r.DB.Query(`
+		SELECT u.id, u.first_name, u.last_name, COALESCE(u.nickname, ''), COALESCE(u.avatar, ''),
+		       mr.status, mr.created_at,
+		       (SELECT COUNT(*) FROM messages WHERE from_id = u.id AND to_id = mr.recipient_id),
+		       m.id, m.content, m.timestamp, m.deleted_at IS NOT NULL,
+		       (SELECT COUNT(*) FROM chat_attachments ca WHERE ca.conversation = 'private' AND ca.message_id = m.id)
+		FROM message_requests mr
+		JOIN users u ON u.id = mr.sender_id
+		JOIN messages m ON m.id = (
+			SELECT MAX(id) FROM messages WHERE from_id = mr.sender_id AND to_id = mr.recipient_id
+		)
+		WHERE mr.recipient_id = ? AND mr.status = ?
+		ORDER BY m.id DESC
+	`, recipientID, status)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var requests []models.MessageRequest
+	for rows.Next() {
+		var req models.MessageRequest
+		var firstName, lastName string
+		var createdAt, sentAt time.Time
+		if err := rows.Scan(&req.UserID, &firstName, &lastName, &req.Nickname, &req.Avatar,
+			&req.Status, &createdAt, &req.MessageCount,
+			&req.LastMessage.ID, &req.LastMessage.Content, &sentAt, &req.LastMessage.Deleted,
+			&req.LastMessage.Attachments); err != nil {
+			return nil, err
+		}
+		req.FullName = firstName + " " + lastName
+		req.LastMessage.From = req.UserID
+		req.LastMessage.Timestamp = sentAt.Format(time.RFC3339)
+		req.CreatedAt = createdAt.Format(time.RFC3339)
+		requests = append(requests, req)
+	}
+	return requests, rows.Err()
+}
@@ -0,0 +1,206 @@
+package repositories
+
+import (
+	"database/sql"
+	"social/models"
+	"strings"
+	"time"
+	"unicode"
+)
+
+type MuteRepository struct {
+	DB *sql.DB
+}
+
+func NewMuteRepository(db *sql.DB) *MuteRepository {
+	return &MuteRepository{DB: db}
+}
+
+// Les dates d'expiration sont stockées en UTC au format de CURRENT_TIMESTAMP pour pouvoir
+// être comparées directement en SQL.
+const sqliteTimeFormat = "2006-01-02 15:04:05"
+
+const activeMute = `(m.expires_at IS NULL OR m.expires_at > CURRENT_TIMESTAMP)`
+const activeKeyword = `(k.expires_at IS NULL OR k.expires_at > CURRENT_TIMESTAMP)`
+
+// notMutedUserSQL est vraie quand l'utilisateur passé en paramètre n'a pas masqué la colonne donnée
+func notMutedUserSQL(column string) string {
+	return `NOT EXISTS (
+		SELECT 1 FROM mutes m
+		WHERE m.muter_id = ? AND m.muted_id = ` + column + ` AND ` + activeMute + `
+	)`
+}
+
+// notMutedSQL est vraie quand l'auteur d'un contenu n'est pas masqué pour le viewer passé en
+// paramètre (deux fois) : ses propres contenus ne sont jamais masqués. Les mots-clés sont
+// vérifiés ensuite en Go avec mutedKeywords.hides, LOWER() de SQLite ne gérant que l'ASCII.
+func notMutedSQL(authorColumn string) string {
+	return `(` + authorColumn + ` = ? OR ` + notMutedUserSQL(authorColumn) + `)`
+}
+
+// mutedKeywords sont les mots-clés actifs d'un viewer, déjà découpés en mots
+type mutedKeywords struct {
+	viewerID int
+	keywords []string
+}
+
+// mutedKeywordsFor charge les mots-clés actuellement masqués par viewerID
+func mutedKeywordsFor(db *sql.DB, viewerID int) (mutedKeywords, error) {
+	rows, err := db.Query(`SELECT k.keyword FROM muted_keywords k WHERE k.user_id = ? AND `+activeKeyword, viewerID)
+	if err != nil {
+		return mutedKeywords{}, err
+	}
+	defer rows.Close()
+
+	muted := mutedKeywords{viewerID: viewerID}
+	for rows.Next() {
+		var keyword string
+		if err := rows.Scan(&keyword); err != nil {
+			return mutedKeywords{}, err
+		}
+		if w := words(keyword); w != "  " {
+			muted.keywords = append(muted.keywords, w)
+		}
+	}
+	return muted, rows.Err()
+}
+
+// hides indique si un contenu de authorID est masqué par un mot-clé : les mots-clés ne masquent que
+// des mots (ou suites de mots) entiers, "cat" masque "Cat!" mais pas "education"
+func (m mutedKeywords) hides(authorID int, content string) bool {
+	if authorID == m.viewerID || len(m.keywords) == 0 {
+		return false
+	}
+	text := words(content)
+	for _, k := range m.keywords {
+		if strings.Contains(text, k) {
+			return true
+		}
+	}
+	return false
+}
+
+// words met s en minuscules, remplace tout ce qui n'est ni lettre ni chiffre par un espace et
+// entoure le résultat d'espaces : chercher " mot " dedans ne trouve que des mots entiers
+func words(s string) string {
+	fields := strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	return " " + strings.Join(fields, " ") + " "
+}
+
+func nullableTime(t *time.Time) interface{} {
+	if t == nil {
+		return nil
+	}
+	return t.UTC().Format(sqliteTimeFormat)
+}
+
+// MuteUser masque mutedID pour muterID, ou met à jour l'expiration d'un masquage existant
+func (r *MuteRepository) MuteUser(muterID, mutedID int, expiresAt *time.Time) error {
+	_, err := r.DB.Exec(`
+		INSERT INTO mutes (muter_id, muted_id, expires_at) VALUES (?, ?, ?)
+		ON CONFLICT (muter_id, muted_id) DO UPDATE SET expires_at = excluded.expires_at
+	`, muterID, mutedID, nullableTime(expiresAt))
+	return err
+}
+
+func (r *MuteRepository) UnmuteUser(muterID, mutedID int) error {
+	_, err := r.DB.Exec(`DELETE FROM mutes WHERE muter_id = ? AND muted_id = ?`, muterID, mutedID)
+	return err
+}
+
+// IsMuted indique si muterID a actuellement masqué mutedID
+func (r *MuteRepository) IsMuted(muterID, mutedID int) (bool, error) {
+	var exists bool
+	err := r.DB.QueryRow(`
+		SELECT EXISTS(
+			SELECT 1 FROM mutes m WHERE m.muter_id = ? AND m.muted_id = ? AND `+activeMute+`
+		)`, muterID, mutedID).Scan(&exists)
+	return exists, err
+}
+
+func (r *MuteRepository) GetMutedUsers(muterID int) ([]models.MutedUser, error) {
+	rows, err := r.DB.Query(`
+		SELECT u.id, u.first_name, u.last_name, COALESCE(u.nickname, ''), COALESCE(u.avatar, ''),
+		       m.expires_at, m.created_at
+		FROM mutes m
+		JOIN users u ON u.id = m.muted_id
+		WHERE m.muter_id = ? AND `+activeMute+`
+		ORDER BY m.created_at DESC
+	`, muterID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []models.MutedUser{}
+	for rows.Next() {
+		var u models.MutedUser
+		var expiresAt sql.NullTime
+		if err := rows.Scan(&u.ID, &u.FirstName, &u.LastName, &u.Nickname, &u.Avatar, &expiresAt, &u.MutedAt); err != nil {
+			return nil, err
+		}
+		if expiresAt.Valid {
+			u.ExpiresAt = &expiresAt.Time
+		}
+		users = append(users, u)
+	}
+	return users, rows.Err()
+}
+
+// AddMutedKeyword masque un mot ou une expression ; un mot déjà masqué voit son expiration mise à jour
+func (r *MuteRepository) AddMutedKeyword(userID int, keyword string, expiresAt *time.Time) (models.MutedKeyword, error) {
+	var k models.MutedKeyword
+	var expires sql.NullTime
+	err := r.DB.QueryRow(`
+		INSERT INTO muted_keywords (user_id, keyword, expires_at) VALUES (?, ?, ?)
+		ON CONFLICT (user_id, keyword) DO UPDATE SET expires_at = excluded.expires_at
+		RETURNING id, keyword, expires_at, created_at
+	`, userID, keyword, nullableTime(expiresAt)).Scan(&k.ID, &k.Keyword, &expires, &k.CreatedAt)
+	if expires.Valid {
+		k.ExpiresAt = &expires.Time
+	}
+	return k, err
+}
+
+func (r *MuteRepository) DeleteMutedKeyword(userID, keywordID int) error {
+	_, err := r.DB.Exec(`DELETE FROM muted_keywords WHERE id = ? AND user_id = ?`, keywordID, userID)
+	return err
+}
+
+func (r *MuteRepository) GetMutedKeywords(userID int) ([]models.MutedKeyword, error) {
+	rows, err := r.DB.Query(`
+		SELECT k.id, k.keyword, k.expires_at, k.created_at
+		FROM muted_keywords k
+		WHERE k.user_id = ? AND `+activeKeyword+`
+		ORDER BY k.created_at DESC
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keywords := []models.MutedKeyword{}
+	for rows.Next() {
+		var k models.MutedKeyword
+		var expiresAt sql.NullTime
+		if err := rows.Scan(&k.ID, &k.Keyword, &expiresAt, &k.CreatedAt); err != nil {
+			return nil, err
+		}
+		if expiresAt.Valid {
+			k.ExpiresAt = &expiresAt.Time
+		}
+		keywords = append(keywords, k)
+	}
+	return keywords, rows.Err()
+}
+
+// DeleteExpired supprime les masquages arrivés à expiration
+func (r *MuteRepository) DeleteExpired() error {
+	if _, err := r.DB.Exec(`DELETE FROM mutes WHERE expires_at IS NOT NULL AND expires_at <= CURRENT_TIMESTAMP`); err != nil {
+		return err
+	}
+	_, err := r.DB.Exec(`DELETE FROM muted_keywords WHERE expires_at IS NOT NULL AND expires_at <= CURRENT_TIMESTAMP`)
+	return err
+}
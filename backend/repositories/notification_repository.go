@@ -28,8 +28,8 @@ func (repo *NotificationRepository) GetNotificationsByUserID(userID int) ([]mode
             n.created_at
         FROM notifications n
         LEFT JOIN users u ON n.sender_id = u.id
-        WHERE n.user_id = ?
-        ORDER BY n.created_at DESC`, userID)
+        WHERE n.user_id = ? AND `+notMutedUserSQL("n.sender_id")+`
+        ORDER BY n.created_at DESC`, userID, userID)
     if err != nil {
         return nil, err
     }
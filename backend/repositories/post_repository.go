@@ -21,7 +21,7 @@ func (r *PostRepository) IsUserFollowing(authorID, followerID int) (bool, error)
     err := r.DB.QueryRow(`
         SELECT EXISTS(
             SELECT 1 FROM followers 
-            WHERE followed_id = ? AND follower_id = ?
+            WHERE followed_id = ? AND follower_id = ? AND status = 'accepted'
         )`, authorID, followerID).Scan(&exists)
     if err != nil {
         return false, err
@@ -194,6 +194,11 @@ func (r *PostRepository) CreatePost(post models.PostFetch, recipients []int) err
 }
 
 func (r *PostRepository) GetPostsForUser(userID int) ([]models.PostFetch, error) {
+    muted, err := mutedKeywordsFor(r.DB, userID)
+    if err != nil {
+        return nil, err
+    }
+
     rows, err := r.DB.Query(`
         SELECT 
             p.id, 
@@ -206,17 +211,10 @@ func (r *PostRepository) GetPostsForUser(userID int) ([]models.PostFetch, error)
             CONCAT(u.first_name, ' ', u.last_name) as author_name
         FROM posts p
         JOIN users u ON p.author_id = u.id
-        WHERE p.privacy = 'public' OR p.author_id = ? OR p.id IN (
-            SELECT post_id FROM post_permissions WHERE user_id = ?
-        )
-            OR (
-                p.privacy = 'followers' 
-                AND p.author_id IN (
-                    SELECT followed_id FROM followers WHERE follower_id = ?
-                )
-            )
+        WHERE `+postVisibleCondition+`
+        AND `+notMutedSQL("p.author_id")+`
         ORDER BY p.created_at DESC
-    `, userID, userID, userID)
+    `, userID, userID, userID, userID, userID, userID, userID, userID)
 
     if err != nil {
         return nil, err
@@ -240,20 +238,24 @@ func (r *PostRepository) GetPostsForUser(userID int) ([]models.PostFetch, error)
             log.Println("❌ scan error:", err)
             continue
         }
+        if muted.hides(post.AuthorID, post.Content) {
+            continue
+        }
         posts = append(posts, post)
     }
     return posts, nil
 }
 
-func (r *PostRepository) GetCommentsByPost(postID string) ([]models.CommentWithUser, error) {
+// GetCommentsByPost retourne les commentaires d'un post, sans ceux des utilisateurs bloqués par (ou bloquant) viewerID
+func (r *PostRepository) GetCommentsByPost(postID string, viewerID int) ([]models.CommentWithUser, error) {
 	rows, err := r.DB.Query(`
 		SELECT c.id, c.content, c.image, c.created_at,
 		       u.first_name, u.last_name, u.avatar
 		FROM comments c
 		JOIN users u ON c.user_id = u.id
-		WHERE c.post_id = ?
+		WHERE c.post_id = ? AND `+notBlockedSQL("c.user_id")+`
 		ORDER BY c.created_at ASC
-	`, postID)
+	`, postID, viewerID, viewerID)
 	if err != nil {
 		return nil, err
 	}
@@ -271,6 +273,12 @@ func (r *PostRepository) GetCommentsByPost(postID string) ([]models.CommentWithU
 	return comments, nil
 }
 
+func (r *PostRepository) GetPostAuthorID(postID string) (int, error) {
+	var authorID int
+	err := r.DB.QueryRow(`SELECT author_id FROM posts WHERE id = ?`, postID).Scan(&authorID)
+	return authorID, err
+}
+
 func (r *PostRepository) InsertComment(postID string, userID int, content, image, createdAt string) error {
 	_, err := r.DB.Exec(`
 		INSERT INTO comments (post_id, user_id, content, image, created_at)
@@ -0,0 +1,98 @@
+package repositories
+
+import (
+	"database/sql"
+	"social/models"
+	"strings"
+	"time"
+)
+
+type PresenceRepository struct {
+	DB *sql.DB
+}
+
+func NewPresenceRepository(db *sql.DB) *PresenceRepository {
+	return &PresenceRepository{DB: db}
+}
+
+// SetLastSeen enregistre l'heure de dernière déconnexion de userID
+func (r *PresenceRepository) SetLastSeen(userID int, at time.Time) error {
+	_, err := r.DB.Exec(`UPDATE users SET last_seen_at = ? WHERE id = ?`, at.UTC().Format(sqliteTimeFormat), userID)
+	return err
+}
+
+// GetLastSeen retourne l'heure de dernière déconnexion de chaque utilisateur de userIDs qui en a une
+func (r *PresenceRepository) GetLastSeen(userIDs []int) (map[int]time.Time, error) {
+	lastSeen := make(map[int]time.Time)
+	if len(userIDs) == 0 {
+		return lastSeen, nil
+	}
+
+	args := make([]interface{}, len(userIDs))
+	for i, id := range userIDs {
+		args[i] = id
+	}
+	in := strings.TrimSuffix(strings.Repeat("?,", len(userIDs)), ",")
+
+	rows, err := r.DB.Query(`
+		SELECT id, last_seen_at FROM users WHERE last_seen_at IS NOT NULL AND id IN (`+in+`)
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int
+		var seen string
+		if err := rows.Scan(&id, &seen); err != nil {
+			return nil, err
+		}
+		if t, err := time.ParseInLocation(sqliteTimeFormat, seen, time.UTC); err == nil {
+			lastSeen[id] = t
+		} else if t, err := time.Parse(time.RFC3339, seen); err == nil {
+			lastSeen[id] = t
+		}
+	}
+	return lastSeen, rows.Err()
+}
+
+// GetWatchers liste qui doit être prévenu de la présence de userID : les membres des groupes
+// dont il fait partie (une ligne par groupe commun) et ses contacts de messagerie privée,
+// sans les utilisateurs bloqués par (ou bloquant) userID
+func (r *PresenceRepository) GetWatchers(userID int) ([]models.PresenceWatcher, error) {
+	rows, err := r.DB.Query(`
+		WITH memberships AS (
+			SELECT group_id, user_id FROM group_memberships WHERE status = 'accepted'
+			UNION
+			SELECT id, creator_id FROM groups WHERE creator_id IS NOT NULL
+		),
+		watchers AS (
+			SELECT other.user_id AS user_id, other.group_id AS group_id
+			FROM memberships mine
+			JOIN memberships other ON other.group_id = mine.group_id AND other.user_id != mine.user_id
+			WHERE mine.user_id = ?
+			UNION
+			SELECT CASE WHEN from_id = ? THEN to_id ELSE from_id END, 0
+			FROM messages
+			WHERE (from_id = ? OR to_id = ?) AND from_id != to_id
+		)
+		SELECT w.user_id, w.group_id FROM watchers w
+		WHERE `+notBlockedSQL("w.user_id")+`
+		ORDER BY w.user_id, w.group_id
+	`, userID, userID, userID, userID, userID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	watchers := []models.PresenceWatcher{}
+	for rows.Next() {
+		var w models.PresenceWatcher
+		if err := rows.Scan(&w.UserID, &w.GroupID); err != nil {
+			return nil, err
+		}
+		watchers = append(watchers, w)
+	}
+	return watchers, rows.Err()
+}
@@ -2,8 +2,10 @@ package repositories
 
 import (
 	"database/sql"
+	"fmt"
 	"social/models"
 	"strings"
+	"time"
 )
 
 type SqliteProfileRepo struct {
@@ -85,13 +87,15 @@ func (r *SqliteProfileRepo) IsPending(followerID, followedID int) (bool, error)
 	return status == "pending", nil
 }
 
-func (ur *SqliteProfileRepo) SearchUsers(query string) ([]models.SearchResult, error) {
+// SearchUsers cherche par nom ou pseudo, en excluant les utilisateurs bloqués par (ou bloquant) requesterID
+func (ur *SqliteProfileRepo) SearchUsers(requesterID int, query string) ([]models.SearchResult, error) {
 	search := "%" + strings.ToLower(query) + "%"
 	rows, err := ur.db.Query(`
 		SELECT id, first_name, last_name, nickname
 		FROM users
-		WHERE LOWER(first_name) LIKE ? OR LOWER(last_name) LIKE ? OR LOWER(nickname) LIKE ?
-	`, search, search, search)
+		WHERE (LOWER(first_name) LIKE ? OR LOWER(last_name) LIKE ? OR LOWER(nickname) LIKE ?)
+		AND `+notBlockedSQL("users.id")+`
+	`, search, search, search, requesterID, requesterID)
 	if err != nil {
 		return nil, err
 	}
@@ -109,7 +113,232 @@ func (ur *SqliteProfileRepo) SearchUsers(query string) ([]models.SearchResult, e
 	return results, nil
 }
 
-func (r *SqliteProfileRepo) TogglePrivacy(userID int, isPrivate bool) error {
-	_, err := r.db.Exec(`UPDATE users SET is_private = ? WHERE id = ?`, isPrivate, userID)
-	return err
-}
\ No newline at end of file
+// TogglePrivacy change la confidentialité du compte et applique ses effets sur les abonnements,
+// avec les notifications correspondantes, dans une seule transaction :
+//   - passage en public : toutes les demandes en attente sont acceptées et leurs auteurs notifiés
+//   - passage en privé avec requireReapproval : les abonnés repassent en attente, sont notifiés,
+//     et une demande follow_request est recréée pour chacun côté propriétaire
+func (r *SqliteProfileRepo) TogglePrivacy(userID int, isPrivate, requireReapproval bool) (models.PrivacyChange, error) {
+	change := models.PrivacyChange{
+		IsPrivate:         isPrivate,
+		AutoAccepted:      []int{},
+		PendingReapproval: []int{},
+		Notifications:     map[int]models.Notification{},
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return change, err
+	}
+	defer tx.Rollback()
+
+	var wasPrivate bool
+	var firstName, lastName string
+	err = tx.QueryRow(`SELECT is_private, first_name, last_name FROM users WHERE id = ?`, userID).
+		Scan(&wasPrivate, &firstName, &lastName)
+	if err != nil {
+		return change, err
+	}
+	ownerName := firstName + " " + lastName
+
+	if _, err := tx.Exec(`UPDATE users SET is_private = ? WHERE id = ?`, isPrivate, userID); err != nil {
+		return change, err
+	}
+
+	switch {
+	case wasPrivate && !isPrivate:
+		change.AutoAccepted, err = followerIDsWithStatus(tx, userID, "pending")
+		if err != nil {
+			return change, err
+		}
+		if _, err := tx.Exec(`
+			UPDATE followers SET status = 'accepted' WHERE followed_id = ? AND status = 'pending'
+		`, userID); err != nil {
+			return change, err
+		}
+		if _, err := tx.Exec(`
+			UPDATE notifications SET seen = 1 WHERE user_id = ? AND type = 'follow_request'
+		`, userID); err != nil {
+			return change, err
+		}
+		message := fmt.Sprintf("%s accepted your follow request", ownerName)
+		for _, followerID := range change.AutoAccepted {
+			n, err := insertNotification(tx, followerID, userID, "follow_accept", message)
+			if err != nil {
+				return change, err
+			}
+			n.SenderNickname = ownerName
+			change.Notifications[followerID] = n
+		}
+
+	case !wasPrivate && isPrivate && requireReapproval:
+		change.PendingReapproval, err = followerIDsWithStatus(tx, userID, "accepted")
+		if err != nil {
+			return change, err
+		}
+		if _, err := tx.Exec(`
+			UPDATE followers SET status = 'pending' WHERE followed_id = ? AND status = 'accepted'
+		`, userID); err != nil {
+			return change, err
+		}
+		message := fmt.Sprintf("%s made their account private, your follow request needs to be approved again", ownerName)
+		for _, followerID := range change.PendingReapproval {
+			var followerFirst, followerLast string
+			if err := tx.QueryRow(`SELECT first_name, last_name FROM users WHERE id = ?`, followerID).
+				Scan(&followerFirst, &followerLast); err != nil {
+				return change, err
+			}
+			followerName := followerFirst + " " + followerLast
+			if _, err := insertNotification(tx, userID, followerID, "follow_request", followerName+" sent you a follow request"); err != nil {
+				return change, err
+			}
+
+			n, err := insertNotification(tx, followerID, userID, "follow_reapproval", message)
+			if err != nil {
+				return change, err
+			}
+			n.SenderNickname = ownerName
+			change.Notifications[followerID] = n
+		}
+	}
+
+	return change, tx.Commit()
+}
+
+func followerIDsWithStatus(tx *sql.Tx, userID int, status string) ([]int, error) {
+	rows, err := tx.Query(`
+		SELECT follower_id FROM followers WHERE followed_id = ? AND status = ? ORDER BY follower_id
+	`, userID, status)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []int{}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// insertNotification enregistre une notification dans tx et la retourne prête à être poussée
+func insertNotification(tx *sql.Tx, userID, senderID int, notifType, message string) (models.Notification, error) {
+	res, err := tx.Exec(`
+		INSERT INTO notifications (user_id, sender_id, type, message, created_at)
+		VALUES (?, ?, ?, ?, CURRENT_TIMESTAMP)
+	`, userID, senderID, notifType, message)
+	if err != nil {
+		return models.Notification{}, err
+	}
+	id, _ := res.LastInsertId()
+	return models.Notification{
+		ID:        int(id),
+		SenderID:  senderID,
+		Type:      notifType,
+		Message:   message,
+		CreatedAt: time.Now().Format(time.RFC3339),
+	}, nil
+}
+
+// CountFollows retourne le nombre d'abonnés et d'abonnements acceptés de userID
+func (r *SqliteProfileRepo) CountFollows(userID int) (followers, following int, err error) {
+	err = r.db.QueryRow(`
+		SELECT
+			(SELECT COUNT(*) FROM followers WHERE followed_id = ? AND status = 'accepted'),
+			(SELECT COUNT(*) FROM followers WHERE follower_id = ? AND status = 'accepted')
+	`, userID, userID).Scan(&followers, &following)
+	return followers, following, err
+}
+
+// mutualsFromSQL sélectionne les personnes que viewerID (1er paramètre) suit et qui suivent userID (2e),
+// sans userID lui-même ni les utilisateurs bloqués par (ou bloquant) viewerID (3e et 4e)
+const mutualsFromSQL = `
+	FROM followers mine
+	JOIN followers theirs ON theirs.follower_id = mine.followed_id AND theirs.status = 'accepted'
+	JOIN users u ON u.id = mine.followed_id
+	WHERE mine.follower_id = ? AND mine.status = 'accepted'
+	  AND theirs.followed_id = ?
+	  AND u.id != theirs.followed_id
+`
+
+// GetMutuals liste les abonnements de viewerID qui suivent aussi userID
+func (r *SqliteProfileRepo) GetMutuals(viewerID, userID int) ([]models.UserSummary, error) {
+	rows, err := r.db.Query(`
+		SELECT u.id, u.first_name, u.last_name, COALESCE(u.nickname, ''), COALESCE(u.avatar, '')
+		`+mutualsFromSQL+`
+		  AND `+notBlockedSQL("u.id")+`
+		ORDER BY u.first_name, u.last_name, u.id
+	`, viewerID, userID, viewerID, viewerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	mutuals := []models.UserSummary{}
+	for rows.Next() {
+		var u models.UserSummary
+		if err := rows.Scan(&u.ID, &u.FirstName, &u.LastName, &u.Nickname, &u.Avatar); err != nil {
+			return nil, err
+		}
+		mutuals = append(mutuals, u)
+	}
+	return mutuals, rows.Err()
+}
+
+// GetRelationship décrit le lien entre viewerID et userID (abonnements, blocages, groupes communs).
+// La sourdine est renseignée par le service.
+func (r *SqliteProfileRepo) GetRelationship(viewerID, userID int) (models.Relationship, error) {
+	rel := models.Relationship{UserID: userID, SharedGroups: []models.SharedGroup{}}
+
+	err := r.db.QueryRow(`
+		SELECT
+			EXISTS(SELECT 1 FROM followers WHERE follower_id = ? AND followed_id = ? AND status = 'accepted'),
+			EXISTS(SELECT 1 FROM followers WHERE follower_id = ? AND followed_id = ? AND status = 'accepted'),
+			EXISTS(SELECT 1 FROM followers WHERE follower_id = ? AND followed_id = ? AND status = 'pending'),
+			EXISTS(SELECT 1 FROM followers WHERE follower_id = ? AND followed_id = ? AND status = 'pending'),
+			EXISTS(SELECT 1 FROM blocks WHERE blocker_id = ? AND blocked_id = ?),
+			EXISTS(SELECT 1 FROM blocks WHERE blocker_id = ? AND blocked_id = ?),
+			(SELECT COUNT(*) `+mutualsFromSQL+` AND `+notBlockedSQL("u.id")+`)
+	`,
+		viewerID, userID, userID, viewerID,
+		viewerID, userID, userID, viewerID,
+		viewerID, userID, userID, viewerID,
+		viewerID, userID, viewerID, viewerID,
+	).Scan(
+		&rel.Follows, &rel.FollowedBy, &rel.RequestSent, &rel.RequestReceived,
+		&rel.Blocking, &rel.BlockedBy, &rel.MutualCount,
+	)
+	if err != nil {
+		return rel, err
+	}
+
+	rows, err := r.db.Query(`
+		WITH memberships AS (
+			SELECT group_id, user_id FROM group_memberships WHERE status = 'accepted'
+			UNION
+			SELECT id, creator_id FROM groups
+		)
+		SELECT g.id, g.title
+		FROM groups g
+		JOIN memberships a ON a.group_id = g.id AND a.user_id = ?
+		JOIN memberships b ON b.group_id = g.id AND b.user_id = ?
+		ORDER BY g.title, g.id
+	`, viewerID, userID)
+	if err != nil {
+		return rel, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var g models.SharedGroup
+		if err := rows.Scan(&g.ID, &g.Title); err != nil {
+			return rel, err
+		}
+		rel.SharedGroups = append(rel.SharedGroups, g)
+	}
+	return rel, rows.Err()
+}
@@ -0,0 +1,105 @@
+package repositories
+
+import (
+	"database/sql"
+	"social/models"
+)
+
+type SuggestionRepository struct {
+	DB *sql.DB
+}
+
+func NewSuggestionRepository(db *sql.DB) *SuggestionRepository {
+	return &SuggestionRepository{DB: db}
+}
+
+// Poids de chaque signal dans le score d'une suggestion
+const (
+	mutualFollowWeight = 3
+	sharedGroupWeight  = 2
+	sharedEventWeight  = 1
+)
+
+// GetUserSuggestions classe les candidats par amis d'amis (abonnements acceptés),
+// groupes en commun et participation aux mêmes événements. Sont exclus : userID lui-même,
+// les comptes déjà suivis ou demandés, ceux qui attendent une réponse de userID,
+// les blocages dans les deux sens et les suggestions écartées.
+func (r *SuggestionRepository) GetUserSuggestions(userID, limit int) ([]models.UserSuggestion, error) {
+	rows, err := r.DB.Query(`
+		WITH
+		memberships AS (
+			SELECT group_id, user_id FROM group_memberships WHERE status = 'accepted'
+			UNION
+			SELECT id, creator_id FROM groups WHERE creator_id IS NOT NULL
+		),
+		mutual_follows AS (
+			SELECT f2.followed_id AS candidate_id, COUNT(DISTINCT f1.followed_id) AS n
+			FROM followers f1
+			JOIN followers f2 ON f2.follower_id = f1.followed_id AND f2.status = 'accepted'
+			WHERE f1.follower_id = ? AND f1.status = 'accepted'
+			GROUP BY f2.followed_id
+		),
+		shared_groups AS (
+			SELECT other.user_id AS candidate_id, COUNT(DISTINCT other.group_id) AS n
+			FROM memberships mine
+			JOIN memberships other ON other.group_id = mine.group_id
+			WHERE mine.user_id = ?
+			GROUP BY other.user_id
+		),
+		shared_events AS (
+			SELECT other.user_id AS candidate_id, COUNT(DISTINCT other.event_id) AS n
+			FROM event_responses mine
+			JOIN event_responses other ON other.event_id = mine.event_id AND other.response = 'going'
+			WHERE mine.user_id = ? AND mine.response = 'going'
+			GROUP BY other.user_id
+		),
+		signals AS (
+			SELECT candidate_id, n AS mutual, 0 AS grp, 0 AS evt FROM mutual_follows
+			UNION ALL SELECT candidate_id, 0, n, 0 FROM shared_groups
+			UNION ALL SELECT candidate_id, 0, 0, n FROM shared_events
+		)
+		SELECT u.id, u.first_name, u.last_name, COALESCE(u.nickname, ''), COALESCE(u.avatar, ''), u.is_private,
+		       SUM(s.mutual), SUM(s.grp), SUM(s.evt),
+		       SUM(s.mutual) * ? + SUM(s.grp) * ? + SUM(s.evt) * ? AS score
+		FROM signals s
+		JOIN users u ON u.id = s.candidate_id
+		WHERE u.id != ?
+		  AND NOT EXISTS (SELECT 1 FROM followers f WHERE f.follower_id = ? AND f.followed_id = u.id)
+		  AND NOT EXISTS (SELECT 1 FROM followers f WHERE f.follower_id = u.id AND f.followed_id = ? AND f.status = 'pending')
+		  AND NOT EXISTS (SELECT 1 FROM suggestion_dismissals d WHERE d.user_id = ? AND d.dismissed_id = u.id)
+		  AND `+notBlockedSQL("u.id")+`
+		GROUP BY u.id
+		ORDER BY score DESC, u.id ASC
+		LIMIT ?
+	`,
+		userID, userID, userID,
+		mutualFollowWeight, sharedGroupWeight, sharedEventWeight,
+		userID, userID, userID, userID, userID, userID,
+		limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	suggestions := []models.UserSuggestion{}
+	for rows.Next() {
+		var s models.UserSuggestion
+		if err := rows.Scan(
+			&s.ID, &s.FirstName, &s.LastName, &s.Nickname, &s.Avatar, &s.IsPrivate,
+			&s.MutualFollows, &s.SharedGroups, &s.SharedEvents, &s.Score,
+		); err != nil {
+			return nil, err
+		}
+		suggestions = append(suggestions, s)
+	}
+	return suggestions, rows.Err()
+}
+
+// DismissSuggestion écarte définitivement dismissedID des suggestions de userID
+func (r *SuggestionRepository) DismissSuggestion(userID, dismissedID int) error {
+	_, err := r.DB.Exec(`
+		INSERT OR IGNORE INTO suggestion_dismissals (user_id, dismissed_id) VALUES (?, ?)
+	`, userID, dismissedID)
+	return err
+}
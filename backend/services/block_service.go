@@ -0,0 +1,67 @@
+package services
+
+import (
+	"errors"
+	"social/models"
+	"social/repositories"
+	"social/storage"
+)
+
+var (
+	ErrUserBlocked  = errors.New("user is blocked")
+	ErrCannotBlock  = errors.New("cannot block yourself")
+	ErrUserNotFound = errors.New("user not found")
+)
+
+type BlockService struct {
+	Repo *repositories.BlockRepository
+}
+
+func NewBlockService(repo *repositories.BlockRepository) *BlockService {
+	return &BlockService{Repo: repo}
+}
+
+// BlockUser bloque blockedID pour blockerID ; les abonnements entre eux sont supprimés
+func (s *BlockService) BlockUser(blockerID, blockedID int) error {
+	if blockerID == blockedID {
+		return ErrCannotBlock
+	}
+	exists, err := s.Repo.UserExists(blockedID)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return ErrUserNotFound
+	}
+	return s.Repo.BlockUser(blockerID, blockedID)
+}
+
+func (s *BlockService) UnblockUser(blockerID, blockedID int) error {
+	return s.Repo.UnblockUser(blockerID, blockedID)
+}
+
+func (s *BlockService) GetBlockedUsers(blockerID int) ([]models.BlockedUser, error) {
+	users, err := s.Repo.GetBlockedUsers(blockerID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range users {
+		users[i].Avatar = storage.MediaURL(users[i].Avatar)
+	}
+	return users, nil
+}
+
+// checkNotBlocked retourne ErrUserBlocked si l'un des deux utilisateurs a bloqué l'autre
+func checkNotBlocked(repo *repositories.BlockRepository, userID1, userID2 int) error {
+	if repo == nil || userID1 == userID2 {
+		return nil
+	}
+	blocked, err := repo.IsBlockedBetween(userID1, userID2)
+	if err != nil {
+		return err
+	}
+	if blocked {
+		return ErrUserBlocked
+	}
+	return nil
+}
@@ -0,0 +1,373 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"social/models"
+	"social/repositories"
+)
+
+var (
+	ErrRoomNotFound      = errors.New("chat room not found")
+	ErrNotRoomAdmin      = errors.New("only room admins can do this")
+	ErrNotRoomCreator    = errors.New("only the room creator can change roles")
+	ErrRoomTooSmall      = errors.New("a chat room needs at least 2 other participants")
+	ErrRoomFull          = errors.New("too many participants in this chat room")
+	ErrInvalidRoomTitle  = errors.New("title must be between 1 and 100 characters")
+	ErrInvalidRoomRole   = errors.New("role must be admin or member")
+	ErrCannotRemoveAdmin = errors.New("only the room creator can remove an admin")
+	// ErrChatNotAllowed : les règles de CanUsersChat interdisent la discussion avec l'un des utilisateurs
+	ErrChatNotAllowed = errors.New("chat not allowed with this user")
+)
+
+const (
+	maxRoomParticipants = 50 // créateur compris
+	maxRoomTitleLength  = 100
+)
+
+// ChatRoomService gère les discussions à plusieurs. Chaque participant doit pouvoir écrire en privé à chacun
+// des autres sans demande de message (canTalkDirectly), et aucun n'a de blocage avec un autre.
+type ChatRoomService struct {
+	Repo        *repositories.ChatRoomRepository
+	ChatRepo    *repositories.ChatRepository
+	RequestRepo *repositories.MessageRequestRepository
+	BlockRepo   *repositories.BlockRepository
+}
+
+func NewChatRoomService(repo *repositories.ChatRoomRepository, chatRepo *repositories.ChatRepository, requestRepo *repositories.MessageRequestRepository, blockRepo *repositories.BlockRepository) *ChatRoomService {
+	return &ChatRoomService{Repo: repo, ChatRepo: chatRepo, RequestRepo: requestRepo, BlockRepo: blockRepo}
+}
+
+// CreateRoom crée une discussion entre creatorID et au moins deux autres utilisateurs.
+// Retourne l'événement room_created à pousser à ses participants.
+func (s *ChatRoomService) CreateRoom(creatorID int, req models.CreateRoomRequest) (models.RoomEvent, error) {
+	title, err := roomTitle(req.Title)
+	if err != nil {
+		return models.RoomEvent{}, err
+	}
+
+	participantIDs := uniqueIDs(req.ParticipantIDs, creatorID)
+	if len(participantIDs) < 2 {
+		return models.RoomEvent{}, ErrRoomTooSmall
+	}
+	if len(participantIDs)+1 > maxRoomParticipants {
+		return models.RoomEvent{}, ErrRoomFull
+	}
+	if err := s.checkCanJoin([]int{creatorID}, participantIDs); err != nil {
+		return models.RoomEvent{}, err
+	}
+
+	roomID, err := s.Repo.CreateRoom(title, creatorID, participantIDs)
+	if err != nil {
+		return models.RoomEvent{}, err
+	}
+	return s.roomEvent(models.RoomCreated, roomID, creatorID, participantIDs, "")
+}
+
+// GetRoom retourne la discussion si userID y participe
+func (s *ChatRoomService) GetRoom(roomID, userID int) (models.ChatRoom, error) {
+	if _, err := s.participantRole(roomID, userID); err != nil {
+		return models.ChatRoom{}, err
+	}
+	room, err := s.Repo.GetRoom(roomID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return room, ErrRoomNotFound
+	}
+	return room, err
+}
+
+// GetRooms retourne les discussions de userID, de la plus récemment active à la plus ancienne
+func (s *ChatRoomService) GetRooms(userID int) ([]models.ChatRoom, error) {
+	rooms, err := s.Repo.GetRoomsForUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range rooms {
+		if rooms[i].LastMessage != nil {
+			rooms[i].LastMessage.Content = previewText(rooms[i].LastMessage.Content)
+		}
+	}
+	if rooms == nil {
+		rooms = []models.ChatRoom{}
+	}
+	return rooms, nil
+}
+
+// GetHistory retourne une page des messages que userID peut voir (ceux envoyés depuis son arrivée)
+func (s *ChatRoomService) GetHistory(roomID, userID, before, limit int) ([]models.Message, error) {
+	if _, err := s.participantRole(roomID, userID); err != nil {
+		return nil, err
+	}
+	if before < 0 {
+		before = 0
+	}
+	if limit <= 0 || limit > maxHistoryPage {
+		limit = defaultHistoryPage
+	}
+	return s.Repo.GetRoomHistory(roomID, userID, before, limit)
+}
+
+// ProcessRoomMessage vérifie et enregistre un message de msg.From dans la discussion msg.RoomID ;
+// msg.ID reçoit l'ID enregistré. Retourne les participants à qui le livrer.
+func (s *ChatRoomService) ProcessRoomMessage(msg *models.Message) ([]int, error) {
+	if _, err := s.participantRole(msg.RoomID, msg.From); err != nil {
+		return nil, err
+	}
+	msg.Content = strings.TrimSpace(msg.Content)
+	if msg.Content == "" {
+		return nil, ErrEmptyMessage
+	}
+	if len(msg.ClientID) > maxClientIDLength {
+		return nil, ErrInvalidClientID
+	}
+	if msg.ReplyTo != 0 {
+		visible, err := s.Repo.IsVisibleMessage(msg.RoomID, msg.From, msg.ReplyTo)
+		if err != nil {
+			return nil, err
+		}
+		if !visible {
+			return nil, ErrReplyNotFound
+		}
+	}
+
+	id, duplicate, err := s.Repo.SaveRoomMessage(*msg)
+	if err != nil {
+		return nil, err
+	}
+	msg.ID = id
+	msg.Type = "room_message"
+	if duplicate {
+		return nil, ErrDuplicateMessage
+	}
+	return s.Repo.GetParticipantIDs(msg.RoomID)
+}
+
+// Rename change le titre de la discussion (admins)
+func (s *ChatRoomService) Rename(roomID, actorID int, title string) (models.RoomEvent, error) {
+	title, err := roomTitle(title)
+	if err != nil {
+		return models.RoomEvent{}, err
+	}
+	if err := s.requireAdmin(roomID, actorID); err != nil {
+		return models.RoomEvent{}, err
+	}
+	if err := s.Repo.RenameRoom(roomID, title); err != nil {
+		return models.RoomEvent{}, err
+	}
+	return s.roomEvent(models.RoomRenamed, roomID, actorID, nil, "")
+}
+
+// AddParticipants ajoute des utilisateurs à la discussion (admins). Retourne l'événement
+// room_participants_added, sans Room si aucun n'était nouveau.
+func (s *ChatRoomService) AddParticipants(roomID, actorID int, userIDs []int) (models.RoomEvent, error) {
+	if err := s.requireAdmin(roomID, actorID); err != nil {
+		return models.RoomEvent{}, err
+	}
+
+	current, err := s.Repo.GetParticipantIDs(roomID)
+	if err != nil {
+		return models.RoomEvent{}, err
+	}
+	var newIDs []int
+	for _, id := range uniqueIDs(userIDs, actorID) {
+		if !containsID(current, id) {
+			newIDs = append(newIDs, id)
+		}
+	}
+	if len(newIDs) == 0 {
+		return models.RoomEvent{Type: models.RoomParticipantsAdded, RoomID: roomID}, nil
+	}
+	if len(current)+len(newIDs) > maxRoomParticipants {
+		return models.RoomEvent{}, ErrRoomFull
+	}
+	if err := s.checkCanJoin(current, newIDs); err != nil {
+		return models.RoomEvent{}, err
+	}
+
+	added, err := s.Repo.AddParticipants(roomID, newIDs)
+	if err != nil {
+		return models.RoomEvent{}, err
+	}
+	return s.roomEvent(models.RoomParticipantsAdded, roomID, actorID, added, "")
+}
+
+// RemoveParticipant retire userID de la discussion (admins ; seul le créateur retire un admin).
+// Retourne l'événement room_participant_removed (UserIDs : le participant retiré).
+func (s *ChatRoomService) RemoveParticipant(roomID, actorID, userID int) (models.RoomEvent, error) {
+	if actorID == userID {
+		return s.Leave(roomID, userID)
+	}
+	if err := s.requireAdmin(roomID, actorID); err != nil {
+		return models.RoomEvent{}, err
+	}
+	role, err := s.participantRole(roomID, userID)
+	if errors.Is(err, ErrRoomNotFound) {
+		return models.RoomEvent{}, ErrUserNotFound
+	}
+	if err != nil {
+		return models.RoomEvent{}, err
+	}
+	if role == models.RoomRoleAdmin {
+		room, err := s.Repo.GetRoom(roomID)
+		if err != nil {
+			return models.RoomEvent{}, err
+		}
+		if room.CreatorID != actorID {
+			return models.RoomEvent{}, ErrCannotRemoveAdmin
+		}
+	}
+
+	if _, err := s.Repo.RemoveParticipant(roomID, userID); err != nil {
+		return models.RoomEvent{}, err
+	}
+	return s.roomEvent(models.RoomParticipantRemoved, roomID, actorID, []int{userID}, "")
+}
+
+// Leave fait quitter la discussion à userID ; si c'était le créateur, un autre participant le remplace
+func (s *ChatRoomService) Leave(roomID, userID int) (models.RoomEvent, error) {
+	if _, err := s.participantRole(roomID, userID); err != nil {
+		return models.RoomEvent{}, err
+	}
+	if _, err := s.Repo.RemoveParticipant(roomID, userID); err != nil {
+		return models.RoomEvent{}, err
+	}
+	return s.roomEvent(models.RoomParticipantLeft, roomID, userID, []int{userID}, "")
+}
+
+// SetRole nomme admin ou rétrograde un participant (créateur uniquement)
+func (s *ChatRoomService) SetRole(roomID, actorID, userID int, role string) (models.RoomEvent, error) {
+	if role != models.RoomRoleAdmin && role != models.RoomRoleMember {
+		return models.RoomEvent{}, ErrInvalidRoomRole
+	}
+	room, err := s.GetRoom(roomID, actorID)
+	if err != nil {
+		return models.RoomEvent{}, err
+	}
+	if room.CreatorID != actorID {
+		return models.RoomEvent{}, ErrNotRoomCreator
+	}
+	if userID == actorID {
+		return models.RoomEvent{}, ErrInvalidRoomRole
+	}
+	if _, err := s.participantRole(roomID, userID); err != nil {
+		if errors.Is(err, ErrRoomNotFound) {
+			return models.RoomEvent{}, ErrUserNotFound
+		}
+		return models.RoomEvent{}, err
+	}
+
+	changed, err := s.Repo.SetRole(roomID, userID, role)
+	if err != nil || !changed {
+		return models.RoomEvent{Type: models.RoomRoleChanged, RoomID: roomID}, err
+	}
+	return s.roomEvent(models.RoomRoleChanged, roomID, actorID, []int{userID}, role)
+}
+
+// checkCanJoin vérifie que chacun des nouveaux participants et chaque participant actuel (celui qui les
+// ajoute compris) ou autre nouveau n'ont pas de blocage et peuvent s'écrire directement, dans les deux sens
+func (s *ChatRoomService) checkCanJoin(current, newIDs []int) error {
+	for i, id := range newIDs {
+		others := append(append([]int{}, current...), newIDs[:i]...)
+		for _, other := range others {
+			if err := checkNotBlocked(s.BlockRepo, id, other); err != nil {
+				return err
+			}
+			for _, pair := range [][2]int{{id, other}, {other, id}} {
+				ok, err := s.canTalkDirectly(pair[0], pair[1])
+				if err != nil {
+					return err
+				}
+				if !ok {
+					return ErrChatNotAllowed
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// canTalkDirectly applique les règles des messages privés : senderID écrit à recipientID sans demande de
+// message si l'un a accepté une demande de l'autre, ou si leurs abonnements permettent la discussion
+// (CanUsersChat) et que le réglage dm_policy de recipientID l'accepte
+func (s *ChatRoomService) canTalkDirectly(senderID, recipientID int) (bool, error) {
+	accepted, err := s.RequestRepo.IsAccepted(senderID, recipientID)
+	if err != nil || accepted {
+		return accepted, err
+	}
+	allowed, err := s.RequestRepo.AllowsMessagesFrom(recipientID, senderID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, ErrUserNotFound
+	}
+	if err != nil || !allowed {
+		return false, err
+	}
+	return s.ChatRepo.CanUsersChat(senderID, recipientID)
+}
+
+// participantRole retourne le rôle de userID ; ErrRoomNotFound s'il ne participe pas à la discussion
+func (s *ChatRoomService) participantRole(roomID, userID int) (string, error) {
+	role, err := s.Repo.GetParticipantRole(roomID, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", ErrRoomNotFound
+	}
+	return role, err
+}
+
+func (s *ChatRoomService) requireAdmin(roomID, userID int) error {
+	role, err := s.participantRole(roomID, userID)
+	if err != nil {
+		return err
+	}
+	if role != models.RoomRoleAdmin {
+		return ErrNotRoomAdmin
+	}
+	return nil
+}
+
+// roomEvent construit l'événement avec l'état de la discussion après le changement
+func (s *ChatRoomService) roomEvent(eventType string, roomID, actorID int, userIDs []int, role string) (models.RoomEvent, error) {
+	room, err := s.Repo.GetRoom(roomID)
+	if err != nil {
+		return models.RoomEvent{}, err
+	}
+	return models.RoomEvent{
+		Type:      eventType,
+		RoomID:    roomID,
+		ActorID:   actorID,
+		UserIDs:   userIDs,
+		Role:      role,
+		Room:      &room,
+		Timestamp: time.Now().Format(time.RFC3339),
+	}, nil
+}
+
+func roomTitle(title string) (string, error) {
+	title = strings.TrimSpace(title)
+	if title == "" || len([]rune(title)) > maxRoomTitleLength {
+		return "", ErrInvalidRoomTitle
+	}
+	return title, nil
+}
+
+// uniqueIDs retire les doublons, les IDs invalides et exclude
+func uniqueIDs(ids []int, exclude int) []int {
+	var result []int
+	for _, id := range ids {
+		if id > 0 && id != exclude && !containsID(result, id) {
+			result = append(result, id)
+		}
+	}
+	return result
+}
+
+func containsID(ids []int, id int) bool {
+	for _, v := range ids {
+		if v == id {
+			return true
+		}
+	}
+	return false
+}
@@ -1,18 +1,75 @@
 package services
 
 import (
+	"database/sql"
 	"errors"
+	"os"
+	"path/filepath"
 	"social/models"
 	"social/repositories"
+	"social/storage"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+var (
+	ErrMessageNotFound   = errors.New("message not found")
+	ErrNotMessageSender  = errors.New("only the sender can change this message")
+	ErrEditWindowExpired = errors.New("message can no longer be changed")
+
+	ErrAttachmentNotFound = errors.New("attachment not found or already sent")
+	ErrTooManyAttachments = errors.New("too many attachments")
+
+	// ErrDuplicateMessage : le client a renvoyé un client_id déjà enregistré ; msg.ID est celui du message existant
+	ErrDuplicateMessage = errors.New("message already sent")
+	ErrInvalidClientID  = errors.New("client_id must be at most 64 characters")
+
+	ErrReplyNotFound    = errors.New("replied message not found in this conversation")
+	ErrInvalidEmoji     = errors.New("reaction must be a single emoji")
+	ErrTooManyReactions = errors.New("too many reactions on this message")
+)
+
+const (
+	// maxAttachmentsPerMessage limite le nombre de pièces jointes d'un message
+	maxAttachmentsPerMessage = 10
+	maxClientIDLength        = 64
+	// maxReactionsPerUser limite le nombre d'emojis différents d'un utilisateur sur un même message
+	maxReactionsPerUser = 10
+	maxEmojiLength      = 32 // en octets : les séquences (drapeaux, familles, tons de peau) sont longues
+
+	defaultHistoryPage      = 50
+	maxHistoryPage          = 100
+	defaultConversationPage = 20
+	maxConversationPage     = 50
+	previewLength           = 100
 )
 
 type ChatService struct {
-	Repo *repositories.ChatRepository
+	Repo        *repositories.ChatRepository
+	RequestRepo *repositories.MessageRequestRepository
+	BlockRepo   *repositories.BlockRepository
+	// EditWindow est le délai pendant lequel l'expéditeur peut modifier ou supprimer un message (0 = sans limite)
+	EditWindow time.Duration
+	// AttachmentMaxSize est la taille maximale (en octets) d'une pièce jointe audio ou fichier
+	AttachmentMaxSize int64
 }
 
-// NewChatService creates a new ChatService with the given repository
-func NewChatService(repo *repositories.ChatRepository) *ChatService {
-	return &ChatService{Repo: repo}
+// NewChatService creates a new ChatService with the given repositories.
+// The edit window comes from MESSAGE_EDIT_WINDOW (15m by default, 0 = unlimited) and the
+// attachment size limit from CHAT_ATTACHMENT_MAX_MB (25 by default).
+func NewChatService(repo *repositories.ChatRepository, requestRepo *repositories.MessageRequestRepository, blockRepo *repositories.BlockRepository) *ChatService {
+	window, err := time.ParseDuration(os.Getenv("MESSAGE_EDIT_WINDOW"))
+	if err != nil || window < 0 {
+		window = 15 * time.Minute
+	}
+	maxMB, err := strconv.ParseInt(os.Getenv("CHAT_ATTACHMENT_MAX_MB"), 10, 64)
+	if err != nil || maxMB <= 0 {
+		maxMB = 25
+	}
+	return &ChatService{Repo: repo, RequestRepo: requestRepo, BlockRepo: blockRepo, EditWindow: window, AttachmentMaxSize: maxMB << 20}
 }
 
 func (s *ChatService) GetAllChatUsers(requesterID int) ([]models.ChatUser, error) {
@@ -21,19 +78,15 @@ func (s *ChatService) GetAllChatUsers(requesterID int) ([]models.ChatUser, error
 		return nil, err
 	}
 
-	// Enrich users with CanChat flag
-	for i, user := range users {
-		if user.ID == requesterID {
-			users[i].CanChat = false
-			continue
-		}
+	unread, err := s.Repo.GetUnreadCounts(requesterID)
+	if err != nil {
+		return nil, err
+	}
 
-		canChat, err := s.Repo.CanUsersChat(requesterID, user.ID)
-		if err != nil {
-			users[i].CanChat = false
-		} else {
-			users[i].CanChat = canChat
-		}
+	// Enrich users with unread counters
+	for i, user := range users {
+		users[i].UnreadCount = unread[user.ID]
+		users[i].Avatar = storage.MediaURL(user.Avatar)
 	}
 
 	return users, nil
@@ -41,33 +94,482 @@ func (s *ChatService) GetAllChatUsers(requesterID int) ([]models.ChatUser, error
 func (s *ChatService) CanChat(userID, otherID int) (bool, error){
 	return s.Repo.CanUsersChat(userID, otherID)
 }
-func (s *ChatService) GetChatHistory(userID, otherID int) ([]models.Message, error) {
+// GetChatHistory retourne une page de l'historique (pagination par ID : before = plus ancien message déjà chargé).
+// Une demande de message, même en attente, donne accès à l'historique aux deux utilisateurs.
+func (s *ChatService) GetChatHistory(userID, otherID, before, limit int) ([]models.Message, error) {
 	canChat, err := s.Repo.CanUsersChat(userID, otherID)
 	if err != nil {
 		return nil, err
 	}
+	if !canChat {
+		canChat, err = s.RequestRepo.HasRequestBetween(userID, otherID)
+		if err != nil {
+			return nil, err
+		}
+	}
 	if !canChat {
 		return nil, errors.New("chat not allowed: users must follow each other")
 	}
-	return s.Repo.GetChatHistory(userID, otherID)
+	if before < 0 {
+		before = 0
+	}
+	if limit <= 0 || limit > maxHistoryPage {
+		limit = defaultHistoryPage
+	}
+	return s.Repo.GetChatHistory(userID, otherID, before, limit)
 }
 
-func (s *ChatService) ProcessPrivateMessage(msg models.Message) error {
+// GetConversations retourne une page des discussions privées de userID, de la plus récemment active
+// à la plus ancienne, avec un aperçu du dernier message et le nombre de messages non lus
+func (s *ChatService) GetConversations(userID, before, limit int) (models.ConversationPage, error) {
+	if before < 0 {
+		before = 0
+	}
+	if limit <= 0 || limit > maxConversationPage {
+		limit = defaultConversationPage
+	}
+
+	// Une conversation de plus pour savoir s'il reste une page
+	conversations, err := s.Repo.GetConversations(userID, before, limit+1)
+	if err != nil {
+		return models.ConversationPage{}, err
+	}
+
+	page := models.ConversationPage{Conversations: []models.Conversation{}, Limit: limit}
+	if len(conversations) > limit {
+		conversations = conversations[:limit]
+		page.NextBefore = conversations[limit-1].LastMessage.ID
+	}
+	for i := range conversations {
+		conversations[i].Avatar = storage.MediaURL(conversations[i].Avatar)
+		conversations[i].LastMessage.Content = previewText(conversations[i].LastMessage.Content)
+	}
+	if conversations != nil {
+		page.Conversations = conversations
+	}
+	return page, nil
+}
+
+// previewText tronque le contenu d'un message pour la liste des conversations
+func previewText(content string) string {
+	runes := []rune(content)
+	if len(runes) <= previewLength {
+		return content
+	}
+	return strings.TrimSpace(string(runes[:previewLength])) + "…"
+}
+
+// ProcessPrivateMessage vérifie et enregistre le message ; msg.ID reçoit l'ID enregistré.
+// Le premier message à quelqu'un avec qui l'expéditeur n'est pas en relation ouvre une demande de message
+// (msg.Request) ; deliver est false quand le destinataire l'a ignorée et ne doit pas le recevoir en direct.
+func (s *ChatService) ProcessPrivateMessage(msg *models.Message) (deliver bool, err error) {
+	// Never deliver messages between blocked users
+	if err := checkNotBlocked(s.BlockRepo, msg.From, msg.To); err != nil {
+		return false, err
+	}
+
 	// Check access rights
-	hasAccess, err := s.Repo.CheckPrivateProfileAccess(msg.From, msg.To)
-	if err != nil || !hasAccess {
-		return err
+	request, err := s.messageRequestStatus(msg.From, msg.To)
+	if err != nil {
+		return false, err
 	}
-	
+
 	// Save message
-	return s.Repo.SavePrivateMessage(msg)
+	if len(msg.ClientID) > maxClientIDLength {
+		return false, ErrInvalidClientID
+	}
+	if len(msg.AttachmentIDs) > maxAttachmentsPerMessage {
+		return false, ErrTooManyAttachments
+	}
+	if err := s.checkReply(msg); err != nil {
+		return false, err
+	}
+	id, duplicate, err := s.Repo.SavePrivateMessage(*msg, request)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, ErrAttachmentNotFound
+	}
+	if err != nil {
+		return false, err
+	}
+	msg.ID = id
+	if duplicate {
+		return false, ErrDuplicateMessage
+	}
+	msg.Request = request != ""
+	return request != models.MessageRequestIgnored, s.loadAttachments("private", msg)
+}
+
+// messageRequestStatus décide du sort d'un message de senderID à recipientID : "" pour une discussion
+// établie (abonnements ou demande acceptée), sinon le statut de la demande de message qu'il alimente.
+// Répondre à une demande l'accepte. ErrChatNotAllowed si le réglage dm_policy du destinataire refuse l'expéditeur.
+// Rien n'est modifié ici : SavePrivateMessage met les demandes à jour avec le message.
+func (s *ChatService) messageRequestStatus(senderID, recipientID int) (string, error) {
+	if senderID == recipientID {
+		return "", nil
+	}
+	received, err := s.RequestRepo.GetStatus(recipientID, senderID)
+	if err != nil || received != "" {
+		return "", err
+	}
+	accepted, err := s.RequestRepo.IsAccepted(senderID, recipientID)
+	if err != nil || accepted {
+		return "", err
+	}
+
+	allowed, err := s.RequestRepo.AllowsMessagesFrom(recipientID, senderID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", ErrUserNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+	if !allowed {
+		return "", ErrChatNotAllowed
+	}
+
+	status, err := s.RequestRepo.GetStatus(senderID, recipientID)
+	if err != nil || status == models.MessageRequestIgnored {
+		return status, err
+	}
+	canChat, err := s.Repo.CanUsersChat(senderID, recipientID)
+	if err != nil {
+		return "", err
+	}
+	if !canChat {
+		return models.MessageRequestPending, nil
+	}
+	// Devenus relations entre-temps : la demande en attente sera acceptée avec le message
+	return "", nil
 }
 
-func (s *ChatService) ProcessGroupMessage(msg models.Message) error {
+// MarkConversationRead enregistre que readerID a lu les messages de otherID jusqu'à upTo
+// (0 = tout). Retourne le read_receipt à relayer à otherID, et false si rien n'a changé.
+func (s *ChatService) MarkConversationRead(readerID, otherID, upTo int) (models.Message, bool, error) {
+	if err := checkNotBlocked(s.BlockRepo, readerID, otherID); err != nil {
+		return models.Message{}, false, err
+	}
+
+	lastRead, advanced, err := s.Repo.MarkConversationRead(readerID, otherID, upTo)
+	if err != nil || !advanced {
+		return models.Message{}, false, err
+	}
+	// Lire une demande de message pas encore acceptée ne prévient pas l'expéditeur
+	status, err := s.RequestRepo.GetStatus(otherID, readerID)
+	if err != nil || (status != "" && status != models.MessageRequestAccepted) {
+		return models.Message{}, false, err
+	}
+
+	return models.Message{
+		Type:      "read_receipt",
+		From:      readerID,
+		To:        otherID,
+		MessageID: lastRead,
+		Timestamp: time.Now().Format(time.RFC3339),
+	}, true, nil
+}
+
+// ProcessGroupMessage enregistre le message de groupe ; msg.ID reçoit l'ID enregistré
+func (s *ChatService) ProcessGroupMessage(msg *models.Message) error {
 	// Validate group membership would go here
 	
 	// Save message
-	return s.Repo.SaveGroupMessage(msg)
+	if len(msg.ClientID) > maxClientIDLength {
+		return ErrInvalidClientID
+	}
+	if len(msg.AttachmentIDs) > maxAttachmentsPerMessage {
+		return ErrTooManyAttachments
+	}
+	if err := s.checkReply(msg); err != nil {
+		return err
+	}
+	id, duplicate, err := s.Repo.SaveGroupMessage(*msg)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrAttachmentNotFound
+	}
+	if err != nil {
+		return err
+	}
+	msg.ID = id
+	if duplicate {
+		return ErrDuplicateMessage
+	}
+	return s.loadAttachments("group", msg)
+}
+
+// checkReply vérifie que le message auquel msg répond existe, n'est pas supprimé et appartient
+// à la même conversation (les deux mêmes participants, ou le même groupe)
+func (s *ChatService) checkReply(msg *models.Message) error {
+	if msg.ReplyTo == 0 {
+		return nil
+	}
+	if msg.ReplyTo < 0 {
+		return ErrReplyNotFound
+	}
+	ref, err := s.loadMessageRef(msg.ReplyTo, msg.GroupID)
+	if errors.Is(err, ErrMessageNotFound) {
+		return ErrReplyNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if msg.GroupID == 0 && (!isParticipant(ref, msg.From) || !isParticipant(ref, msg.To)) {
+		return ErrReplyNotFound
+	}
+	return nil
+}
+
+// isParticipant indique si userID est l'expéditeur ou le destinataire d'un message privé
+func isParticipant(ref models.MessageRef, userID int) bool {
+	return ref.From == userID || ref.To == userID
+}
+
+// loadAttachments remplace les IDs de pièces jointes envoyés par le client par leurs métadonnées
+func (s *ChatService) loadAttachments(conversation string, msg *models.Message) error {
+	msg.Attachments = nil
+	if len(msg.AttachmentIDs) == 0 {
+		return nil
+	}
+	attachments, err := s.Repo.GetAttachments(conversation, []int{msg.ID})
+	if err != nil {
+		return err
+	}
+	msg.AttachmentIDs = nil
+	msg.Attachments = attachments[msg.ID]
+	return nil
+}
+
+// CreateAttachment enregistre un fichier uploadé (déjà enregistré dans media) comme pièce jointe
+// de uploaderID ; elle sera rattachée au message qui la référence dans attachment_ids
+func (s *ChatService) CreateAttachment(uploaderID int, media *models.Media, fileName string) (models.Attachment, error) {
+	fileName = strings.TrimSpace(filepath.Base(filepath.ToSlash(fileName)))
+	if fileName == "." || fileName == "/" {
+		fileName = ""
+	}
+	if len(fileName) > 255 {
+		fileName = fileName[len(fileName)-255:]
+	}
+
+	kind := attachmentKind(media.MimeType)
+	id, err := s.Repo.CreateAttachment(media.ID, uploaderID, kind, fileName)
+	if err != nil {
+		return models.Attachment{}, err
+	}
+
+	return models.Attachment{
+		ID:       id,
+		Kind:     kind,
+		URL:      storage.MediaURL(media.Path),
+		FileName: fileName,
+		MimeType: media.MimeType,
+		Size:     media.Size,
+		Width:    media.Width,
+		Height:   media.Height,
+		BlurHash: media.BlurHash,
+		ThumbURL: storage.MediaURL(media.ThumbPath),
+	}, nil
+}
+
+// attachmentKind classe un type MIME détecté en image, audio (notes vocales comprises) ou fichier
+func attachmentKind(mimeType string) string {
+	switch {
+	case strings.HasPrefix(mimeType, "image/"):
+		return "image"
+	case strings.HasPrefix(mimeType, "audio/"), mimeType == "application/ogg", mimeType == "video/webm":
+		return "audio"
+	default:
+		return "file"
+	}
+}
+
+// loadMessageRef charge un message privé (groupID = 0) ou de groupe encore visible
+func (s *ChatService) loadMessageRef(messageID, groupID int) (models.MessageRef, error) {
+	var ref models.MessageRef
+	var err error
+	if groupID != 0 {
+		ref, err = s.Repo.GetGroupMessageRef(messageID)
+		if err == nil && ref.GroupID != groupID {
+			err = sql.ErrNoRows
+		}
+	} else {
+		ref, err = s.Repo.GetPrivateMessageRef(messageID)
+	}
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && ref.Deleted) {
+		return ref, ErrMessageNotFound
+	}
+	return ref, err
+}
+
+// withinEditWindow indique si le message est encore modifiable par son expéditeur
+func (s *ChatService) withinEditWindow(ref models.MessageRef) bool {
+	return s.EditWindow == 0 || time.Since(ref.SentAt) <= s.EditWindow
+}
+
+// EditMessage remplace le contenu d'un message par son expéditeur, dans le délai autorisé.
+// groupID vaut 0 pour un message privé. Retourne l'événement message_edited à diffuser.
+func (s *ChatService) EditMessage(userID, messageID, groupID int, content string) (models.MessageUpdate, error) {
+	content = strings.TrimSpace(content)
+	if content == "" {
+		return models.MessageUpdate{}, ErrEmptyMessage
+	}
+
+	ref, err := s.loadMessageRef(messageID, groupID)
+	if err != nil {
+		return models.MessageUpdate{}, err
+	}
+	if ref.From != userID {
+		return models.MessageUpdate{}, ErrNotMessageSender
+	}
+	if !s.withinEditWindow(ref) {
+		return models.MessageUpdate{}, ErrEditWindowExpired
+	}
+
+	conversation := "private"
+	if groupID != 0 {
+		conversation = "group"
+	}
+	editedAt, err := s.Repo.EditMessage(conversation, messageID, userID, content)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.MessageUpdate{}, ErrMessageNotFound
+	}
+	if err != nil {
+		return models.MessageUpdate{}, err
+	}
+
+	return models.MessageUpdate{
+		Type:      "message_edited",
+		ID:        messageID,
+		From:      ref.From,
+		To:        ref.To,
+		GroupID:   ref.GroupID,
+		Content:   content,
+		EditedAt:  editedAt.Format(time.RFC3339),
+		Timestamp: time.Now().Format(time.RFC3339),
+	}, nil
+}
+
+// DeleteMessage remplace un message par une trace de suppression. L'expéditeur peut le faire
+// dans le délai autorisé ; le créateur du groupe peut supprimer tout message du groupe, à tout moment.
+// Retourne l'événement message_deleted à diffuser.
+func (s *ChatService) DeleteMessage(userID, messageID, groupID int) (models.MessageUpdate, error) {
+	ref, err := s.loadMessageRef(messageID, groupID)
+	if err != nil {
+		return models.MessageUpdate{}, err
+	}
+
+	isGroupCreator := groupID != 0 && ref.GroupCreatorID == userID
+	if !isGroupCreator {
+		if ref.From != userID {
+			return models.MessageUpdate{}, ErrNotMessageSender
+		}
+		if !s.withinEditWindow(ref) {
+			return models.MessageUpdate{}, ErrEditWindowExpired
+		}
+	}
+
+	conversation := "private"
+	if groupID != 0 {
+		conversation = "group"
+	}
+	err = s.Repo.DeleteMessage(conversation, messageID, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.MessageUpdate{}, ErrMessageNotFound
+	}
+	if err != nil {
+		return models.MessageUpdate{}, err
+	}
+
+	return models.MessageUpdate{
+		Type:      "message_deleted",
+		ID:        messageID,
+		From:      ref.From,
+		To:        ref.To,
+		GroupID:   ref.GroupID,
+		DeletedBy: userID,
+		Timestamp: time.Now().Format(time.RFC3339),
+	}, nil
+}
+
+// React ajoute (add) ou retire l'emoji de userID sur un message privé (groupID = 0) ou de groupe.
+// L'appartenance au groupe est vérifiée par l'appelant. Retourne l'événement reaction_added ou
+// reaction_removed à diffuser, et false si la réaction était déjà dans cet état (rien à diffuser).
+func (s *ChatService) React(userID, messageID, groupID int, emoji string, add bool) (models.MessageUpdate, bool, error) {
+	emoji = strings.TrimSpace(emoji)
+	if !validEmoji(emoji) {
+		return models.MessageUpdate{}, false, ErrInvalidEmoji
+	}
+
+	ref, err := s.loadMessageRef(messageID, groupID)
+	if err != nil {
+		return models.MessageUpdate{}, false, err
+	}
+	conversation := "group"
+	if groupID == 0 {
+		// Un message privé n'existe que pour ses deux participants
+		if !isParticipant(ref, userID) {
+			return models.MessageUpdate{}, false, ErrMessageNotFound
+		}
+		if err := checkNotBlocked(s.BlockRepo, ref.From, ref.To); err != nil {
+			return models.MessageUpdate{}, false, err
+		}
+		conversation = "private"
+	}
+
+	var changed bool
+	eventType := "reaction_removed"
+	if add {
+		count, err := s.Repo.CountUserReactions(conversation, messageID, userID)
+		if err != nil {
+			return models.MessageUpdate{}, false, err
+		}
+		if count >= maxReactionsPerUser {
+			return models.MessageUpdate{}, false, ErrTooManyReactions
+		}
+		changed, err = s.Repo.AddReaction(conversation, messageID, userID, emoji)
+		eventType = "reaction_added"
+	} else {
+		changed, err = s.Repo.RemoveReaction(conversation, messageID, userID, emoji)
+	}
+	if err != nil || !changed {
+		return models.MessageUpdate{ID: messageID}, false, err
+	}
+
+	reactions, err := s.Repo.GetReactions(conversation, []int{messageID})
+	if err != nil {
+		return models.MessageUpdate{}, false, err
+	}
+	return models.MessageUpdate{
+		Type:      eventType,
+		ID:        messageID,
+		From:      ref.From,
+		To:        ref.To,
+		GroupID:   ref.GroupID,
+		UserID:    userID,
+		Emoji:     emoji,
+		Reactions: reactions[messageID],
+		Timestamp: time.Now().Format(time.RFC3339),
+	}, true, nil
+}
+
+// validEmoji accepte un emoji, éventuellement composé (ZWJ, variantes, tons de peau, drapeaux, keycaps) :
+// ni lettre ni espace, au moins un symbole hors ASCII (l'ASCII se limite aux keycaps 0-9, # et *)
+func validEmoji(emoji string) bool {
+	if emoji == "" || len(emoji) > maxEmojiLength || !utf8.ValidString(emoji) {
+		return false
+	}
+	hasSymbol := false
+	for _, r := range emoji {
+		switch {
+		case r <= unicode.MaxASCII:
+			if !unicode.IsDigit(r) && r != '#' && r != '*' {
+				return false
+			}
+		case unicode.IsLetter(r), unicode.IsSpace(r):
+			return false
+		default:
+			hasSymbol = true
+		}
+	}
+	return hasSymbol
 }
 
 func (s *ChatService) GetGroupMembers(groupID int) ([]models.GroupMember, error) {
@@ -0,0 +1,89 @@
+package services
+
+import (
+	"context"
+	"log"
+	"os"
+	"time"
+
+	"social/models"
+	"social/repositories"
+)
+
+// replayBatchSize est le nombre d'événements chargés à la fois pendant un rattrapage
+const replayBatchSize = 500
+
+// EventService tient le journal des événements temps réel de chaque utilisateur,
+// pour les rejouer quand il se reconnecte avec /ws?since=<seq>
+type EventService struct {
+	Repo      *repositories.EventRepository
+	Retention time.Duration // durée de conservation du journal
+}
+
+// NewEventService crée le service ; la conservation vient de EVENT_LOG_RETENTION (168h par défaut)
+func NewEventService(repo *repositories.EventRepository) *EventService {
+	retention, err := time.ParseDuration(os.Getenv("EVENT_LOG_RETENTION"))
+	if err != nil || retention <= 0 {
+		retention = 7 * 24 * time.Hour
+	}
+	return &EventService{Repo: repo, Retention: retention}
+}
+
+// Record enregistre les événements et leur attribue leur numéro de séquence
+func (s *EventService) Record(events []*models.UserEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+	return s.Repo.AppendEvents(events)
+}
+
+// Since retourne le prochain lot d'événements de userID postérieurs à since
+func (s *EventService) Since(userID int, since int64) ([]models.UserEvent, error) {
+	return s.Repo.GetEventsSince(userID, since, replayBatchSize)
+}
+
+// LastSeq retourne le dernier seq attribué à userID (0 si aucun)
+func (s *EventService) LastSeq(userID int) (int64, error) {
+	_, last, err := s.Repo.GetSeqRange(userID)
+	return last, err
+}
+
+// Truncated indique si des événements postérieurs à since ont déjà été purgés ;
+// oldest est alors le plus ancien encore disponible
+func (s *EventService) Truncated(userID int, since int64) (truncated bool, oldest int64, err error) {
+	oldest, last, err := s.Repo.GetSeqRange(userID)
+	if err != nil {
+		return false, 0, err
+	}
+	if last <= since {
+		return false, oldest, nil
+	}
+	// Rien dans le journal alors que des seq ont été attribués : tout a été purgé
+	if oldest == 0 {
+		return true, last + 1, nil
+	}
+	return oldest > since+1, oldest, nil
+}
+
+// StartPruner purge régulièrement les événements plus anciens que Retention, jusqu'à l'annulation de ctx
+func (s *EventService) StartPruner(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(time.Hour)
+		defer ticker.Stop()
+
+		for {
+			pruned, err := s.Repo.PruneEvents(time.Now().Add(-s.Retention))
+			if err != nil {
+				log.Println("❌ Event log pruning failed:", err)
+			} else if pruned > 0 {
+				log.Printf("🧹 Pruned %d expired real-time events", pruned)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
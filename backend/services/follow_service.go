@@ -1,22 +1,43 @@
 package services
 
 import (
+	"errors"
 	"fmt"
 	"social/models"
 	"social/repositories"
+	"social/storage"
 	"time"
 )
 
+var (
+	ErrFollowRequestNotFound   = errors.New("follow request not found")
+	ErrNotAFollower            = errors.New("user is not following you")
+	ErrInvalidDirection        = errors.New("direction must be incoming or outgoing")
+	ErrInvalidBulkFollowAction = errors.New("sender_ids must contain between 1 and 100 users")
+	ErrPrivateAccount          = errors.New("this account is private")
+)
+
+const (
+	defaultFollowRequestsPage = 20
+	maxFollowRequestsPage     = 100
+	maxBulkFollowActions      = 100
+)
+
 type FollowService struct {
 	Repo      *repositories.FollowRepository
 	NotifRepo *repositories.NotificationRepository
+	BlockRepo *repositories.BlockRepository
 }
 
-func NewFollowService(repo *repositories.FollowRepository, NotifRepo *repositories.NotificationRepository) *FollowService {
-	return &FollowService{Repo: repo, NotifRepo: NotifRepo}
+func NewFollowService(repo *repositories.FollowRepository, NotifRepo *repositories.NotificationRepository, BlockRepo *repositories.BlockRepository) *FollowService {
+	return &FollowService{Repo: repo, NotifRepo: NotifRepo, BlockRepo: BlockRepo}
 }
 
 func (s *FollowService) SendFollowRequest(followerID, followedID int) (models.Notification, string, error) {
+    if err := checkNotBlocked(s.BlockRepo, followerID, followedID); err != nil {
+        return models.Notification{}, "", err
+    }
+
     exists, err := s.Repo.FollowExists(followerID, followedID)
     if err != nil {
         return models.Notification{}, "", err
@@ -79,34 +100,180 @@ func (s *FollowService) GetFollowStatus(followerID, followedID int) (string, err
 	return s.Repo.GetFollowStatus(followerID, followedID)
 }
 
+// AcceptFollowRequest accepte la demande en attente de senderID vers receiverID
 func (s *FollowService) AcceptFollowRequest(senderID, receiverID int) error {
-	err := s.Repo.AcceptFollowRequest(senderID, receiverID)
+	processed, err := s.Repo.RespondToFollowRequests(receiverID, []int{senderID}, true)
 	if err != nil {
 		return err
 	}
-	return s.Repo.UpdateFollowNotificationStatus(senderID, receiverID, "accepted")
+	if len(processed) == 0 {
+		return ErrFollowRequestNotFound
+	}
+	return nil
 }
 
+// RejectFollowRequest refuse la demande en attente de senderID vers receiverID
 func (s *FollowService) RejectFollowRequest(senderID, receiverID int) error {
-	err := s.Repo.RejectFollowRequest(senderID, receiverID)
+	processed, err := s.Repo.RespondToFollowRequests(receiverID, []int{senderID}, false)
+	if err != nil {
+		return err
+	}
+	if len(processed) == 0 {
+		return ErrFollowRequestNotFound
+	}
+	return nil
+}
+
+// RespondToFollowRequests accepte ou refuse plusieurs demandes reçues par receiverID en une fois.
+// Retourne les expéditeurs dont la demande a bien été traitée.
+func (s *FollowService) RespondToFollowRequests(receiverID int, senderIDs []int, accept bool) ([]int, error) {
+	if len(senderIDs) == 0 || len(senderIDs) > maxBulkFollowActions {
+		return nil, ErrInvalidBulkFollowAction
+	}
+
+	seen := make(map[int]bool, len(senderIDs))
+	unique := make([]int, 0, len(senderIDs))
+	for _, id := range senderIDs {
+		if id > 0 && !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+
+	return s.Repo.RespondToFollowRequests(receiverID, unique, accept)
+}
+
+// GetFollowRequests retourne une page des demandes en attente de userID.
+// direction vaut "incoming" (reçues, par défaut) ou "outgoing" (envoyées).
+func (s *FollowService) GetFollowRequests(userID int, direction string, limit, offset int) (models.FollowRequestPage, error) {
+	if direction == "" {
+		direction = "incoming"
+	}
+	if direction != "incoming" && direction != "outgoing" {
+		return models.FollowRequestPage{}, ErrInvalidDirection
+	}
+	if limit <= 0 || limit > maxFollowRequestsPage {
+		limit = defaultFollowRequestsPage
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	requests, total, err := s.Repo.GetFollowRequests(userID, direction == "incoming", limit, offset)
+	if err != nil {
+		return models.FollowRequestPage{}, err
+	}
+	for i := range requests {
+		requests[i].Avatar = storage.MediaURL(requests[i].Avatar)
+	}
+
+	return models.FollowRequestPage{
+		Direction: direction,
+		Requests:  requests,
+		Total:     total,
+		Limit:     limit,
+		Offset:    offset,
+	}, nil
+}
+
+// CancelFollowRequest annule la demande envoyée par followerID à followedID
+func (s *FollowService) CancelFollowRequest(followerID, followedID int) error {
+	cancelled, err := s.Repo.CancelFollowRequest(followerID, followedID)
+	if err != nil {
+		return err
+	}
+	if !cancelled {
+		return ErrFollowRequestNotFound
+	}
+	return nil
+}
+
+// UnfollowUser arrête de suivre followedID, ou annule la demande si elle était encore en attente.
+// cancelled indique qu'il s'agissait d'une demande en attente.
+func (s *FollowService) UnfollowUser(followerID, followedID int) (cancelled bool, err error) {
+	cancelled, err = s.Repo.CancelFollowRequest(followerID, followedID)
+	if err != nil || cancelled {
+		return cancelled, err
+	}
+	return false, s.Repo.UnfollowUser(followerID, followedID)
+}
+
+// RemoveFollower retire followerID des abonnés de userID
+func (s *FollowService) RemoveFollower(userID, followerID int) error {
+	removed, err := s.Repo.RemoveFollower(userID, followerID)
 	if err != nil {
 		return err
 	}
-	return s.Repo.UpdateFollowNotificationStatus(senderID, receiverID, "rejected")
+	if !removed {
+		return ErrNotAFollower
+	}
+	return nil
 }
 
-func (s *FollowService) UnfollowUser(followerID, followedID int) error {
-	return s.Repo.UnfollowUser(followerID, followedID)
+// GetFollowers liste les abonnés de userID tels que viewerID peut les voir (sans les utilisateurs bloqués)
+func (s *FollowService) GetFollowers(viewerID, userID int) ([]models.Follower, error) {
+	if err := s.checkCanSeeConnections(viewerID, userID); err != nil {
+		return nil, err
+	}
+	followers, err := s.Repo.GetFollowers(userID, viewerID)
+	for i := range followers {
+		followers[i].Avatar = storage.MediaURL(followers[i].Avatar)
+	}
+	return followers, err
 }
 
-func (s *FollowService) GetFollowers(userID int) ([]models.Follower, error) {
-	return s.Repo.GetFollowers(userID)
+// GetFollowing liste les abonnements de userID tels que viewerID peut les voir (sans les utilisateurs bloqués)
+func (s *FollowService) GetFollowing(viewerID, userID int) ([]models.Following, error) {
+	if err := s.checkCanSeeConnections(viewerID, userID); err != nil {
+		return nil, err
+	}
+	following, err := s.Repo.GetFollowing(userID, viewerID)
+	for i := range following {
+		following[i].Avatar = storage.MediaURL(following[i].Avatar)
+	}
+	return following, err
 }
 
-func (s *FollowService) GetFollowing(userID int) ([]models.Following, error) {
-	return s.Repo.GetFollowing(userID)
+// checkCanSeeConnections refuse les listes d'abonnés/abonnements en cas de blocage,
+// ou si le compte est privé et que viewerID n'en est pas un abonné accepté
+func (s *FollowService) checkCanSeeConnections(viewerID, userID int) error {
+	if err := checkNotBlocked(s.BlockRepo, viewerID, userID); err != nil {
+		return err
+	}
+	if viewerID == userID {
+		return nil
+	}
+
+	exists, err := s.BlockRepo.UserExists(userID)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return ErrUserNotFound
+	}
+
+	isPrivate, err := s.Repo.IsPrivate(userID)
+	if err != nil {
+		return err
+	}
+	if !isPrivate {
+		return nil
+	}
+
+	status, err := s.Repo.GetFollowStatus(viewerID, userID)
+	if err != nil {
+		return err
+	}
+	if status != "accepted" {
+		return ErrPrivateAccount
+	}
+	return nil
 }
 
 func (s *FollowService) GetAcceptedFollowers(userID int) ([]models.Follower, error) {
-	return s.Repo.GetAcceptedFollowers(userID)
+	followers, err := s.Repo.GetAcceptedFollowers(userID)
+	for i := range followers {
+		followers[i].Avatar = storage.MediaURL(followers[i].Avatar)
+	}
+	return followers, err
 }
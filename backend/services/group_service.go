@@ -6,6 +6,8 @@ import (
 	"fmt"
 	"social/models"
 	"social/repositories"
+	"social/storage"
+	"sync"
 	"time"
 )
 
@@ -13,14 +15,49 @@ var (
 	ErrUnauthorized = errors.New("user not authorized")
 	ErrInvalidDate  = errors.New("invalid date format")
 	ErrEmptyMessage = errors.New("message content cannot be empty")
+
+	ErrNotGroupMember     = errors.New("user is not a member of this group")
+	ErrCreatorCannotLeave = errors.New("the group creator cannot leave or be removed")
+	ErrNoPendingRequest   = errors.New("no pending request for this user")
 )
 
 type GroupService struct {
-	Repo *repositories.GroupRepository
+	Repo      *repositories.GroupRepository
+	MediaRepo *repositories.MediaRepository
+	BlockRepo *repositories.BlockRepository
+
+	listenersMu sync.RWMutex
+	listeners   []func(models.GroupMembershipEvent)
+}
+
+// OnMembershipChange abonne fn aux changements d'adhésion ; fn est appelée de façon synchrone,
+// une fois le changement enregistré
+func (s *GroupService) OnMembershipChange(fn func(models.GroupMembershipEvent)) {
+	s.listenersMu.Lock()
+	defer s.listenersMu.Unlock()
+	s.listeners = append(s.listeners, fn)
+}
+
+func (s *GroupService) publishMembership(eventType string, groupID, userID, actorID, creatorID int) {
+	event := models.GroupMembershipEvent{
+		Type:      eventType,
+		GroupID:   groupID,
+		UserID:    userID,
+		ActorID:   actorID,
+		CreatorID: creatorID,
+		Timestamp: time.Now().Format(time.RFC3339),
+	}
+
+	s.listenersMu.RLock()
+	listeners := s.listeners
+	s.listenersMu.RUnlock()
+	for _, fn := range listeners {
+		fn(event)
+	}
 }
 
-func NewGroupService(Repo *repositories.GroupRepository) *GroupService {
-	return &GroupService{Repo: Repo}
+func NewGroupService(Repo *repositories.GroupRepository, MediaRepo *repositories.MediaRepository, BlockRepo *repositories.BlockRepository) *GroupService {
+	return &GroupService{Repo: Repo, MediaRepo: MediaRepo, BlockRepo: BlockRepo}
 }
 
 func (s *GroupService) GetGroupDetailsByID(groupID, userID int) (*models.GroupResponse, error) {
@@ -63,6 +100,10 @@ func (s *GroupService) GetPendingRequests(groupID, userID int) ([]models.Pending
 		return nil, err
 	}
 
+	for i := range requests {
+		requests[i].Avatar = storage.MediaURL(requests[i].Avatar)
+	}
+
 	return requests, nil
 }
 
@@ -97,6 +138,11 @@ func (s *GroupService) AcceptInvite(groupID, userID int) error {
 		return err
 	}
 
+	creatorID, err := s.Repo.GetGroupCreatorID(groupID)
+	if err != nil {
+		return err
+	}
+	s.publishMembership(models.GroupMemberJoined, groupID, userID, userID, creatorID)
 	return nil
 }
 
@@ -115,6 +161,11 @@ func (s *GroupService) RefuseInvite(groupID, userID int) error {
 		return err
 	}
 
+	creatorID, err := s.Repo.GetGroupCreatorID(groupID)
+	if err != nil {
+		return err
+	}
+	s.publishMembership(models.GroupInvitationRefused, groupID, userID, userID, creatorID)
 	return nil
 }
 
@@ -128,6 +179,10 @@ func (s *GroupService) InviteUserToGroup(groupID int, creatorID int, invite mode
         return models.Notification{}, fmt.Errorf("not authorized")
     }
 
+    if err := checkNotBlocked(s.BlockRepo, creatorID, invite.UserID); err != nil {
+        return models.Notification{}, err
+    }
+
     // Get group title for notification
     groupDetails, err := s.Repo.GetGroupDetailsByID(groupID, creatorID)
     if err != nil {
@@ -174,11 +229,15 @@ func (s *GroupService) ApproveMembership(groupID int, creatorID int, body models
 		return fmt.Errorf("forbidden")
 	}
 
-	err = s.Repo.ApproveMembershipRequest(groupID, body.UserID)
+	approved, err := s.Repo.ApproveMembershipRequest(groupID, body.UserID)
 	if err != nil {
 		return err
 	}
+	if !approved {
+		return ErrNoPendingRequest
+	}
 
+	s.publishMembership(models.GroupMemberJoined, groupID, body.UserID, creatorID, creatorID)
 	return nil
 }
 
@@ -191,19 +250,87 @@ func (s *GroupService) DeclineMembership(groupID int, creatorID int, body models
 		return fmt.Errorf("forbidden")
 	}
 
-	err = s.Repo.DeclineMembershipRequest(groupID, body.UserID)
+	declined, err := s.Repo.DeclineMembershipRequest(groupID, body.UserID)
+	if err != nil {
+		return err
+	}
+	if !declined {
+		return ErrNoPendingRequest
+	}
+
+	s.publishMembership(models.GroupRequestDeclined, groupID, body.UserID, creatorID, creatorID)
+	return nil
+}
+
+// LeaveGroup retire userID des membres du groupe ; le créateur ne peut pas partir
+func (s *GroupService) LeaveGroup(groupID, userID int) error {
+	creatorID, err := s.Repo.GetGroupCreatorID(groupID)
 	if err != nil {
 		return err
 	}
+	if creatorID == userID {
+		return ErrCreatorCannotLeave
+	}
+	if err := s.removeAcceptedMember(groupID, userID); err != nil {
+		return err
+	}
+
+	s.publishMembership(models.GroupMemberLeft, groupID, userID, userID, creatorID)
 	return nil
 }
 
+// RemoveMember exclut userID du groupe ; réservé au créateur
+func (s *GroupService) RemoveMember(groupID, creatorID, userID int) error {
+	dbCreatorID, err := s.Repo.GetGroupCreatorID(groupID)
+	if err != nil {
+		return err
+	}
+	if dbCreatorID != creatorID {
+		return ErrUnauthorized
+	}
+	if userID == creatorID {
+		return ErrCreatorCannotLeave
+	}
+	if err := s.removeAcceptedMember(groupID, userID); err != nil {
+		return err
+	}
+
+	s.publishMembership(models.GroupMemberRemoved, groupID, userID, creatorID, creatorID)
+	return nil
+}
+
+func (s *GroupService) removeAcceptedMember(groupID, userID int) error {
+	status, err := s.Repo.GetMembershipStatus(groupID, userID)
+	if err == sql.ErrNoRows || (err == nil && status != "accepted") {
+		return ErrNotGroupMember
+	}
+	if err != nil {
+		return err
+	}
+	return s.Repo.DeleteMembership(groupID, userID)
+}
+
 func (s *GroupService) GetNonGroupMembers(groupID, userID int) ([]map[string]interface{}, error) {
 	return s.Repo.GetNonGroupMembers(groupID, userID)
 }
 
 func (s *GroupService) GetGroupPosts(groupID, userID int) ([]models.GroupPost, error) {
-	return s.Repo.GetGroupPosts(groupID, userID)
+	posts, err := s.Repo.GetGroupPosts(groupID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	paths := make([]string, len(posts))
+	for i, p := range posts {
+		paths[i] = p.Image
+	}
+	media := lookupMedia(s.MediaRepo, paths)
+	for i := range posts {
+		posts[i].ImageMeta = publicMedia(media[posts[i].Image])
+		posts[i].Image = storage.MediaURL(posts[i].Image)
+	}
+
+	return posts, nil
 }
 
 func (s *GroupService) IsGroupMember(groupID, userID int) (bool, error) {
@@ -211,7 +338,13 @@ func (s *GroupService) IsGroupMember(groupID, userID int) (bool, error) {
 }
 
 func (s *GroupService) CreateGroupPost(post models.GroupPost) (*models.GroupPost, error) {
-	return s.Repo.CreateGroupPost(post)
+	created, err := s.Repo.CreateGroupPost(post)
+	if err != nil {
+		return nil, err
+	}
+	created.ImageMeta = publicMedia(lookupMedia(s.MediaRepo, []string{created.Image})[created.Image])
+	created.Image = storage.MediaURL(created.Image)
+	return created, nil
 }
 
 func (s *GroupService) GetGroupPostComments(postID, userID int) ([]models.GroupPostComment, error) {
@@ -387,3 +520,31 @@ func (s *GroupService) GetGroupMembers(groupID int) ([]models.GroupMember, error
 func (s *GroupService) GetGroupChatHistory(groupID, limit int) ([]models.GroupMessage, error) {
 	return s.Repo.GetGroupChatHistory(groupID, limit)
 }
+
+// GetGroupThread retourne le fil du message messageID : le message et une page de ses réponses
+// (pagination par ID : before = plus ancienne réponse déjà chargée)
+func (s *GroupService) GetGroupThread(groupID, messageID, before, limit int) (models.GroupThread, error) {
+	if before < 0 {
+		before = 0
+	}
+	if limit <= 0 || limit > maxHistoryPage {
+		limit = defaultHistoryPage
+	}
+
+	root, replies, err := s.Repo.GetGroupThread(groupID, messageID, before, limit)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.GroupThread{}, ErrMessageNotFound
+	}
+	if err != nil {
+		return models.GroupThread{}, err
+	}
+	if replies == nil {
+		replies = []models.GroupMessage{}
+	}
+
+	thread := models.GroupThread{Root: root, Replies: replies, Limit: limit}
+	if len(replies) == limit {
+		thread.NextBefore = replies[0].ID
+	}
+	return thread, nil
+}
@@ -0,0 +1,304 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"social/models"
+	"social/repositories"
+	"social/storage"
+)
+
+var ErrStorageQuotaExceeded = errors.New("storage quota exceeded")
+
+type MediaService struct {
+	Repo  *repositories.MediaRepository
+	Quota int64 // octets autorisés par utilisateur, 0 = illimité
+}
+
+// NewMediaService crée le service ; le quota par utilisateur vient de MEDIA_QUOTA_MB (500 par défaut, 0 = illimité)
+func NewMediaService(repo *repositories.MediaRepository) *MediaService {
+	quotaMB, err := strconv.ParseInt(os.Getenv("MEDIA_QUOTA_MB"), 10, 64)
+	if err != nil || quotaMB < 0 {
+		quotaMB = 500
+	}
+	return &MediaService{Repo: repo, Quota: quotaMB << 20}
+}
+
+// RecordUpload enregistre les métadonnées d'un upload (media peut être nil si aucun fichier)
+func (s *MediaService) RecordUpload(ownerID int, media *models.Media) error {
+	if media == nil {
+		return nil
+	}
+	media.OwnerID = ownerID
+	return s.Repo.CreateMedia(media)
+}
+
+// DiscardUpload supprime un upload qui ne sera finalement pas utilisé, par exemple quand l'insertion
+// en base qui devait le référencer échoue : le fichier, ses variantes et ses métadonnées, qui ne
+// compteront donc plus dans le quota (media peut être nil si aucun fichier)
+func (s *MediaService) DiscardUpload(ctx context.Context, media *models.Media) {
+	if media == nil {
+		return
+	}
+	for _, p := range []string{media.Path, media.ThumbPath, media.MediumPath} {
+		if p == "" {
+			continue
+		}
+		if err := storage.GetStorage().Delete(ctx, storage.KeyFromPath(p)); err != nil {
+			fmt.Println("❌ Failed to delete discarded upload:", err)
+		}
+	}
+	if media.ID > 0 {
+		if err := s.Repo.DeleteMedia(media.ID); err != nil {
+			fmt.Println("❌ Failed to delete discarded upload metadata:", err)
+		}
+	}
+}
+
+// QuotaCheck retourne la vérification à placer dans utils.UploadConfig.Quota :
+// elle refuse un upload de size octets qui ferait dépasser le quota de ownerID.
+func (s *MediaService) QuotaCheck(ownerID int) func(size int64) error {
+	return func(size int64) error {
+		if s.Quota <= 0 {
+			return nil
+		}
+		used, err := s.Repo.GetStorageUsage(ownerID)
+		if err != nil {
+			return err
+		}
+		if used+size > s.Quota {
+			return ErrStorageQuotaExceeded
+		}
+		return nil
+	}
+}
+
+// SweepReport résume un passage du nettoyage des fichiers orphelins
+type SweepReport struct {
+	Scanned    int
+	Orphans    int
+	FreedBytes int64
+	StaleMedia int
+	DryRun     bool
+}
+
+// SweepOrphans supprime du stockage les fichiers qui ne sont plus référencés par aucun
+// avatar, post, commentaire, post de groupe ou message du chat (variantes comprises), ainsi que leurs
+// métadonnées. Les fichiers plus récents que grace sont ignorés : ils peuvent appartenir
+// à un formulaire en cours d'envoi. En dryRun, rien n'est supprimé.
+func (s *MediaService) SweepOrphans(ctx context.Context, grace time.Duration, dryRun bool) (SweepReport, error) {
+	report := SweepReport{DryRun: dryRun}
+
+	refs, err := s.Repo.ReferencedPaths()
+	if err != nil {
+		return report, fmt.Errorf("failed to load referenced paths: %w", err)
+	}
+	referenced := make(map[string]bool, len(refs))
+	for p := range refs {
+		referenced[storage.KeyFromPath(p)] = true
+	}
+
+	media, err := s.Repo.ListMedia()
+	if err != nil {
+		return report, fmt.Errorf("failed to load media: %w", err)
+	}
+	var stale []models.Media
+	for _, m := range media {
+		if !referenced[storage.KeyFromPath(m.Path)] {
+			if time.Since(m.CreatedAt) > grace {
+				stale = append(stale, m)
+			}
+			continue
+		}
+		for _, variant := range []string{m.ThumbPath, m.MediumPath} {
+			if variant != "" {
+				referenced[storage.KeyFromPath(variant)] = true
+			}
+		}
+	}
+
+	objects, err := storage.GetStorage().List(ctx, "")
+	if err != nil {
+		return report, fmt.Errorf("failed to list stored files: %w", err)
+	}
+	report.Scanned = len(objects)
+
+	for _, obj := range objects {
+		if referenced[obj.Key] || time.Since(obj.ModTime) <= grace {
+			continue
+		}
+		report.Orphans++
+		report.FreedBytes += obj.Size
+		if dryRun {
+			log.Printf("🔎 orphaned upload %s (%d bytes)", obj.Key, obj.Size)
+			continue
+		}
+		if err := storage.GetStorage().Delete(ctx, obj.Key); err != nil {
+			log.Printf("❌ failed to delete orphaned upload %s: %v", obj.Key, err)
+		}
+	}
+
+	report.StaleMedia = len(stale)
+	if !dryRun {
+		for _, m := range stale {
+			if err := s.Repo.DeleteMedia(m.ID); err != nil {
+				log.Printf("❌ failed to delete media record %s: %v", m.Path, err)
+			}
+		}
+	}
+
+	return report, nil
+}
+
+// SweeperConfig règle le nettoyage périodique des uploads orphelins
+type SweeperConfig struct {
+	Interval time.Duration // 0 désactive le nettoyage
+	Grace    time.Duration
+	DryRun   bool
+}
+
+// SweeperConfigFromEnv lit MEDIA_GC_INTERVAL (6h), MEDIA_GC_GRACE (24h) et MEDIA_GC_DRY_RUN (false)
+func SweeperConfigFromEnv() SweeperConfig {
+	cfg := SweeperConfig{Interval: 6 * time.Hour, Grace: 24 * time.Hour}
+	if v, err := time.ParseDuration(os.Getenv("MEDIA_GC_INTERVAL")); err == nil {
+		cfg.Interval = v
+	}
+	if v, err := time.ParseDuration(os.Getenv("MEDIA_GC_GRACE")); err == nil {
+		cfg.Grace = v
+	}
+	cfg.DryRun = os.Getenv("MEDIA_GC_DRY_RUN") == "true"
+	return cfg
+}
+
+// StartOrphanSweeper lance le nettoyage en tâche de fond jusqu'à l'annulation de ctx
+func (s *MediaService) StartOrphanSweeper(ctx context.Context, cfg SweeperConfig) {
+	if cfg.Interval <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(cfg.Interval)
+		defer ticker.Stop()
+
+		for {
+			report, err := s.SweepOrphans(ctx, cfg.Grace, cfg.DryRun)
+			if err != nil {
+				log.Println("❌ Orphaned uploads sweep failed:", err)
+			} else if report.Orphans > 0 || report.StaleMedia > 0 {
+				log.Printf("🧹 Orphaned uploads sweep: %d/%d files orphaned (%d bytes), %d stale records, dry-run=%v",
+					report.Orphans, report.Scanned, report.FreedBytes, report.StaleMedia, report.DryRun)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// lookupMedia récupère les métadonnées des chemins donnés ; une erreur n'empêche pas
+// de servir le contenu, les images sont alors simplement renvoyées sans métadonnées.
+func lookupMedia(repo *repositories.MediaRepository, paths []string) map[string]*models.Media {
+	if repo == nil {
+		return nil
+	}
+	media, err := repo.GetMediaByPaths(paths)
+	if err != nil {
+		log.Println("❌ media lookup failed:", err)
+		return nil
+	}
+	return media
+}
+
+// publicMedia retourne une copie de m dont les chemins sont remplacés par des URLs publiques (nil reste nil)
+func publicMedia(m *models.Media) *models.Media {
+	if m == nil {
+		return nil
+	}
+	out := *m
+	out.Path = storage.MediaURL(m.Path)
+	out.ThumbPath = storage.MediaURL(m.ThumbPath)
+	out.MediumPath = storage.MediaURL(m.MediumPath)
+	return &out
+}
+
+// attachPostMedia ajoute aux posts les métadonnées de leur image et transforme les chemins stockés en URLs publiques
+func attachPostMedia(repo *repositories.MediaRepository, posts []models.PostFetch) {
+	paths := make([]string, len(posts))
+	for i, p := range posts {
+		paths[i] = p.ImageURL
+	}
+	media := lookupMedia(repo, paths)
+	for i := range posts {
+		posts[i].ImageMeta = publicMedia(media[posts[i].ImageURL])
+		posts[i].ImageURL = storage.MediaURL(posts[i].ImageURL)
+		posts[i].AuthorAvatar = storage.MediaURL(posts[i].AuthorAvatar)
+	}
+}
+
+// CanView indique si viewerID peut télécharger le fichier stocké sous key.
+// Les variantes (thumb, medium) héritent des droits de l'image d'origine :
+//   - avatar : tout utilisateur connecté
+//   - image de post : selon la visibilité du post
+//   - image de commentaire : selon la visibilité du post parent
+//   - image de post de groupe : membres du groupe
+//   - pièce jointe du chat : participants de la conversation ou membres du groupe
+//   - fichier non référencé : uniquement son propriétaire
+func (s *MediaService) CanView(viewerID int, key string) (bool, error) {
+	paths := storedPaths(storage.PathFromKey(key))
+
+	original, ownerID, err := s.Repo.FindOriginal(paths)
+	if err != nil {
+		return false, err
+	}
+	if original != "" {
+		paths = append(paths, storedPaths(original)...)
+	}
+	if ownerID != 0 && ownerID == viewerID {
+		return true, nil
+	}
+
+	isAvatar, err := s.Repo.IsAvatar(paths)
+	if err != nil || isAvatar {
+		return isAvatar, err
+	}
+
+	checks := []func(int, []string) (bool, bool, error){
+		s.Repo.PostImageAccess,
+		s.Repo.CommentImageAccess,
+		s.Repo.GroupPostImageAccess,
+		s.Repo.ChatAttachmentAccess,
+	}
+	for _, check := range checks {
+		_, visible, err := check(viewerID, paths)
+		if err != nil {
+			return false, err
+		}
+		if visible {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// ContentType retourne le type MIME enregistré à l'upload du fichier stocké sous key
+// (chaîne vide pour un fichier antérieur à la table media)
+func (s *MediaService) ContentType(key string) (string, error) {
+	return s.Repo.GetMimeType(storedPaths(storage.PathFromKey(key)))
+}
+
+// storedPaths retourne les formes sous lesquelles un chemin peut être enregistré en base
+// ("/uploads/x" pour les nouveaux uploads, "uploads/x" pour les anciens)
+func storedPaths(p string) []string {
+	trimmed := strings.TrimPrefix(p, "/")
+	return []string{"/" + trimmed, trimmed}
+}
@@ -0,0 +1,109 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"social/models"
+	"social/repositories"
+	"social/storage"
+)
+
+var (
+	ErrMessageRequestNotFound = errors.New("message request not found")
+	ErrInvalidDMPolicy        = errors.New("dm_policy must be everyone, followers, mutuals or nobody")
+	ErrInvalidRequestStatus   = errors.New("status must be pending or ignored")
+)
+
+// MessageRequestService gère la boîte des demandes de message et le réglage dm_policy ;
+// les demandes sont ouvertes par ChatService.ProcessPrivateMessage
+type MessageRequestService struct {
+	Repo      *repositories.MessageRequestRepository
+	BlockRepo *repositories.BlockRepository
+}
+
+func NewMessageRequestService(repo *repositories.MessageRequestRepository, blockRepo *repositories.BlockRepository) *MessageRequestService {
+	return &MessageRequestService{Repo: repo, BlockRepo: blockRepo}
+}
+
+// GetRequests retourne les demandes reçues par userID : en attente par défaut, ou ignorées
+func (s *MessageRequestService) GetRequests(userID int, status string) ([]models.MessageRequest, error) {
+	if status == "" {
+		status = models.MessageRequestPending
+	}
+	if status != models.MessageRequestPending && status != models.MessageRequestIgnored {
+		return nil, ErrInvalidRequestStatus
+	}
+
+	requests, err := s.Repo.GetRequests(userID, status)
+	if err != nil {
+		return nil, err
+	}
+	for i := range requests {
+		requests[i].Avatar = storage.MediaURL(requests[i].Avatar)
+		requests[i].LastMessage.Content = previewText(requests[i].LastMessage.Content)
+	}
+	return requests, nil
+}
+
+// Accept accepte la demande de senderID : la conversation devient une discussion normale.
+// Retourne l'événement à pousser à l'expéditeur.
+func (s *MessageRequestService) Accept(userID, senderID int) (models.MessageRequestEvent, error) {
+	updated, err := s.Repo.SetStatus(senderID, userID, models.MessageRequestAccepted)
+	if err != nil {
+		return models.MessageRequestEvent{}, err
+	}
+	if !updated {
+		return models.MessageRequestEvent{}, ErrMessageRequestNotFound
+	}
+	return models.MessageRequestEvent{
+		Type:      models.MessageRequestAcceptedEvent,
+		UserID:    userID,
+		Timestamp: time.Now().Format(time.RFC3339),
+	}, nil
+}
+
+// Ignore range la demande en attente de senderID parmi les demandes ignorées : ses messages suivants
+// sont enregistrés sans être livrés, et il n'en est pas prévenu
+func (s *MessageRequestService) Ignore(userID, senderID int) error {
+	status, err := s.Repo.GetStatus(senderID, userID)
+	if err != nil {
+		return err
+	}
+	if status != models.MessageRequestPending {
+		return ErrMessageRequestNotFound
+	}
+	_, err = s.Repo.SetStatus(senderID, userID, models.MessageRequestIgnored)
+	return err
+}
+
+// Block bloque l'auteur d'une demande en attente ou ignorée ; le blocage supprime la demande
+func (s *MessageRequestService) Block(userID, senderID int) error {
+	status, err := s.Repo.GetStatus(senderID, userID)
+	if err != nil {
+		return err
+	}
+	if status == "" || status == models.MessageRequestAccepted {
+		return ErrMessageRequestNotFound
+	}
+	return s.BlockRepo.BlockUser(userID, senderID)
+}
+
+func (s *MessageRequestService) GetDMPolicy(userID int) (models.DMSettings, error) {
+	policy, err := s.Repo.GetDMPolicy(userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.DMSettings{}, ErrUserNotFound
+	}
+	return models.DMSettings{DMPolicy: policy}, err
+}
+
+// SetDMPolicy change qui peut écrire à userID ; les discussions déjà acceptées ne sont pas concernées
+func (s *MessageRequestService) SetDMPolicy(userID int, policy string) error {
+	switch policy {
+	case models.DMPolicyEveryone, models.DMPolicyFollowers, models.DMPolicyMutuals, models.DMPolicyNobody:
+		return s.Repo.SetDMPolicy(userID, policy)
+	default:
+		return ErrInvalidDMPolicy
+	}
+}
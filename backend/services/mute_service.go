@@ -0,0 +1,109 @@
+package services
+
+import (
+	"errors"
+	"log"
+	"strings"
+	"time"
+	"unicode"
+
+	"social/models"
+	"social/repositories"
+	"social/storage"
+)
+
+var (
+	ErrCannotMute     = errors.New("cannot mute yourself")
+	ErrInvalidKeyword = errors.New("keyword must be between 1 and 100 characters and contain a letter or digit")
+	ErrExpiryInPast   = errors.New("expiry must be in the future")
+)
+
+const maxKeywordLength = 100
+
+// MuteService gère les masquages : contrairement au blocage, l'utilisateur masqué
+// n'en sait rien et peut toujours interagir, ses contenus sont juste cachés au muter.
+type MuteService struct {
+	Repo      *repositories.MuteRepository
+	BlockRepo *repositories.BlockRepository
+}
+
+func NewMuteService(repo *repositories.MuteRepository, blockRepo *repositories.BlockRepository) *MuteService {
+	return &MuteService{Repo: repo, BlockRepo: blockRepo}
+}
+
+func (s *MuteService) MuteUser(muterID int, req models.MuteRequest) error {
+	if muterID == req.UserID {
+		return ErrCannotMute
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		return ErrExpiryInPast
+	}
+	exists, err := s.BlockRepo.UserExists(req.UserID)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return ErrUserNotFound
+	}
+	return s.Repo.MuteUser(muterID, req.UserID, req.ExpiresAt)
+}
+
+func (s *MuteService) UnmuteUser(muterID, mutedID int) error {
+	return s.Repo.UnmuteUser(muterID, mutedID)
+}
+
+func (s *MuteService) MuteKeyword(userID int, req models.MuteKeywordRequest) (models.MutedKeyword, error) {
+	keyword := strings.ToLower(strings.Join(strings.Fields(req.Keyword), " "))
+	if !strings.ContainsFunc(keyword, isWordRune) || len(keyword) > maxKeywordLength {
+		return models.MutedKeyword{}, ErrInvalidKeyword
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		return models.MutedKeyword{}, ErrExpiryInPast
+	}
+	return s.Repo.AddMutedKeyword(userID, keyword, req.ExpiresAt)
+}
+
+// isWordRune indique si r peut faire partie d'un mot : un mot-clé fait seulement de ponctuation
+// ne correspondrait à aucun mot, ou à tous
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+func (s *MuteService) UnmuteKeyword(userID, keywordID int) error {
+	return s.Repo.DeleteMutedKeyword(userID, keywordID)
+}
+
+// GetMutes retourne les utilisateurs et mots-clés actuellement masqués par userID
+func (s *MuteService) GetMutes(userID int) (models.Mutes, error) {
+	if err := s.Repo.DeleteExpired(); err != nil {
+		log.Println("❌ Failed to delete expired mutes:", err)
+	}
+
+	users, err := s.Repo.GetMutedUsers(userID)
+	if err != nil {
+		return models.Mutes{}, err
+	}
+	for i := range users {
+		users[i].Avatar = storage.MediaURL(users[i].Avatar)
+	}
+
+	keywords, err := s.Repo.GetMutedKeywords(userID)
+	if err != nil {
+		return models.Mutes{}, err
+	}
+
+	return models.Mutes{Users: users, Keywords: keywords}, nil
+}
+
+// ShouldNotify indique si une notification de senderID doit être envoyée à recipientID
+func (s *MuteService) ShouldNotify(recipientID, senderID int) bool {
+	if senderID == 0 {
+		return true
+	}
+	muted, err := s.Repo.IsMuted(recipientID, senderID)
+	if err != nil {
+		log.Println("❌ Failed to check mute:", err)
+		return true
+	}
+	return !muted
+}
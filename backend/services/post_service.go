@@ -2,25 +2,43 @@
 package services
 
 import (
+	"database/sql"
+	"errors"
 	"social/models"
 	"social/repositories"
+	"social/storage"
 	"sort"
 	"time"
 )
 
 type PostService struct {
-	repo *repositories.PostRepository
+	repo   *repositories.PostRepository
+	media  *repositories.MediaRepository
+	blocks *repositories.BlockRepository
 }
 
-func NewPostService(repo *repositories.PostRepository) *PostService {
-	return &PostService{repo: repo}
+func NewPostService(repo *repositories.PostRepository, media *repositories.MediaRepository, blocks *repositories.BlockRepository) *PostService {
+	return &PostService{repo: repo, media: media, blocks: blocks}
 }
 
 // services/post_service.go
 func (s *PostService) GetUserPosts(authorID, currentUserID int) ([]models.PostFetch, error) {
 	// If user is viewing their own posts, return all posts
 	if authorID == currentUserID {
-		return s.repo.GetAllPostsByUserID(authorID)
+		posts, err := s.repo.GetAllPostsByUserID(authorID)
+		if err != nil {
+			return nil, err
+		}
+		attachPostMedia(s.media, posts)
+		return posts, nil
+	}
+
+	// Blocked users (in either direction) don't see each other's posts
+	if err := checkNotBlocked(s.blocks, authorID, currentUserID); err != nil {
+		if errors.Is(err, ErrUserBlocked) {
+			return []models.PostFetch{}, nil
+		}
+		return nil, err
 	}
 
 	// Check if the account is private
@@ -74,6 +92,7 @@ func (s *PostService) GetUserPosts(authorID, currentUserID int) ([]models.PostFe
 		return allPosts[i].CreatedAt.After(allPosts[j].CreatedAt)
 	})
 
+	attachPostMedia(s.media, allPosts)
 	return allPosts, nil
 }
 
@@ -89,14 +108,57 @@ func (s *PostService) CreatePost(authorID int, content, imageURL, privacy string
 }
 
 func (s *PostService) GetPostsForUser(userID int) ([]models.PostFetch, error) {
-	return s.repo.GetPostsForUser(userID)
+	posts, err := s.repo.GetPostsForUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	attachPostMedia(s.media, posts)
+	return posts, nil
 }
 
-func (s *PostService) GetCommentsByPost(postID string) ([]models.CommentWithUser, error) {
-	return s.repo.GetCommentsByPost(postID)
+func (s *PostService) GetCommentsByPost(postID string, viewerID int) ([]models.CommentWithUser, error) {
+	authorID, err := s.repo.GetPostAuthorID(postID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return []models.CommentWithUser{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := checkNotBlocked(s.blocks, authorID, viewerID); err != nil {
+		if errors.Is(err, ErrUserBlocked) {
+			return []models.CommentWithUser{}, nil
+		}
+		return nil, err
+	}
+
+	comments, err := s.repo.GetCommentsByPost(postID, viewerID)
+	if err != nil {
+		return nil, err
+	}
+
+	paths := make([]string, len(comments))
+	for i, c := range comments {
+		paths[i] = c.ImageURL
+	}
+	media := lookupMedia(s.media, paths)
+	for i := range comments {
+		comments[i].ImageMeta = publicMedia(media[comments[i].ImageURL])
+		comments[i].ImageURL = storage.MediaURL(comments[i].ImageURL)
+		comments[i].Author.Avatar = storage.MediaURL(comments[i].Author.Avatar)
+	}
+
+	return comments, nil
 }
 
 func (s *PostService) CreateComment(postID string, userID int, content, image string) error {
+	authorID, err := s.repo.GetPostAuthorID(postID)
+	if err != nil {
+		return err
+	}
+	if err := checkNotBlocked(s.blocks, authorID, userID); err != nil {
+		return err
+	}
+
 	createdAt := time.Now().Format("2006-01-02 15:04:05")
 	return s.repo.InsertComment(postID, userID, content, image, createdAt)
 }
@@ -0,0 +1,292 @@
+package services
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"social/models"
+	"social/repositories"
+)
+
+var (
+	ErrInvalidPresence  = errors.New("status must be online or away")
+	ErrTypingNotAllowed = errors.New("not allowed to type in this conversation")
+)
+
+const (
+	// Un typing_start n'est relayé qu'une fois par intervalle et par conversation
+	typingStartInterval = 2 * time.Second
+	maxPresenceLookup   = 100
+)
+
+type typingKey struct {
+	userID  int
+	to      int
+	groupID int
+}
+
+// remoteInstance est l'état de présence annoncé par une autre instance du backend
+type remoteInstance struct {
+	users map[int]models.PresenceCount
+	seen  time.Time
+}
+
+// PresenceService suit qui est connecté (avec le nombre de connexions par utilisateur),
+// le statut away choisi par le client, la dernière connexion, et limite les indicateurs de frappe.
+// Les connexions ouvertes sur les autres instances (reçues via le broker) comptent aussi.
+type PresenceService struct {
+	Repo      *repositories.PresenceRepository
+	ChatRepo  *repositories.ChatRepository
+	GroupRepo *repositories.GroupRepository
+	BlockRepo *repositories.BlockRepository
+
+	mu          sync.Mutex
+	connections map[int]int
+	away        map[int]bool
+	typing      map[typingKey]time.Time    // typing_start relayés et pas encore arrêtés
+	remote      map[string]*remoteInstance // par identifiant d'instance
+}
+
+func NewPresenceService(repo *repositories.PresenceRepository, chatRepo *repositories.ChatRepository, groupRepo *repositories.GroupRepository, blockRepo *repositories.BlockRepository) *PresenceService {
+	return &PresenceService{
+		Repo:        repo,
+		ChatRepo:    chatRepo,
+		GroupRepo:   groupRepo,
+		BlockRepo:   blockRepo,
+		connections: make(map[int]int),
+		away:        make(map[int]bool),
+		typing:      make(map[typingKey]time.Time),
+		remote:      make(map[string]*remoteInstance),
+	}
+}
+
+// Connect compte une nouvelle connexion ; retourne true si l'utilisateur vient de passer en ligne
+// (aucune autre connexion, ni ici ni sur une autre instance)
+func (s *PresenceService) Connect(userID int) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.connections[userID]++
+	if s.connections[userID] > 1 {
+		return false
+	}
+	delete(s.away, userID)
+	return !s.remoteConnected(userID)
+}
+
+// Disconnect retire une connexion ; quand c'était la dernière de toutes les instances, l'utilisateur
+// passe hors ligne, sa dernière connexion est enregistrée et offline vaut true
+func (s *PresenceService) Disconnect(userID int) (offline bool, lastSeen time.Time, err error) {
+	s.mu.Lock()
+	if s.connections[userID] > 1 {
+		s.connections[userID]--
+		s.mu.Unlock()
+		return false, time.Time{}, nil
+	}
+	delete(s.connections, userID)
+	delete(s.away, userID)
+	for key := range s.typing {
+		if key.userID == userID {
+			delete(s.typing, key)
+		}
+	}
+	stillOnline := s.remoteConnected(userID)
+	s.mu.Unlock()
+
+	if stillOnline {
+		return false, time.Time{}, nil
+	}
+	lastSeen = time.Now().UTC().Truncate(time.Second)
+	return true, lastSeen, s.Repo.SetLastSeen(userID, lastSeen)
+}
+
+// SetStatus passe un utilisateur connecté en away ou de nouveau en online ; changed vaut false si rien ne change
+func (s *PresenceService) SetStatus(userID int, status string) (changed bool, err error) {
+	if status != models.PresenceOnline && status != models.PresenceAway {
+		return false, ErrInvalidPresence
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.connections[userID] == 0 {
+		return false, nil
+	}
+	before := s.statusLocked(userID)
+	if status == models.PresenceAway {
+		s.away[userID] = true
+	} else {
+		delete(s.away, userID)
+	}
+	return before != s.statusLocked(userID), nil
+}
+
+// Status retourne online, away ou offline ; l'utilisateur est online dès qu'une de ses connexions
+// (sur n'importe quelle instance) n'est pas away
+func (s *PresenceService) Status(userID int) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.statusLocked(userID)
+}
+
+func (s *PresenceService) statusLocked(userID int) string {
+	connected := s.connections[userID] > 0
+	active := connected && !s.away[userID]
+	for _, inst := range s.remote {
+		if c := inst.users[userID]; c.Connections > 0 {
+			connected = true
+			active = active || !c.Away
+		}
+	}
+
+	switch {
+	case !connected:
+		return models.PresenceOffline
+	case !active:
+		return models.PresenceAway
+	default:
+		return models.PresenceOnline
+	}
+}
+
+func (s *PresenceService) remoteConnected(userID int) bool {
+	for _, inst := range s.remote {
+		if inst.users[userID].Connections > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// LocalState retourne les connexions ouvertes sur cette instance pour userIDs (un compte à zéro
+// pour ceux qui n'en ont plus), ou pour tous les utilisateurs connectés si userIDs est vide
+func (s *PresenceService) LocalState(userIDs ...int) map[int]models.PresenceCount {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(userIDs) == 0 {
+		for id := range s.connections {
+			userIDs = append(userIDs, id)
+		}
+	}
+	state := make(map[int]models.PresenceCount, len(userIDs))
+	for _, id := range userIDs {
+		state[id] = models.PresenceCount{Connections: s.connections[id], Away: s.away[id]}
+	}
+	return state
+}
+
+// ApplyRemote enregistre l'état annoncé par une autre instance : un instantané complet remplace
+// tout ce qu'on savait d'elle, sinon seuls les utilisateurs cités sont mis à jour
+func (s *PresenceService) ApplyRemote(sync models.PresenceSync) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	inst := s.remote[sync.Instance]
+	if inst == nil || sync.Full {
+		inst = &remoteInstance{users: make(map[int]models.PresenceCount)}
+		s.remote[sync.Instance] = inst
+	}
+	inst.seen = time.Now()
+	for id, c := range sync.Users {
+		if c.Connections > 0 {
+			inst.users[id] = c
+		} else {
+			delete(inst.users, id)
+		}
+	}
+}
+
+// PruneRemote oublie les instances qui n'ont rien annoncé depuis maxAge (arrêtées ou injoignables)
+func (s *PresenceService) PruneRemote(maxAge time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, inst := range s.remote {
+		if time.Since(inst.seen) > maxAge {
+			delete(s.remote, id)
+		}
+	}
+}
+
+// GetPresence retourne la présence des utilisateurs demandés, sans ceux bloqués par (ou bloquant) viewerID
+func (s *PresenceService) GetPresence(viewerID int, userIDs []int) ([]models.Presence, error) {
+	if len(userIDs) > maxPresenceLookup {
+		userIDs = userIDs[:maxPresenceLookup]
+	}
+
+	visible := make([]int, 0, len(userIDs))
+	for _, id := range userIDs {
+		if err := checkNotBlocked(s.BlockRepo, viewerID, id); err != nil {
+			if errors.Is(err, ErrUserBlocked) {
+				continue
+			}
+			return nil, err
+		}
+		visible = append(visible, id)
+	}
+
+	lastSeen, err := s.Repo.GetLastSeen(visible)
+	if err != nil {
+		return nil, err
+	}
+
+	presence := make([]models.Presence, 0, len(visible))
+	for _, id := range visible {
+		p := models.Presence{UserID: id, Status: s.Status(id)}
+		if t, ok := lastSeen[id]; ok && p.Status == models.PresenceOffline {
+			p.LastSeen = &t
+		}
+		presence = append(presence, p)
+	}
+	return presence, nil
+}
+
+// GetWatchers liste les utilisateurs à prévenir des changements de présence de userID
+func (s *PresenceService) GetWatchers(userID int) ([]models.PresenceWatcher, error) {
+	return s.Repo.GetWatchers(userID)
+}
+
+// AllowTyping vérifie que l'événement de frappe peut être relayé : l'expéditeur doit pouvoir
+// écrire dans la conversation (discussion privée autorisée ou membre du groupe), et un
+// typing_start n'est relayé qu'une fois par intervalle. Un typing_stop n'est relayé que
+// s'il arrête un typing_start relayé. relay vaut false quand l'événement est simplement ignoré.
+func (s *PresenceService) AllowTyping(userID int, event models.TypingEvent) (relay bool, err error) {
+	key := typingKey{userID: userID, to: event.To, groupID: event.GroupID}
+
+	if event.Type == "typing_stop" {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if _, ok := s.typing[key]; !ok {
+			return false, nil
+		}
+		delete(s.typing, key)
+		return true, nil
+	}
+
+	s.mu.Lock()
+	last, ok := s.typing[key]
+	s.mu.Unlock()
+	if ok && time.Since(last) < typingStartInterval {
+		return false, nil
+	}
+
+	var allowed bool
+	if event.GroupID != 0 {
+		allowed, err = s.GroupRepo.IsGroupMember(event.GroupID, userID)
+	} else {
+		allowed, err = s.ChatRepo.CanUsersChat(userID, event.To)
+	}
+	if err != nil {
+		return false, err
+	}
+	if !allowed {
+		return false, ErrTypingNotAllowed
+	}
+
+	s.mu.Lock()
+	s.typing[key] = time.Now()
+	s.mu.Unlock()
+	return true, nil
+}
@@ -1,19 +1,30 @@
 package services
 
 import (
+	"database/sql"
+	"errors"
 	"social/models"
 	"social/repositories"
+	"social/storage"
 )
 
 type ProfileService struct {
 	ProfileRepo repositories.SqliteProfileRepo
+	MediaRepo   *repositories.MediaRepository
+	BlockRepo   *repositories.BlockRepository
+	MuteRepo    *repositories.MuteRepository
 }
 
-func NewProfileService(repo repositories.SqliteProfileRepo) *ProfileService {
-	return &ProfileService{ProfileRepo: repo}
+func NewProfileService(repo repositories.SqliteProfileRepo, mediaRepo *repositories.MediaRepository, blockRepo *repositories.BlockRepository, muteRepo *repositories.MuteRepository) *ProfileService {
+	return &ProfileService{ProfileRepo: repo, MediaRepo: mediaRepo, BlockRepo: blockRepo, MuteRepo: muteRepo}
 }
 
 func (s *ProfileService) GetUserProfile(requesterID, targetID int) (*models.Profile, error) {
+	// Un profil bloqué est présenté comme introuvable aux deux utilisateurs
+	if err := checkNotBlocked(s.BlockRepo, requesterID, targetID); err != nil {
+		return nil, err
+	}
+
 	user, err := s.ProfileRepo.FindByID(targetID)
 	if err != nil {
 		return nil, err
@@ -21,6 +32,11 @@ func (s *ProfileService) GetUserProfile(requesterID, targetID int) (*models.Prof
 
 	user.IsOwner = (requesterID == targetID)
 
+	user.FollowersCount, user.FollowingCount, err = s.ProfileRepo.CountFollows(targetID)
+	if err != nil {
+		return nil, err
+	}
+
 	if !user.IsOwner {
 		isFollowed, err := s.ProfileRepo.IsFollowing(requesterID, user.ID)
 		if err == nil {
@@ -40,16 +56,69 @@ func (s *ProfileService) GetUserProfile(requesterID, targetID int) (*models.Prof
 		user.About = ""
 		user.DateOfBirth = ""
 	}
+
+	user.AvatarMeta = publicMedia(lookupMedia(s.MediaRepo, []string{user.Avatar})[user.Avatar])
+	user.Avatar = storage.MediaURL(user.Avatar)
 	return user, nil
 }
 
-func (us *ProfileService) SearchUsers(query string) ([]models.SearchResult, error) {
+func (us *ProfileService) SearchUsers(requesterID int, query string) ([]models.SearchResult, error) {
 	if query == "" {
 		return nil, nil
 	}
-	return us.ProfileRepo.SearchUsers(query)
+	return us.ProfileRepo.SearchUsers(requesterID, query)
 }
 
-func (s *ProfileService) TogglePrivacy(userID int, isPrivate bool) error {
-	return s.ProfileRepo.TogglePrivacy(userID, isPrivate)
-}
\ No newline at end of file
+// TogglePrivacy change la confidentialité du compte ; voir SqliteProfileRepo.TogglePrivacy pour les effets
+func (s *ProfileService) TogglePrivacy(userID int, isPrivate, requireReapproval bool) (models.PrivacyChange, error) {
+	return s.ProfileRepo.TogglePrivacy(userID, isPrivate, requireReapproval)
+}
+
+// GetMutuals liste les personnes suivies par viewerID qui suivent aussi userID.
+// Les abonnés d'un compte privé ne sont visibles que de ses abonnés.
+func (s *ProfileService) GetMutuals(viewerID, userID int) ([]models.UserSummary, error) {
+	if err := checkNotBlocked(s.BlockRepo, viewerID, userID); err != nil {
+		return nil, err
+	}
+	if viewerID == userID {
+		return []models.UserSummary{}, nil
+	}
+
+	user, err := s.ProfileRepo.FindByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.IsPrivate {
+		isFollowing, err := s.ProfileRepo.IsFollowing(viewerID, userID)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		}
+		if !isFollowing {
+			return nil, ErrPrivateAccount
+		}
+	}
+
+	mutuals, err := s.ProfileRepo.GetMutuals(viewerID, userID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range mutuals {
+		mutuals[i].Avatar = storage.MediaURL(mutuals[i].Avatar)
+	}
+	return mutuals, nil
+}
+
+// GetRelationship résume en un appel le lien entre viewerID et userID
+func (s *ProfileService) GetRelationship(viewerID, userID int) (models.Relationship, error) {
+	if _, err := s.ProfileRepo.FindByID(userID); err != nil {
+		return models.Relationship{}, err
+	}
+
+	rel, err := s.ProfileRepo.GetRelationship(viewerID, userID)
+	if err != nil {
+		return rel, err
+	}
+
+	rel.Muted, err = s.MuteRepo.IsMuted(viewerID, userID)
+	return rel, err
+}
@@ -0,0 +1,249 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Actions limitées, chacune avec son propre budget
+const (
+	ActionMessage = "message" // messages privés et de groupe, leurs modifications et les réactions
+	ActionPost    = "post"    // publications, y compris dans les groupes
+	ActionComment = "comment"
+	ActionInvite  = "invite" // invitations dans un groupe
+	ActionUpload  = "upload" // pièces jointes du chat
+)
+
+// Raisons d'un refus
+const (
+	LimitRateExceeded     = "rate_limited"
+	LimitDuplicateContent = "duplicate_content"
+)
+
+const (
+	// Plus de duplicateLimit contenus identiques dans duplicateWindow : le suivant est refusé
+	duplicateLimit  = 3
+	duplicateWindow = time.Minute
+	// strikeLimit refus dans strikeWindow (rafale ou doublons) ralentissent le compte pendant slowdownDuration :
+	// ses budgets se rechargent slowdownFactor fois moins vite
+	strikeLimit      = 5
+	strikeWindow     = time.Minute
+	slowdownDuration = 5 * time.Minute
+	slowdownFactor   = 4
+	// Un utilisateur sans activité depuis idleExpiry est oublié
+	idleExpiry = time.Hour
+)
+
+var ErrRateLimited = errors.New("rate limit exceeded")
+
+// RateLimitError est retourné quand une action est refusée ; errors.Is(err, ErrRateLimited) est vrai
+type RateLimitError struct {
+	Action     string
+	Reason     string // LimitRateExceeded ou LimitDuplicateContent
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	if e.Reason == LimitDuplicateContent {
+		return fmt.Sprintf("same content sent too many times, retry in %s", e.RetryAfter.Round(time.Second))
+	}
+	return fmt.Sprintf("too many %s actions, retry in %s", e.Action, e.RetryAfter.Round(time.Second))
+}
+
+func (e *RateLimitError) Unwrap() error { return ErrRateLimited }
+
+// Budget autorise Limit actions par période Per, avec au plus Limit d'affilée
+type Budget struct {
+	Limit int
+	Per   time.Duration
+}
+
+// DefaultBudgets sont les budgets par défaut de chaque action
+var DefaultBudgets = map[string]Budget{
+	ActionMessage: {Limit: 30, Per: time.Minute},
+	ActionPost:    {Limit: 10, Per: 10 * time.Minute},
+	ActionComment: {Limit: 20, Per: time.Minute},
+	ActionInvite:  {Limit: 30, Per: 10 * time.Minute},
+	ActionUpload:  {Limit: 20, Per: time.Minute},
+}
+
+// RateLimitConfigFromEnv part des budgets par défaut, remplacés par RATE_LIMIT_<ACTION>=limite/période
+// (par exemple RATE_LIMIT_MESSAGE=30/1m)
+func RateLimitConfigFromEnv() map[string]Budget {
+	budgets := make(map[string]Budget, len(DefaultBudgets))
+	for action, b := range DefaultBudgets {
+		budgets[action] = b
+		v := os.Getenv("RATE_LIMIT_" + strings.ToUpper(action))
+		if v == "" {
+			continue
+		}
+		limit, per, ok := strings.Cut(v, "/")
+		n, err1 := strconv.Atoi(limit)
+		d, err2 := time.ParseDuration(per)
+		if !ok || err1 != nil || err2 != nil || n <= 0 || d <= 0 {
+			log.Printf("⚠️ Invalid RATE_LIMIT_%s=%q, keeping %d/%s", strings.ToUpper(action), v, b.Limit, b.Per)
+			continue
+		}
+		budgets[action] = Budget{Limit: n, Per: d}
+	}
+	return budgets
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+type contentHit struct {
+	hash uint64
+	at   time.Time
+}
+
+type limitState struct {
+	buckets   map[string]*tokenBucket
+	recent    []contentHit // contenus envoyés dans duplicateWindow
+	strikes   []time.Time  // refus dans strikeWindow
+	slowUntil time.Time
+	seen      time.Time
+}
+
+// RateLimitService limite en mémoire les actions de chaque utilisateur (seau à jetons par action),
+// pour le hub comme pour les routes HTTP. Les compteurs sont propres à chaque instance du backend.
+type RateLimitService struct {
+	budgets map[string]Budget
+
+	mu    sync.Mutex
+	users map[int]*limitState
+}
+
+func NewRateLimitService(budgets map[string]Budget) *RateLimitService {
+	return &RateLimitService{budgets: budgets, users: make(map[int]*limitState)}
+}
+
+// Allow consomme un jeton de action pour userID, ou retourne une *RateLimitError
+func (s *RateLimitService) Allow(userID int, action string) error {
+	return s.AllowContent(userID, action, "")
+}
+
+// AllowContent fait de même en refusant aussi un contenu déjà envoyé trop de fois récemment
+// (comparé sans tenir compte de la casse ni des espaces)
+func (s *RateLimitService) AllowContent(userID int, action, content string) error {
+	budget, ok := s.budgets[action]
+	if !ok {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	u := s.users[userID]
+	if u == nil {
+		u = &limitState{buckets: make(map[string]*tokenBucket)}
+		s.users[userID] = u
+	}
+	u.seen = now
+
+	// Jetons par seconde, réduits pendant un ralentissement
+	rate := float64(budget.Limit) / budget.Per.Seconds()
+	if now.Before(u.slowUntil) {
+		rate /= slowdownFactor
+	}
+
+	b := u.buckets[action]
+	if b == nil {
+		b = &tokenBucket{tokens: float64(budget.Limit), last: now}
+		u.buckets[action] = b
+	}
+	b.tokens = min(float64(budget.Limit), b.tokens+now.Sub(b.last).Seconds()*rate)
+	b.last = now
+
+	var hash uint64
+	if content != "" {
+		hash = contentHash(content)
+		kept := u.recent[:0]
+		count := 0
+		var oldest time.Time
+		for _, hit := range u.recent {
+			if now.Sub(hit.at) < duplicateWindow {
+				kept = append(kept, hit)
+				if hit.hash == hash {
+					if count == 0 {
+						oldest = hit.at
+					}
+					count++
+				}
+			}
+		}
+		u.recent = kept
+		if count >= duplicateLimit {
+			s.strike(u, now, true)
+			return &RateLimitError{Action: action, Reason: LimitDuplicateContent, RetryAfter: duplicateWindow - now.Sub(oldest)}
+		}
+	}
+
+	if b.tokens < 1 {
+		s.strike(u, now, false)
+		wait := time.Duration((1 - b.tokens) / rate * float64(time.Second))
+		return &RateLimitError{Action: action, Reason: LimitRateExceeded, RetryAfter: wait}
+	}
+	b.tokens--
+	if content != "" {
+		u.recent = append(u.recent, contentHit{hash: hash, at: now})
+	}
+	return nil
+}
+
+// strike compte un refus ; trop de refus rapprochés, ou du contenu répété, ralentissent le compte
+func (s *RateLimitService) strike(u *limitState, now time.Time, duplicate bool) {
+	kept := u.strikes[:0]
+	for _, t := range u.strikes {
+		if now.Sub(t) < strikeWindow {
+			kept = append(kept, t)
+		}
+	}
+	u.strikes = append(kept, now)
+
+	if (duplicate || len(u.strikes) >= strikeLimit) && now.After(u.slowUntil) {
+		u.slowUntil = now.Add(slowdownDuration)
+	}
+}
+
+// StartJanitor oublie périodiquement les utilisateurs inactifs jusqu'à l'annulation de ctx
+func (s *RateLimitService) StartJanitor(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(10 * time.Minute)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			now := time.Now()
+			s.mu.Lock()
+			for id, u := range s.users {
+				if now.Sub(u.seen) > idleExpiry && now.After(u.slowUntil) {
+					delete(s.users, id)
+				}
+			}
+			s.mu.Unlock()
+		}
+	}()
+}
+
+func contentHash(content string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(strings.Join(strings.Fields(strings.ToLower(content)), " ")))
+	return h.Sum64()
+}
@@ -0,0 +1,53 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// drain vide le seau action de userID comme si son dernier jeton avait été pris il y a ago
+func drain(s *RateLimitService, userID int, action string, ago time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b := s.users[userID].buckets[action]
+	b.tokens = 0
+	b.last = time.Now().Add(-ago)
+}
+
+func TestDuplicateContentSlowsDownAccount(t *testing.T) {
+	s := NewRateLimitService(map[string]Budget{ActionPost: {Limit: 100, Per: time.Minute}})
+
+	for i := 0; i < duplicateLimit; i++ {
+		if err := s.AllowContent(1, ActionPost, "Buy now"); err != nil {
+			t.Fatalf("post %d refused: %v", i+1, err)
+		}
+		if err := s.AllowContent(2, ActionPost, "hello"); err != nil {
+			t.Fatalf("user 2 post %d refused: %v", i+1, err)
+		}
+	}
+
+	// Le même contenu, à la casse et aux espaces près, est refusé une fois la limite atteinte
+	err := s.AllowContent(1, ActionPost, "  buy   NOW ")
+	var rl *RateLimitError
+	if !errors.As(err, &rl) || rl.Reason != LimitDuplicateContent || !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("duplicate error = %v, want %s", err, LimitDuplicateContent)
+	}
+	if rl.RetryAfter <= 0 || rl.RetryAfter > duplicateWindow {
+		t.Fatalf("RetryAfter = %s, want within %s", rl.RetryAfter, duplicateWindow)
+	}
+	if err := s.AllowContent(1, ActionPost, "something else"); err != nil {
+		t.Fatalf("other content refused: %v", err)
+	}
+
+	// Le doublon a ralenti le compte : en 1,2 s, le seau de l'utilisateur 1 ne regagne qu'un
+	// demi-jeton (100/min divisé par slowdownFactor), celui de l'utilisateur 2 en regagne deux
+	drain(s, 1, ActionPost, 1200*time.Millisecond)
+	drain(s, 2, ActionPost, 1200*time.Millisecond)
+	if err := s.Allow(1, ActionPost); !errors.As(err, &rl) || rl.Reason != LimitRateExceeded {
+		t.Fatalf("slowed down account error = %v, want %s", err, LimitRateExceeded)
+	}
+	if err := s.Allow(2, ActionPost); err != nil {
+		t.Fatalf("user 2 refused: %v", err)
+	}
+}
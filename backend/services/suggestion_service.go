@@ -0,0 +1,56 @@
+package services
+
+import (
+	"errors"
+	"social/models"
+	"social/repositories"
+	"social/storage"
+)
+
+var ErrInvalidSuggestion = errors.New("cannot dismiss yourself")
+
+const (
+	defaultSuggestionLimit = 20
+	maxSuggestionLimit     = 50
+)
+
+type SuggestionService struct {
+	Repo      *repositories.SuggestionRepository
+	BlockRepo *repositories.BlockRepository
+}
+
+func NewSuggestionService(repo *repositories.SuggestionRepository, blockRepo *repositories.BlockRepository) *SuggestionService {
+	return &SuggestionService{Repo: repo, BlockRepo: blockRepo}
+}
+
+func (s *SuggestionService) GetUserSuggestions(userID, limit int) ([]models.UserSuggestion, error) {
+	if limit <= 0 {
+		limit = defaultSuggestionLimit
+	}
+	if limit > maxSuggestionLimit {
+		limit = maxSuggestionLimit
+	}
+
+	suggestions, err := s.Repo.GetUserSuggestions(userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	for i := range suggestions {
+		suggestions[i].Avatar = storage.MediaURL(suggestions[i].Avatar)
+	}
+	return suggestions, nil
+}
+
+func (s *SuggestionService) DismissSuggestion(userID, dismissedID int) error {
+	if userID == dismissedID {
+		return ErrInvalidSuggestion
+	}
+	exists, err := s.BlockRepo.UserExists(dismissedID)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return ErrUserNotFound
+	}
+	return s.Repo.DismissSuggestion(userID, dismissedID)
+}
@@ -0,0 +1,71 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+)
+
+// Seekable returns the body of obj, opened from s, as an io.ReadSeekCloser for
+// http.ServeContent. Seekable bodies (local files) are returned as is. Other bodies
+// (S3) are streamed: a Seek only moves the position, and the next Read reopens the
+// file from there with GetRange, so nothing is buffered in memory.
+func Seekable(ctx context.Context, s Storage, obj *Object) io.ReadSeekCloser {
+	if rs, ok := obj.Body.(io.ReadSeekCloser); ok {
+		return rs
+	}
+	return &rangeReader{ctx: ctx, s: s, key: obj.Key, size: obj.Size, body: obj.Body}
+}
+
+type rangeReader struct {
+	ctx  context.Context
+	s    Storage
+	key  string
+	size int64
+
+	body    io.ReadCloser // nil until reopened
+	bodyPos int64         // offset of the next byte of body
+	pos     int64
+}
+
+func (r *rangeReader) Read(p []byte) (int, error) {
+	if r.pos >= r.size {
+		return 0, io.EOF
+	}
+	if r.body == nil || r.bodyPos != r.pos {
+		if r.body != nil {
+			r.body.Close()
+			r.body = nil
+		}
+		obj, err := r.s.GetRange(r.ctx, r.key, r.pos)
+		if err != nil {
+			return 0, err
+		}
+		r.body, r.bodyPos = obj.Body, r.pos
+	}
+	n, err := r.body.Read(p)
+	r.pos += int64(n)
+	r.bodyPos += int64(n)
+	return n, err
+}
+
+func (r *rangeReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		offset += r.pos
+	case io.SeekEnd:
+		offset += r.size
+	}
+	if offset < 0 {
+		return 0, errors.New("storage: negative position")
+	}
+	r.pos = offset
+	return offset, nil
+}
+
+func (r *rangeReader) Close() error {
+	if r.body == nil {
+		return nil
+	}
+	return r.body.Close()
+}
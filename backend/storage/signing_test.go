@@ -0,0 +1,27 @@
+package storage
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSignedURLIgnoresSubSecondTTL(t *testing.T) {
+	t.Setenv("MEDIA_URL_TTL", "500ms")
+	t.Setenv("MEDIA_SIGNING_KEY", "test-key")
+	defer func(key []byte, ttl time.Duration) { signingKey, signedURLTTL = key, ttl }(signingKey, signedURLTTL)
+	signingKey, signedURLTTL = nil, time.Hour
+
+	raw := SignedURL("posts/a.jpg")
+	if signedURLTTL != time.Hour {
+		t.Fatalf("TTL = %v, want the 1h default", signedURLTTL)
+	}
+	u, err := url.Parse(raw)
+	if err != nil || !strings.HasSuffix(u.Path, "/posts/a.jpg") {
+		t.Fatalf("SignedURL = %q", raw)
+	}
+	if _, ok := VerifySignedURL("posts/a.jpg", u.Query()); !ok {
+		t.Fatalf("signature of %q not accepted", raw)
+	}
+}
@@ -0,0 +1,157 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+var ErrNotFound = errors.New("object not found")
+
+// Storage is the backend where uploaded media are kept. Keys are slash separated
+// paths relative to the uploads root, e.g. "avatars/1700000000_ab12cd34.jpg".
+type Storage interface {
+	Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) error
+	Get(ctx context.Context, key string) (*Object, error)
+	GetRange(ctx context.Context, key string, offset int64) (*Object, error)
+	Delete(ctx context.Context, key string) error
+	List(ctx context.Context, prefix string) ([]ObjectInfo, error)
+	URL(key string) string
+}
+
+// Object is an opened stored file. Body must be closed by the caller. Size is the size
+// of the whole file, even when Body starts at an offset (GetRange).
+type Object struct {
+	ObjectInfo
+	Body io.ReadCloser
+}
+
+type ObjectInfo struct {
+	Key         string
+	Size        int64
+	ContentType string
+	ModTime     time.Time
+	ETag        string
+}
+
+// Config describes which driver to use and how to reach it.
+type Config struct {
+	Driver        string // "local" or "s3"
+	PublicBaseURL string // base URL of this backend, used to build local media URLs
+	LocalRoot     string
+
+	S3Endpoint     string
+	S3Region       string
+	S3Bucket       string
+	S3AccessKey    string
+	S3SecretKey    string
+	S3PublicURL    string // optional CDN / public bucket URL
+	S3UsePathStyle bool
+}
+
+// ConfigFromEnv reads the storage configuration from environment variables.
+func ConfigFromEnv() Config {
+	return Config{
+		Driver:        getEnv("STORAGE_DRIVER", "local"),
+		PublicBaseURL: strings.TrimRight(getEnv("PUBLIC_BASE_URL", "http://localhost:8080"), "/"),
+		LocalRoot:     getEnv("UPLOADS_DIR", "uploads"),
+
+		S3Endpoint:     os.Getenv("S3_ENDPOINT"),
+		S3Region:       getEnv("S3_REGION", "us-east-1"),
+		S3Bucket:       os.Getenv("S3_BUCKET"),
+		S3AccessKey:    os.Getenv("S3_ACCESS_KEY_ID"),
+		S3SecretKey:    os.Getenv("S3_SECRET_ACCESS_KEY"),
+		S3PublicURL:    os.Getenv("S3_PUBLIC_URL"),
+		S3UsePathStyle: getEnv("S3_USE_PATH_STYLE", "true") == "true",
+	}
+}
+
+// New builds the storage driver described by cfg.
+func New(cfg Config) (Storage, error) {
+	switch cfg.Driver {
+	case "", "local":
+		return NewLocalStorage(cfg.LocalRoot, cfg.PublicBaseURL+"/uploads")
+	case "s3":
+		return NewS3Storage(S3Config{
+			Endpoint:     cfg.S3Endpoint,
+			Region:       cfg.S3Region,
+			Bucket:       cfg.S3Bucket,
+			AccessKey:    cfg.S3AccessKey,
+			SecretKey:    cfg.S3SecretKey,
+			PublicURL:    cfg.S3PublicURL,
+			UsePathStyle: cfg.S3UsePathStyle,
+		})
+	default:
+		return nil, fmt.Errorf("unknown storage driver %q", cfg.Driver)
+	}
+}
+
+var (
+	store         Storage
+	publicBaseURL = "http://localhost:8080"
+)
+
+// Init configures the process-wide storage from the environment.
+func Init() error {
+	cfg := ConfigFromEnv()
+	s, err := New(cfg)
+	if err != nil {
+		return err
+	}
+	store = s
+	publicBaseURL = cfg.PublicBaseURL
+	initSigning()
+	return nil
+}
+
+// GetStorage returns the process-wide storage (local "uploads" directory if Init was not called).
+func GetStorage() Storage {
+	if store == nil {
+		store, _ = NewLocalStorage("uploads", publicBaseURL+"/uploads")
+	}
+	return store
+}
+
+// PublicBaseURL returns the configured public URL of this backend.
+func PublicBaseURL() string {
+	return publicBaseURL
+}
+
+// KeyFromPath converts a path stored in the database ("/uploads/avatars/x.jpg")
+// into a storage key ("avatars/x.jpg").
+func KeyFromPath(p string) string {
+	p = strings.TrimPrefix(p, "/")
+	if p == "uploads" {
+		return ""
+	}
+	return strings.TrimPrefix(p, "uploads/")
+}
+
+// PathFromKey is the inverse of KeyFromPath.
+func PathFromKey(key string) string {
+	return "/uploads/" + strings.TrimPrefix(key, "/")
+}
+
+// MediaURL returns the URL of a stored media path: a signed URL served by this backend,
+// or the storage's own public URL when MEDIA_SIGNED_URLS=false.
+// Empty and absolute values are returned untouched.
+func MediaURL(p string) string {
+	if p == "" || strings.HasPrefix(p, "http://") || strings.HasPrefix(p, "https://") {
+		return p
+	}
+	if useSignedURLs {
+		return SignedURL(KeyFromPath(p))
+	}
+	return GetStorage().URL(KeyFromPath(p))
+}
+
+func getEnv(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
@@ -1,8 +1,7 @@
 package utils
 
+import "social/storage"
+
 func PrepareAvatarURL(path string) string {
-	if path == "" {
-		return ""
-	}
-	return "http://localhost:8080/" + path
+	return storage.MediaURL(path)
 }
\ No newline at end of file
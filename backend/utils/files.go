@@ -1,17 +1,23 @@
 package utils
 
 import (
+	"bytes"
 	"crypto/rand"
+	"encoding/binary"
 	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
 	"mime/multipart"
 	"net/http"
-	"os"
+	"path"
 	"path/filepath"
 	"strings"
 	"time"
+
+	"social/models"
+	"social/services"
+	"social/storage"
 )
 
 var (
@@ -26,78 +32,280 @@ type UploadConfig struct {
 	MaxSize      int64
 	AllowedTypes []string
 	UploadDir    string
+	Image        *ImageOptions          // si défini, le fichier est décodé et ré-encodé comme une image
+	Quota        func(size int64) error // si défini, appelé avec la taille totale (variantes comprises) avant toute écriture
 }
 
 // DefaultImageUploadConfig retourne une config par défaut pour les images
 func DefaultImageUploadConfig(uploadDir string) UploadConfig {
+	opts := DefaultImageOptions()
 	return UploadConfig{
 		MaxSize: 10 << 20, // 10 MB
 		AllowedTypes: []string{
 			"image/jpeg",
-			"image/jpg",
 			"image/png",
 			"image/gif",
 			"image/webp",
 		},
 		UploadDir: uploadDir,
+		Image:     &opts,
 	}
 }
 
+// ChatAttachmentTypes liste les types acceptés en pièce jointe du chat en plus des images
+// (les notes vocales enregistrées par les navigateurs arrivent en webm ou ogg)
+var ChatAttachmentTypes = []string{
+	"audio/mpeg",
+	"audio/wave",
+	"audio/aiff",
+	"audio/mp4",
+	"application/ogg",
+	"video/webm",
+	"application/pdf",
+	"application/zip",
+	"text/plain",
+}
+
+// uploadExtensions donne l'extension d'enregistrement de chaque type détecté : le nom envoyé par le client
+// n'est jamais utilisé, un texte nommé page.html est donc stocké en .txt
+var uploadExtensions = map[string]string{
+	"audio/mpeg":      ".mp3",
+	"audio/wave":      ".wav",
+	"audio/aiff":      ".aiff",
+	"audio/mp4":       ".m4a",
+	"application/ogg": ".ogg",
+	"video/webm":      ".webm",
+	"application/pdf": ".pdf",
+	"application/zip": ".zip",
+	"text/plain":      ".txt",
+}
+
+// ChatAttachmentUploadConfig choisit la config d'upload d'une pièce jointe du chat d'après son contenu :
+// une image est traitée comme les autres images (10 MB, ré-encodage), tout autre fichier doit être
+// d'un type de ChatAttachmentTypes et ne pas dépasser maxSize
+func ChatAttachmentUploadConfig(r *http.Request, fieldName, uploadDir string, maxSize int64) (UploadConfig, error) {
+	file, _, err := r.FormFile(fieldName)
+	if err != nil {
+		if err == http.ErrMissingFile {
+			return UploadConfig{}, ErrNoFile
+		}
+		return UploadConfig{}, fmt.Errorf("failed to get file: %w", err)
+	}
+	defer file.Close()
+
+	head := make([]byte, 512)
+	n, _ := io.ReadFull(file, head)
+	if _, ok := SniffImageType(head[:n]); ok {
+		return DefaultImageUploadConfig(uploadDir), nil
+	}
+
+	return UploadConfig{
+		MaxSize:      maxSize,
+		AllowedTypes: ChatAttachmentTypes,
+		UploadDir:    uploadDir,
+	}, nil
+}
+
 // HandleFileUpload gère l'upload d'un fichier depuis un formulaire multipart
 func HandleFileUpload(r *http.Request, fieldName string, config UploadConfig) (string, error) {
+	media, err := SaveUpload(r, fieldName, config)
+	if err != nil {
+		return "", err
+	}
+	return media.Path, nil
+}
+
+// SaveUpload vérifie le contenu réel du fichier (magic bytes), le traite si c'est une image
+// (suppression EXIF, redimensionnement, variantes) et retourne ses métadonnées.
+func SaveUpload(r *http.Request, fieldName string, config UploadConfig) (*models.Media, error) {
 	file, header, err := r.FormFile(fieldName)
 	if err != nil {
 		if err == http.ErrMissingFile {
-			return "", ErrNoFile
+			return nil, ErrNoFile
 		}
-		return "", fmt.Errorf("failed to get file: %w", err)
+		return nil, fmt.Errorf("failed to get file: %w", err)
 	}
 	defer file.Close()
 
-	if !isValidFileType(header, config.AllowedTypes) {
-		return "", ErrInvalidFileType
+	if config.MaxSize > 0 && header.Size > config.MaxSize {
+		return nil, ErrFileTooLarge
 	}
 
-	if config.MaxSize > 0 && header.Size > config.MaxSize {
-		return "", ErrFileTooLarge
+	data, err := readLimited(file, config.MaxSize)
+	if err != nil {
+		return nil, err
 	}
 
-	filename := generateUniqueFilename(header.Filename)
-	fullPath := filepath.Join(config.UploadDir, filename)
+	contentType := detectContentType(data)
+	if !isAllowedType(contentType, config.AllowedTypes) {
+		return nil, ErrInvalidFileType
+	}
 
-	if err := os.MkdirAll(config.UploadDir, os.ModePerm); err != nil {
-		return "", fmt.Errorf("failed to create upload directory: %w", err)
+	if config.Image == nil {
+		if err := checkQuota(config, int64(len(data))); err != nil {
+			return nil, err
+		}
+		key := uploadKey(config.UploadDir, generateUniqueFilename(uploadExtensions[contentType]))
+		if err := putFile(r, key, data, contentType); err != nil {
+			return nil, err
+		}
+		return &models.Media{
+			Path:     storage.PathFromKey(key),
+			MimeType: contentType,
+			Size:     int64(len(data)),
+		}, nil
 	}
 
-	dst, err := os.Create(fullPath)
+	processed, err := ProcessImage(data, *config.Image)
 	if err != nil {
-		return "", fmt.Errorf("failed to create destination file: %w", err)
+		return nil, err
+	}
+
+	total := int64(len(processed.Data))
+	for _, v := range processed.Variants {
+		total += int64(len(v.Data))
+	}
+	if err := checkQuota(config, total); err != nil {
+		return nil, err
+	}
+
+	base := generateUniqueFilename("")
+	key := uploadKey(config.UploadDir, base+processed.Ext)
+	if err := putFile(r, key, processed.Data, processed.ContentType); err != nil {
+		return nil, err
 	}
-	defer dst.Close()
 
-	if _, err := io.Copy(dst, file); err != nil {
-		return "", fmt.Errorf("failed to write file: %w", err)
+	media := &models.Media{
+		Path:     storage.PathFromKey(key),
+		MimeType: processed.ContentType,
+		Size:     total,
+		Width:    processed.Width,
+		Height:   processed.Height,
+		BlurHash: processed.BlurHash,
 	}
 
-	return "/" + fullPath, nil
+	for _, v := range processed.Variants {
+		variantKey := uploadKey(config.UploadDir, base+"_"+v.Name+processed.Ext)
+		if err := putFile(r, variantKey, v.Data, processed.ContentType); err != nil {
+			discardFiles(r, media)
+			return nil, fmt.Errorf("failed to write %s variant: %w", v.Name, err)
+		}
+		switch v.Name {
+		case "thumb":
+			media.ThumbPath = storage.PathFromKey(variantKey)
+		case "medium":
+			media.MediumPath = storage.PathFromKey(variantKey)
+		}
+	}
+
+	return media, nil
 }
 
-// isValidFileType vérifie si le type MIME du fichier est autorisé
-func isValidFileType(header *multipart.FileHeader, allowedTypes []string) bool {
-	contentType := header.Header.Get("Content-Type")
+// discardFiles supprime du stockage les fichiers d'un upload interrompu, pas encore enregistré en base
+func discardFiles(r *http.Request, media *models.Media) {
+	for _, p := range []string{media.Path, media.ThumbPath, media.MediumPath} {
+		if p == "" {
+			continue
+		}
+		if err := storage.GetStorage().Delete(r.Context(), storage.KeyFromPath(p)); err != nil {
+			fmt.Println("❌ Failed to delete discarded upload:", err)
+		}
+	}
+}
 
+// UploadErrorStatus retourne le code HTTP correspondant à une erreur d'upload
+func UploadErrorStatus(err error) int {
+	if errors.Is(err, ErrFileTooLarge) || errors.Is(err, services.ErrStorageQuotaExceeded) {
+		return http.StatusRequestEntityTooLarge
+	}
+	return http.StatusBadRequest
+}
+
+func checkQuota(config UploadConfig, size int64) error {
+	if config.Quota == nil {
+		return nil
+	}
+	return config.Quota(size)
+}
+
+// uploadKey convertit un dossier d'upload ("uploads/avatars") et un nom de fichier en clé de stockage
+func uploadKey(uploadDir, filename string) string {
+	return path.Join(storage.KeyFromPath(filepath.ToSlash(uploadDir)), filename)
+}
+
+// putFile écrit le fichier dans le stockage configuré (disque local ou S3)
+func putFile(r *http.Request, key string, data []byte, contentType string) error {
+	if err := storage.GetStorage().Put(r.Context(), key, bytes.NewReader(data), int64(len(data)), contentType); err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	return nil
+}
+
+// readLimited lit tout le fichier en refusant ceux qui dépassent maxSize
+func readLimited(file io.Reader, maxSize int64) ([]byte, error) {
+	if maxSize <= 0 {
+		return io.ReadAll(file)
+	}
+	data, err := io.ReadAll(io.LimitReader(file, maxSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	if int64(len(data)) > maxSize {
+		return nil, ErrFileTooLarge
+	}
+	return data, nil
+}
+
+// detectContentType détermine le type MIME à partir du contenu, jamais de l'en-tête client
+func detectContentType(data []byte) string {
+	if imageType, ok := SniffImageType(data); ok {
+		return imageType
+	}
+	if isM4A(data) {
+		return "audio/mp4"
+	}
+	contentType := http.DetectContentType(data)
+	if i := strings.Index(contentType, ";"); i >= 0 {
+		contentType = contentType[:i]
+	}
+	return contentType
+}
+
+// isM4A reconnaît l'audio MPEG-4 (m4a, m4b) aux marques de sa boîte ftyp ;
+// http.DetectContentType le classe en video/mp4 ou ne le reconnaît pas
+func isM4A(data []byte) bool {
+	if len(data) < 16 || string(data[4:8]) != "ftyp" {
+		return false
+	}
+	boxSize := int(binary.BigEndian.Uint32(data[:4]))
+	if boxSize < 16 || boxSize > len(data) {
+		return false
+	}
+	// Marque principale en 8:12, version en 12:16, puis les marques compatibles
+	for i := 8; i+4 <= boxSize; i += 4 {
+		if i == 12 {
+			continue
+		}
+		switch string(data[i : i+4]) {
+		case "M4A ", "M4B ":
+			return true
+		}
+	}
+	return false
+}
+
+// isAllowedType vérifie si le type MIME détecté est autorisé
+func isAllowedType(contentType string, allowedTypes []string) bool {
 	for _, allowed := range allowedTypes {
 		if contentType == allowed {
 			return true
 		}
 	}
-
 	return false
 }
 
-// generateUniqueFilename génère un nom de fichier unique
-func generateUniqueFilename(originalName string) string {
-	ext := filepath.Ext(originalName)
+// generateUniqueFilename génère un nom de fichier unique terminé par ext, choisie par le serveur
+func generateUniqueFilename(ext string) string {
 	timestamp := time.Now().UnixNano()
 	random := RandString(8)
 
@@ -115,6 +323,17 @@ func HandleOptionalFileUpload(r *http.Request, fieldName string, config UploadCo
 	return path, err
 }
 
+// HandleOptionalMediaUpload gère un upload optionnel et retourne ses métadonnées (nil si aucun fichier)
+func HandleOptionalMediaUpload(r *http.Request, fieldName string, config UploadConfig) (*models.Media, error) {
+	media, err := SaveUpload(r, fieldName, config)
+
+	if err == ErrNoFile || err == http.ErrMissingFile {
+		return nil, nil
+	}
+
+	return media, err
+}
+
 // RandString génère une chaîne aléatoire (déjà existant dans post.go)
 func RandString(n int) string {
 	bytes := make([]byte, n)
@@ -124,16 +343,20 @@ func RandString(n int) string {
 	return hex.EncodeToString(bytes)[:n]
 }
 
-// IsValidImage vérifie si le fichier est une image valide (déjà existant dans post.go)
+// IsValidImage vérifie si le fichier est une image valide d'après son contenu (déjà existant dans post.go)
 func IsValidImage(fileHeader *multipart.FileHeader) error {
-	allowedTypes := []string{"image/jpeg", "image/png", "image/gif"}
+	file, err := fileHeader.Open()
+	if err != nil {
+		return err
+	}
+	defer file.Close()
 
-	for _, t := range allowedTypes {
-		if fileHeader.Header.Get("Content-Type") == t {
-			return nil
-		}
+	head := make([]byte, 512)
+	n, _ := io.ReadFull(file, head)
+	if _, ok := SniffImageType(head[:n]); !ok {
+		return errors.New("unsupported image format")
 	}
-	return errors.New("unsupported image format")
+	return nil
 }
 
 // GenerateFilename génère un nom de fichier (déjà existant dans post.go)
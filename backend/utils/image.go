@@ -0,0 +1,287 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+
+	_ "image/gif"
+
+	xdraw "golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+var (
+	ErrUnsupportedImage = errors.New("unsupported or corrupted image")
+	ErrImageTooLarge    = errors.New("image dimensions too large")
+)
+
+// ImageVariant décrit une déclinaison redimensionnée d'une image (miniature, moyenne…)
+type ImageVariant struct {
+	Name         string
+	MaxDimension int
+}
+
+// ImageOptions contrôle le ré-encodage des images uploadées
+type ImageOptions struct {
+	MaxDimension int // côté le plus long de l'image principale
+	MaxPixels    int // protection contre les "decompression bombs"
+	JPEGQuality  int
+	Variants     []ImageVariant
+}
+
+// DefaultImageOptions retourne les options utilisées pour avatars, posts et posts de groupe
+func DefaultImageOptions() ImageOptions {
+	return ImageOptions{
+		MaxDimension: 2048,
+		MaxPixels:    40_000_000,
+		JPEGQuality:  85,
+		Variants: []ImageVariant{
+			{Name: "thumb", MaxDimension: 160},
+			{Name: "medium", MaxDimension: 720},
+		},
+	}
+}
+
+// ProcessedVariant est une déclinaison encodée d'une image
+type ProcessedVariant struct {
+	Name   string
+	Data   []byte
+	Width  int
+	Height int
+}
+
+// ProcessedImage est le résultat du pipeline : image nettoyée + variantes + métadonnées
+type ProcessedImage struct {
+	Data        []byte
+	ContentType string
+	Ext         string
+	Width       int
+	Height      int
+	BlurHash    string
+	Variants    []ProcessedVariant
+}
+
+// SniffImageType identifie le format d'une image à partir de ses magic bytes.
+// Le Content-Type envoyé par le client n'est jamais pris en compte.
+func SniffImageType(data []byte) (string, bool) {
+	switch {
+	case len(data) >= 3 && data[0] == 0xFF && data[1] == 0xD8 && data[2] == 0xFF:
+		return "image/jpeg", true
+	case len(data) >= 8 && bytes.Equal(data[:8], []byte("\x89PNG\r\n\x1a\n")):
+		return "image/png", true
+	case len(data) >= 6 && (bytes.Equal(data[:6], []byte("GIF87a")) || bytes.Equal(data[:6], []byte("GIF89a"))):
+		return "image/gif", true
+	case len(data) >= 12 && bytes.Equal(data[:4], []byte("RIFF")) && bytes.Equal(data[8:12], []byte("WEBP")):
+		return "image/webp", true
+	}
+	return "", false
+}
+
+// ProcessImage décode l'image (Go pur), applique l'orientation EXIF, supprime toutes les
+// métadonnées en ré-encodant les pixels, limite la taille et génère les variantes.
+// Les images opaques sont ré-encodées en JPEG, les autres en PNG.
+func ProcessImage(data []byte, opts ImageOptions) (*ProcessedImage, error) {
+	if _, ok := SniffImageType(data); !ok {
+		return nil, ErrUnsupportedImage
+	}
+
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrUnsupportedImage
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 {
+		return nil, ErrUnsupportedImage
+	}
+	if opts.MaxPixels > 0 && cfg.Width*cfg.Height > opts.MaxPixels {
+		return nil, ErrImageTooLarge
+	}
+
+	src, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrUnsupportedImage
+	}
+
+	img := toNRGBA(src)
+	if format == "jpeg" {
+		img = applyOrientation(img, jpegOrientation(data))
+	}
+
+	opaque := img.Opaque()
+	main := resizeToFit(img, opts.MaxDimension)
+
+	encoded, err := encodeImage(main, opaque, opts.JPEGQuality)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ProcessedImage{
+		Data:     encoded,
+		Width:    main.Bounds().Dx(),
+		Height:   main.Bounds().Dy(),
+		BlurHash: EncodeBlurHash(resizeToFit(main, 32), 4, 3),
+	}
+	if opaque {
+		result.ContentType, result.Ext = "image/jpeg", ".jpg"
+	} else {
+		result.ContentType, result.Ext = "image/png", ".png"
+	}
+
+	for _, v := range opts.Variants {
+		resized := resizeToFit(main, v.MaxDimension)
+		variantData, err := encodeImage(resized, opaque, opts.JPEGQuality)
+		if err != nil {
+			return nil, err
+		}
+		result.Variants = append(result.Variants, ProcessedVariant{
+			Name:   v.Name,
+			Data:   variantData,
+			Width:  resized.Bounds().Dx(),
+			Height: resized.Bounds().Dy(),
+		})
+	}
+
+	return result, nil
+}
+
+func encodeImage(img image.Image, opaque bool, quality int) ([]byte, error) {
+	var buf bytes.Buffer
+	var err error
+	if opaque {
+		if quality <= 0 {
+			quality = jpeg.DefaultQuality
+		}
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality})
+	} else {
+		err = png.Encode(&buf, img)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode image: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+func toNRGBA(src image.Image) *image.NRGBA {
+	b := src.Bounds()
+	dst := image.NewNRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(dst, dst.Bounds(), src, b.Min, draw.Src)
+	return dst
+}
+
+// resizeToFit réduit l'image pour que son plus grand côté ne dépasse pas maxDim (jamais d'agrandissement)
+func resizeToFit(img *image.NRGBA, maxDim int) *image.NRGBA {
+	w, h := img.Bounds().Dx(), img.Bounds().Dy()
+	if maxDim <= 0 || (w <= maxDim && h <= maxDim) {
+		return img
+	}
+
+	nw, nh := maxDim, maxDim
+	if w >= h {
+		nh = max(1, h*maxDim/w)
+	} else {
+		nw = max(1, w*maxDim/h)
+	}
+
+	dst := image.NewNRGBA(image.Rect(0, 0, nw, nh))
+	xdraw.CatmullRom.Scale(dst, dst.Bounds(), img, img.Bounds(), xdraw.Src, nil)
+	return dst
+}
+
+// applyOrientation redresse l'image selon le tag EXIF Orientation (1 à 8),
+// puisque ce tag disparaît avec le reste des métadonnées.
+func applyOrientation(img *image.NRGBA, orientation int) *image.NRGBA {
+	if orientation < 2 || orientation > 8 {
+		return img
+	}
+
+	w, h := img.Bounds().Dx(), img.Bounds().Dy()
+	dw, dh := w, h
+	if orientation >= 5 {
+		dw, dh = h, w
+	}
+
+	dst := image.NewNRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		for x := 0; x < dw; x++ {
+			var sx, sy int
+			switch orientation {
+			case 2:
+				sx, sy = w-1-x, y
+			case 3:
+				sx, sy = w-1-x, h-1-y
+			case 4:
+				sx, sy = x, h-1-y
+			case 5:
+				sx, sy = y, x
+			case 6:
+				sx, sy = y, h-1-x
+			case 7:
+				sx, sy = w-1-y, h-1-x
+			case 8:
+				sx, sy = w-1-y, x
+			}
+			si := img.PixOffset(sx, sy)
+			di := dst.PixOffset(x, y)
+			copy(dst.Pix[di:di+4], img.Pix[si:si+4])
+		}
+	}
+	return dst
+}
+
+// jpegOrientation lit le tag Orientation (0x0112) de l'IFD0 du segment APP1/Exif.
+// Retourne 1 (orientation normale) si absent ou illisible.
+func jpegOrientation(data []byte) int {
+	pos := 2
+	for pos+4 <= len(data) {
+		if data[pos] != 0xFF {
+			return 1
+		}
+		marker := data[pos+1]
+		if marker == 0xDA || marker == 0xD9 { // début des données image
+			return 1
+		}
+		segLen := int(binary.BigEndian.Uint16(data[pos+2:]))
+		if segLen < 2 || pos+2+segLen > len(data) {
+			return 1
+		}
+		segment := data[pos+4 : pos+2+segLen]
+		if marker == 0xE1 && len(segment) >= 14 && bytes.Equal(segment[:6], []byte("Exif\x00\x00")) {
+			return tiffOrientation(segment[6:])
+		}
+		pos += 2 + segLen
+	}
+	return 1
+}
+
+func tiffOrientation(tiff []byte) int {
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+
+	ifd := int(order.Uint32(tiff[4:]))
+	if ifd+2 > len(tiff) {
+		return 1
+	}
+	entries := int(order.Uint16(tiff[ifd:]))
+	for i := 0; i < entries; i++ {
+		entry := ifd + 2 + i*12
+		if entry+12 > len(tiff) {
+			return 1
+		}
+		if order.Uint16(tiff[entry:]) == 0x0112 {
+			return int(order.Uint16(tiff[entry+8:]))
+		}
+	}
+	return 1
+}
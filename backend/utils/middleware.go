@@ -2,8 +2,11 @@ package utils
 
 import (
 	"context"
+	"errors"
+	"math"
 	"net/http"
 	"social/services"
+	"strconv"
 )
 
 // CorsMiddleware gère les CORS pour permettre les requêtes depuis le frontend
@@ -39,6 +42,48 @@ func AuthMiddleware(sessionService *services.SessionService) func(http.Handler)
 	}
 }
 
+// RateLimitMiddleware limite les écritures (POST, PUT) d'une route au budget d'une action :
+// RateLimitMiddleware(limiter)(services.ActionUpload)(handler). À placer après AuthMiddleware, qui fournit l'userID.
+// Le contenu envoyé n'étant pas lu ici, les routes qui publient du texte utilisent plutôt CheckRateLimitContent.
+func RateLimitMiddleware(limiter *services.RateLimitService) func(action string) func(http.Handler) http.Handler {
+	return func(action string) func(http.Handler) http.Handler {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method == http.MethodPost || r.Method == http.MethodPut {
+					userID, ok := GetUserIDFromContext(r.Context())
+					if ok && !CheckRateLimit(w, limiter, userID, action) {
+						return
+					}
+				}
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+}
+
+// CheckRateLimit consomme une action de userID ; si elle est refusée, répond 429 avec Retry-After et retourne false
+func CheckRateLimit(w http.ResponseWriter, limiter *services.RateLimitService, userID int, action string) bool {
+	return CheckRateLimitContent(w, limiter, userID, action, "")
+}
+
+// CheckRateLimitContent fait de même en refusant aussi un contenu répété trop souvent : les handlers
+// l'appellent une fois le corps lu, à la place de RateLimitMiddleware
+func CheckRateLimitContent(w http.ResponseWriter, limiter *services.RateLimitService, userID int, action, content string) bool {
+	err := limiter.AllowContent(userID, action, content)
+	if err == nil {
+		return true
+	}
+
+	var rl *services.RateLimitError
+	if !errors.As(err, &rl) {
+		WriteError(w, http.StatusInternalServerError, "Internal server error")
+		return false
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(max(1, int(math.Ceil(rl.RetryAfter.Seconds())))))
+	WriteDetailedError(w, http.StatusTooManyRequests, ErrorResponse{Error: rl.Error(), Code: rl.Reason})
+	return false
+}
+
 // GetUserIDFromContext récupère l'userID depuis le contexte
 func GetUserIDFromContext(ctx context.Context) (int, bool) {
 	userID, ok := ctx.Value("userID").(int)
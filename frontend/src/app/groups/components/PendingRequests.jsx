@@ -92,7 +92,7 @@ export default function PendingRequests({ groupId }) {
                 <div className={styles.flexBetween}>
                   <div className={styles.flexCenter}>
                     <img
-                      src={request.avatar ? `http://localhost:8080/${request.avatar}` : '/avatar.png'}
+                      src={avatarUrl}
                       alt={request.username}
                       width={40}
                       height={40}
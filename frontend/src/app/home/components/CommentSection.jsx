@@ -108,7 +108,7 @@ export default function CommentSection({ postId }) {
                             <p className={styles.commentContent}>{comment.content}</p>
                             {comment.image_url && (
                                 <img
-                                    src={`http://localhost:8080${comment.image_url}`}
+                                    src={comment.image_url.startsWith('http') ? comment.image_url : `http://localhost:8080${comment.image_url}`}
                                     alt="Image commentaire"
                                     className={styles.commentImage}
                                 />
@@ -48,7 +48,7 @@ export default function Post({ post, fetchUserById }) {
       {/* Image */}
       {post.image_url && (
         <img
-          src={`http://localhost:8080${post.image_url}`}
+          src={post.image_url.startsWith('http') ? post.image_url : `http://localhost:8080${post.image_url}`}
           alt="Post"
           className={styles.postImage}
         />
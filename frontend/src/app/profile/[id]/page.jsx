@@ -198,7 +198,7 @@ export default function PublicProfilePage() {
                   onClick={() => router.push(`/profile/${user.ID}`)}
                 >
                   <img
-                    src={user.Avatar ? `${API_BASE_URL}/${user.Avatar}` : '/avatar.png'}
+                    src={user.Avatar ? (user.Avatar.startsWith('http') ? user.Avatar : `${API_BASE_URL}/${user.Avatar}`) : '/avatar.png'}
                     className={styles.userAvatar}
                     alt="avatar"
                   />
@@ -224,7 +224,7 @@ export default function PublicProfilePage() {
                   onClick={() => router.push(`/profile/${user.ID}`)}
                 >
                   <img
-                    src={user.Avatar ? `${API_BASE_URL}/${user.Avatar}` : '/avatar.png'}
+                    src={user.Avatar ? (user.Avatar.startsWith('http') ? user.Avatar : `${API_BASE_URL}/${user.Avatar}`) : '/avatar.png'}
                     className={styles.userAvatar}
                     alt="avatar"
                   />